@@ -11,8 +11,10 @@ import (
 )
 
 // New creates a new goldmark.Markdown instance configured to output ADF JSON.
+// It parses plain CommonMark; use [NewWithGFM] for the extensions. The
+// instance is safe for concurrent use.
 func New(opts ...Option) goldmark.Markdown {
-	r := NewRenderer(opts...)
+	r := newRenderer(opts...)
 	md := goldmark.New(
 		goldmark.WithRenderer(
 			renderer.NewRenderer(
@@ -25,10 +27,14 @@ func New(opts ...Option) goldmark.Markdown {
 	return md
 }
 
-// NewWithGFM creates a new goldmark.Markdown instance with GFM extensions enabled.
-// This enables parsing of tables, strikethrough, autolinks, and task lists.
+// NewWithGFM creates a new goldmark.Markdown instance with GFM extensions
+// enabled: tables, strikethrough, autolinks and task lists. It also parses
+// footnotes, definition lists, decision lists ([D]/[d] list items),
+// GitHub-style alerts, <details> sections, mentions, emoji shortcodes, status
+// lozenges, dates, :::name {attrs} containers and confluence-macro fences. The
+// instance is safe for concurrent use.
 func NewWithGFM(opts ...Option) goldmark.Markdown {
-	r := NewRenderer(opts...)
+	r := newRenderer(opts...)
 
 	// Create a custom renderer that ONLY uses our ADF renderer
 	// We don't include any HTML renderers
@@ -48,6 +54,17 @@ func NewWithGFM(opts ...Option) goldmark.Markdown {
 	// Manually add only the PARSER parts of GFM extensions
 	// (not their HTML renderers)
 	addGFMParsers(md)
+	addFootnoteParser(md)
+	addDefinitionListParser(md)
+	addAlertParser(md, r.config.AlertPanels)
+	addDecisionParser(md)
+	addDetailsParser(md)
+	addMentionParser(md, r.config.MentionResolver)
+	addEmojiParser(md, r.config.CustomEmoji)
+	addStatusParser(md, r.config.StatusSyntax)
+	addDateParser(md)
+	addContainerParser(md, r.config.Containers)
+	addConfluenceMacroParser(md)
 
 	return md
 }
@@ -106,6 +123,26 @@ func ConvertWithGFM(source []byte) ([]byte, error) {
 	return w.buf, nil
 }
 
+// ConvertWithReport is like ConvertWithGFM but also returns the diagnostics
+// reported during the conversion, describing content that did not convert
+// cleanly (see [Diagnostic]). The options configure the conversion as for
+// [NewWithGFM]; a handler set with [WithDiagnosticHandler] is replaced. If the
+// conversion fails, the diagnostics reported up to the failure are returned
+// along with the error.
+func ConvertWithReport(source []byte, opts ...Option) ([]byte, []Diagnostic, error) {
+	var diagnostics []Diagnostic
+	opts = append(opts[:len(opts):len(opts)], WithDiagnosticHandler(func(d Diagnostic) {
+		diagnostics = append(diagnostics, d)
+	}))
+
+	var buf = make([]byte, 0, len(source)*2)
+	w := &bytesWriter{buf: buf}
+	if err := NewWithGFM(opts...).Convert(source, w); err != nil {
+		return nil, diagnostics, err
+	}
+	return w.buf, diagnostics, nil
+}
+
 // bytesWriter is a simple io.Writer that appends to a byte slice.
 type bytesWriter struct {
 	buf []byte
@@ -5,9 +5,17 @@ package adf
 import (
 	"bytes"
 	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ajbeck/goldmark-adf/adfschema"
+	"github.com/yuin/goldmark"
 )
 
 func TestConvert_Paragraph(t *testing.T) {
@@ -276,6 +284,110 @@ func TestConvertWithGFM_Table(t *testing.T) {
 	}
 }
 
+func TestConvertWithGFM_Table_Alignment(t *testing.T) {
+	input := []byte(`| Left | Center | Right | None |
+| :--- | :----: | ----: | ---- |
+| a    | b      | c     | d    |`)
+
+	output, err := ConvertWithGFM(input)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	want := []string{"", "center", "end", ""}
+	for _, row := range doc.Content[0].Content {
+		for i, cell := range row.Content {
+			para := cell.Content[0]
+			got := ""
+			if len(para.Marks) == 1 && para.Marks[0].Type == "alignment" {
+				got, _ = para.Marks[0].Attrs["align"].(string)
+			} else if len(para.Marks) > 0 {
+				t.Errorf("Unexpected marks on column %d: %v", i, para.Marks)
+			}
+			if got != want[i] {
+				t.Errorf("Column %d: expected alignment %q, got %q", i, want[i], got)
+			}
+		}
+	}
+}
+
+func TestConvertWithGFM_Table_Options(t *testing.T) {
+	input := []byte(`| A | B | C |
+|---|---|---|
+| 1 | 2 | 3 |`)
+
+	output, err := convertWithGFMOptions(input,
+		WithTableLayout("wide"),
+		WithTableNumberColumn(true),
+		WithTableColumnWidths(200, 0, 150, 999),
+		WithTableDisplayMode("fixed"),
+	)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	table := doc.Content[0]
+	if table.Attrs["layout"] != "wide" {
+		t.Errorf("Expected layout 'wide', got %v", table.Attrs["layout"])
+	}
+	if table.Attrs["isNumberColumnEnabled"] != true {
+		t.Errorf("Expected isNumberColumnEnabled true, got %v", table.Attrs["isNumberColumnEnabled"])
+	}
+	if table.Attrs["displayMode"] != "fixed" {
+		t.Errorf("Expected displayMode 'fixed', got %v", table.Attrs["displayMode"])
+	}
+
+	want := []any{[]any{200.0}, nil, []any{150.0}}
+	for _, row := range table.Content {
+		for i, cell := range row.Content {
+			if got := cell.Attrs["colwidth"]; !reflect.DeepEqual(got, want[i]) {
+				t.Errorf("Column %d: expected colwidth %v, got %v", i, want[i], got)
+			}
+		}
+	}
+}
+
+func TestConvertWithGFM_Table_Defaults(t *testing.T) {
+	output, err := ConvertWithGFM([]byte("| A |\n|---|\n| 1 |"))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	table := doc.Content[0]
+	if table.Attrs["layout"] != "default" || table.Attrs["isNumberColumnEnabled"] != false {
+		t.Errorf("Expected default table attrs, got %v", table.Attrs)
+	}
+	if _, ok := table.Attrs["displayMode"]; ok {
+		t.Errorf("Expected no displayMode by default, got %v", table.Attrs["displayMode"])
+	}
+	if _, ok := table.Content[1].Content[0].Attrs["colwidth"]; ok {
+		t.Errorf("Expected no colwidth by default, got %v", table.Content[1].Content[0].Attrs)
+	}
+}
+
 func TestConvertWithGFM_Strikethrough(t *testing.T) {
 	input := []byte("~~deleted~~")
 	output, err := ConvertWithGFM(input)
@@ -288,40 +400,2162 @@ func TestConvertWithGFM_Strikethrough(t *testing.T) {
 	}
 }
 
-func TestNew_ReusableInstance(t *testing.T) {
-	md := New()
+func TestConvertWithGFM_MarkConflicts(t *testing.T) {
+	policies := []MarkConflictPolicy{MarkConflictKeepCode, MarkConflictDropCode, MarkConflictFail}
+	tests := []struct {
+		input string
+		// want holds the mark types on the text node containing "x" for each
+		// policy; nil means the conversion must fail.
+		want [][]string
+	}{
+		{"**`x`**", [][]string{{"code"}, {"strong"}, nil}},
+		{"~~`x`~~", [][]string{{"code"}, {"strike"}, nil}},
+		{"***`x`***", [][]string{{"code"}, {"em", "strong"}, nil}},
+		{"[**`x`**](https://example.com)", [][]string{{"link", "code"}, {"link", "strong"}, nil}},
+		{"[`x`](https://example.com)", [][]string{{"link", "code"}, {"link", "code"}, {"link", "code"}}},
+		{"**[x](https://example.com)**", [][]string{{"link", "strong"}, {"link", "strong"}, {"link", "strong"}}},
+		{"~~*x*~~", [][]string{{"em", "strike"}, {"em", "strike"}, {"em", "strike"}}},
+		{"*a *x* b*", [][]string{{"em"}, {"em"}, {"em"}}},
+	}
+
+	for _, tt := range tests {
+		for i, policy := range policies {
+			output, err := convertWithGFMOptions([]byte(tt.input), WithMarkConflicts(policy))
+			if tt.want[i] == nil {
+				var conflict *MarkConflictError
+				if !errors.As(err, &conflict) {
+					t.Errorf("%q with policy %d: expected MarkConflictError, got %v", tt.input, policy, err)
+				} else if conflict.Text != "x" || conflict.Marks[len(conflict.Marks)-1] != "code" {
+					t.Errorf("%q with policy %d: unexpected error %v", tt.input, policy, conflict)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%q with policy %d: Convert failed: %v", tt.input, policy, err)
+				continue
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("%q with policy %d: Invalid ADF output: %v\nOutput: %s", tt.input, policy, err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			var got []string
+			for _, n := range doc.Content[0].Content {
+				if strings.Contains(n.Text, "x") {
+					for _, m := range n.Marks {
+						got = append(got, m.Type)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want[i]) {
+				t.Errorf("%q with policy %d: expected marks %v, got %v", tt.input, policy, tt.want[i], got)
+			}
+		}
+	}
+}
 
-	inputs := []string{
-		"# Hello",
-		"Paragraph",
-		"- List item",
+func TestConvert_SoftLineBreaks(t *testing.T) {
+	input := []byte("First line\nsecond *emphasised\nline* and `code\nspan`\nlast line")
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []Node
+	}{
+		{
+			name: "spaces",
+			want: []Node{
+				*NewText("First line second "),
+				*NewTextWithMarks("emphasised line", []Mark{NewEmMark()}),
+				*NewText(" and "),
+				*NewTextWithMarks("code span", []Mark{NewCodeMark()}),
+				*NewText(" last line"),
+			},
+		},
+		{
+			name: "hard wraps",
+			opts: []Option{WithHardWraps(true)},
+			want: []Node{
+				*NewText("First line"),
+				*NewHardBreak(),
+				*NewText("second "),
+				*NewTextWithMarks("emphasised", []Mark{NewEmMark()}),
+				*NewHardBreak(),
+				*NewTextWithMarks("line", []Mark{NewEmMark()}),
+				*NewText(" and "),
+				*NewTextWithMarks("code span", []Mark{NewCodeMark()}),
+				*NewHardBreak(),
+				*NewText("last line"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions(input, tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			if got := doc.Content[0].Content; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
 	}
+}
 
-	for _, input := range inputs {
+func TestDocument_Normalize(t *testing.T) {
+	doc, err := ParseDocument([]byte(`{"version": 1, "type": "doc", "content": [
+		{"type": "paragraph", "content": [
+			{"type": "text", "text": "a"},
+			{"type": "text", "text": ""},
+			{"type": "text", "text": "b"},
+			{"type": "text", "text": "c", "marks": [{"type": "link", "attrs": {"href": "https://example.com"}}]},
+			{"type": "text", "text": "d", "marks": [{"type": "link", "attrs": {"href": "https://example.com"}}]},
+			{"type": "text", "text": "e", "marks": [{"type": "link", "attrs": {"href": "https://example.org"}}]},
+			{"type": "text", "text": "f", "extra": true},
+			{"type": "text", "text": "g"},
+			{"type": "hardBreak"},
+			{"type": "text", "text": "h"}
+		]}
+	]}`))
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+	doc.Normalize()
+
+	var got []string
+	for _, n := range doc.Content[0].Content {
+		got = append(got, n.Type+":"+n.Text)
+	}
+	want := []string{"text:ab", "text:cd", "text:e", "text:f", "text:g", "hardBreak:", "text:h"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestConvert_NormalizeShrinksOutput(t *testing.T) {
+	input := []byte(strings.Repeat("A soft-wrapped line of *prose*\nthat continues here\n", 50))
+	output, err := Convert(input)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	// Each source line holds a plain and an emphasised run, and the plain
+	// runs on either side of each line break are merged
+	if n := len(doc.Content[0].Content); n != 101 {
+		t.Errorf("Expected 101 text nodes, got %d", n)
+	}
+}
+
+// BenchmarkConvert_LargeDocument converts a large, soft-wrapped document and
+// reports the output size.
+func BenchmarkConvert_LargeDocument(b *testing.B) {
+	var src strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&src, "## Section %d\n\nThis paragraph is wrapped\nover several lines with **bold**,\n*emphasis* and `code`\nso that it spans many segments.\n\n- item one\n  continued\n- item two\n\n", i)
+	}
+	input := []byte(src.String())
+	md := New()
+
+	var size int
+	b.ReportAllocs()
+	for b.Loop() {
 		var buf bytes.Buffer
-		if err := md.Convert([]byte(input), &buf); err != nil {
-			t.Errorf("Convert failed for %q: %v", input, err)
-		}
-		if err := adfschema.Validate(buf.Bytes()); err != nil {
-			t.Errorf("Invalid ADF for %q: %v", input, err)
+		if err := md.Convert(input, &buf); err != nil {
+			b.Fatal(err)
 		}
+		size = buf.Len()
 	}
+	b.ReportMetric(float64(size), "output-bytes")
 }
 
-// convertWithOptions is a test helper that converts markdown with options
-func convertWithOptions(source []byte, opts ...Option) ([]byte, error) {
-	var buf bytes.Buffer
-	md := New(opts...)
-	if err := md.Convert(source, &buf); err != nil {
-		return nil, err
+func TestConvert_HTMLPolicy(t *testing.T) {
+	inline := "H<sub>2</sub>O <u>under *lined</u> text*<br>next"
+	block := "<table>\n<tr><th>A</th></tr>\n<tr><td>1 <b>x</b></td></tr>\n</table>"
+
+	tests := []struct {
+		name   string
+		policy HTMLPolicy
+		input  string
+		want   string
+	}{
+		{
+			name:   "drop inline",
+			policy: HTMLDrop,
+			input:  inline,
+			want:   `[{"type":"paragraph","content":[{"type":"text","text":"H2O under "},{"type":"text","marks":[{"type":"em"}],"text":"lined text"},{"type":"text","text":"next"}]}]`,
+		},
+		{
+			name:   "drop block",
+			policy: HTMLDrop,
+			input:  block,
+			want:   `[]`,
+		},
+		{
+			name:   "text inline",
+			policy: HTMLText,
+			input:  "a<br>b",
+			want:   `[{"type":"paragraph","content":[{"type":"text","text":"a<br>b"}]}]`,
+		},
+		{
+			name:   "text block",
+			policy: HTMLText,
+			input:  "<div>\nhi\n</div>",
+			want:   `[{"type":"paragraph","content":[{"type":"text","text":"<div>"},{"type":"hardBreak"},{"type":"text","text":"hi"},{"type":"hardBreak"},{"type":"text","text":"</div>"}]}]`,
+		},
+		{
+			name:   "code block inline",
+			policy: HTMLCodeBlock,
+			input:  "a<br>b",
+			want:   `[{"type":"paragraph","content":[{"type":"text","text":"a"},{"type":"text","marks":[{"type":"code"}],"text":"<br>"},{"type":"text","text":"b"}]}]`,
+		},
+		{
+			name:   "code block block",
+			policy: HTMLCodeBlock,
+			input:  "<div>\nhi\n</div>",
+			want:   `[{"type":"codeBlock","attrs":{"language":"html"},"content":[{"type":"text","text":"<div>\nhi\n</div>"}]}]`,
+		},
+		{
+			name:   "translate inline",
+			policy: HTMLTranslate,
+			input:  inline,
+			want:   `[{"type":"paragraph","content":[{"type":"text","text":"H"},{"type":"text","marks":[{"type":"subsup","attrs":{"type":"sub"}}],"text":"2"},{"type":"text","text":"O "},{"type":"text","marks":[{"type":"underline"}],"text":"under "},{"type":"text","marks":[{"type":"em"},{"type":"underline"}],"text":"lined"},{"type":"text","marks":[{"type":"em"}],"text":" text"},{"type":"hardBreak"},{"type":"text","text":"next"}]}]`,
+		},
+		{
+			name:   "translate code marks",
+			policy: HTMLTranslate,
+			input:  "Press <kbd>Ctrl</kbd> and <mark>look</mark>",
+			want:   `[{"type":"paragraph","content":[{"type":"text","text":"Press "},{"type":"text","marks":[{"type":"code"}],"text":"Ctrl"},{"type":"text","text":" and "},{"type":"text","marks":[{"type":"backgroundColor","attrs":{"color":"#fff0b3"}}],"text":"look"}]}]`,
+		},
+		{
+			name:   "translate unclosed tag ends with its block",
+			policy: HTMLTranslate,
+			input:  "<u>a\n\nb",
+			want:   `[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"underline"}],"text":"a"}]},{"type":"paragraph","content":[{"type":"text","text":"b"}]}]`,
+		},
+		{
+			name:   "translate table",
+			policy: HTMLTranslate,
+			input:  block,
+			want:   `[{"type":"table","attrs":{"isNumberColumnEnabled":false,"layout":"default"},"content":[{"type":"tableRow","content":[{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"A"}]}]}]},{"type":"tableRow","content":[{"type":"tableCell","content":[{"type":"paragraph","content":[{"type":"text","text":"1 "},{"type":"text","marks":[{"type":"strong"}],"text":"x"}]}]}]}]}]`,
+		},
+		{
+			name:   "translate blocks",
+			policy: HTMLTranslate,
+			input:  "<p>Para <i>one</i></p>\n<h2>Title</h2>\n<hr>\n<!-- comment -->",
+			want:   `[{"type":"paragraph","content":[{"type":"text","text":"Para "},{"type":"text","marks":[{"type":"em"}],"text":"one"}]},{"type":"heading","attrs":{"level":2},"content":[{"type":"text","text":"Title"}]},{"type":"rule"}]`,
+		},
+		{
+			name:   "translate table in a list item",
+			policy: HTMLTranslate,
+			input:  "- item\n\n  <table>\n  <tr><th>A</th></tr>\n  <tr><td>1</td></tr>\n  </table>",
+			want:   `[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"item"}]},{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"A"}]},{"type":"paragraph","content":[{"type":"text","text":"1"}]}]}]}]`,
+		},
+		{
+			name:   "translate heading in a list item",
+			policy: HTMLTranslate,
+			input:  "- item\n\n  <h1>Title <code>x</code></h1>",
+			want:   `[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"item"}]},{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"Title "},{"type":"text","marks":[{"type":"code"}],"text":"x"}]}]}]}]`,
+		},
+		{
+			name:   "translate rule in a blockquote",
+			policy: HTMLTranslate,
+			input:  "> text\n>\n> <hr>",
+			want:   `[{"type":"blockquote","content":[{"type":"paragraph","content":[{"type":"text","text":"text"}]}]}]`,
+		},
+		{
+			name:   "translate drops script and style blocks",
+			policy: HTMLTranslate,
+			input:  "<script>alert(1)</script>\n\n<style>p { color: red }</style>\n\n<div>Kept<noscript>Enable JavaScript</noscript> text</div>",
+			want:   `[{"type":"paragraph","content":[{"type":"text","text":"Kept text"}]}]`,
+		},
+		{
+			name:   "translate drops inline hidden content",
+			policy: HTMLTranslate,
+			input:  "Before <script>alert(*1*)</script> after <iframe src=\"x\">frame</iframe>",
+			want:   `[{"type":"paragraph","content":[{"type":"text","text":"Before  after "}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input), WithHTMLPolicy(tt.policy))
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
 	}
-	return buf.Bytes(), nil
 }
 
-func TestConvert_Image_DefaultFallback(t *testing.T) {
-	// By default, images are converted to text with link marks
-	input := []byte("![Alt text](https://example.com/image.png)")
-	output, err := Convert(input)
+func TestConvertWithReport_HTMLBlockFlattened(t *testing.T) {
+	input := []byte("- item\n\n  <h2>Title</h2>\n\n> text\n>\n> <hr>")
+	_, diagnostics, err := ConvertWithReport(input, WithHTMLPolicy(HTMLTranslate))
+	if err != nil {
+		t.Fatalf("ConvertWithReport failed: %v", err)
+	}
+
+	want := []Diagnostic{
+		{SeverityWarning, DiagnosticHTMLBlockFlattened, "listItem cannot contain heading; rendered as a bold paragraph", 3, 3},
+		{SeverityWarning, DiagnosticHTMLBlockFlattened, "blockquote cannot contain rule; dropped", 7, 3},
+	}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, diagnostics)
+	}
+}
+
+func TestConvertWithReport(t *testing.T) {
+	input := []byte("# Title\n\nSee ![diagram](d.png) and **`x`**.\n\n<div>\nhi\n</div>\n\n> <details><summary>S</summary>body</details>\n\n- [ ] task\n\n      code\n\nä <span>a</span> <!-- comment -->\n")
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []Diagnostic
+	}{
+		{
+			name: "defaults",
+			want: []Diagnostic{
+				{SeverityWarning, DiagnosticImageAsLink, `image "d.png" rendered as a link; enable external media to embed it`, 3, 7},
+				{SeverityWarning, DiagnosticMarkConflict, `marks strong, code cannot be combined on "x"; kept code`, 3, 30},
+				{SeverityWarning, DiagnosticHTMLDropped, `raw HTML "<div>…" dropped`, 5, 1},
+				{SeverityInfo, DiagnosticDetailsFlattened, "<details> cannot be an expand here; rendered as a bold summary and its content", 9, 3},
+				{SeverityWarning, DiagnosticTaskItemAsText, "task item holds content a taskItem cannot contain; rendered with a text checkbox", 11, 3},
+				{SeverityWarning, DiagnosticHTMLDropped, `raw HTML "<span>" dropped`, 15, 3},
+				{SeverityWarning, DiagnosticHTMLDropped, `raw HTML "</span>" dropped`, 15, 10},
+			},
+		},
+		{
+			name: "translate html and drop code",
+			opts: []Option{WithExternalMedia(true), WithHTMLPolicy(HTMLTranslate), WithMarkConflicts(MarkConflictDropCode)},
+			want: []Diagnostic{
+				{SeverityWarning, DiagnosticMarkConflict, `marks strong, code cannot be combined on "x"; dropped code`, 3, 30},
+				{SeverityInfo, DiagnosticDetailsFlattened, "<details> cannot be an expand here; rendered as a bold summary and its content", 9, 3},
+				{SeverityWarning, DiagnosticTaskItemAsText, "task item holds content a taskItem cannot contain; rendered with a text checkbox", 11, 3},
+				{SeverityWarning, DiagnosticHTMLTagRemoved, "HTML tag <span> has no ADF equivalent; removed", 15, 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, diagnostics, err := ConvertWithReport(input, tt.opts...)
+			if err != nil {
+				t.Fatalf("ConvertWithReport failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+			if !reflect.DeepEqual(diagnostics, tt.want) {
+				t.Errorf("Expected diagnostics:\n%v\ngot:\n%v", tt.want, diagnostics)
+			}
+		})
+	}
+}
+
+func TestConvertWithReport_Error(t *testing.T) {
+	_, diagnostics, err := ConvertWithReport([]byte("ok\n\n~~`x`~~"), WithMarkConflicts(MarkConflictFail))
+	var conflict *MarkConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Expected MarkConflictError, got %v", err)
+	}
+
+	want := []Diagnostic{{SeverityError, DiagnosticMarkConflict, `marks strike, code cannot be combined on "x"`, 3, 4}}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, diagnostics)
+	}
+	if got := diagnostics[0].String(); got != `3:4: error: marks strike, code cannot be combined on "x" (mark-conflict)` {
+		t.Errorf("Unexpected String() %q", got)
+	}
+}
+
+func TestConvert_Mentions(t *testing.T) {
+	data, err := os.ReadFile("testdata/mentions.json")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	var directory MentionDirectory
+	if err := json.Unmarshal(data, &directory); err != nil {
+		t.Fatalf("Failed to parse directory: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{
+			name:  "account id",
+			input: "Thanks @[Alice Smith](accountId:5b10ac8d82e05b22cc7d4ef5)!",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Thanks "},{"type":"mention","attrs":{"id":"5b10ac8d82e05b22cc7d4ef5","text":"@Alice Smith"}},{"type":"text","text":"!"}]}]`,
+		},
+		{
+			name:  "username without resolver",
+			input: "Thanks @alice",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Thanks @alice"}]}]`,
+		},
+		{
+			name:  "resolved usernames",
+			opts:  []Option{WithMentionResolver(directory)},
+			input: "cc @alice, @bob.jones and @release-bot.",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"cc "},{"type":"mention","attrs":{"id":"5b10ac8d82e05b22cc7d4ef5","text":"@Alice Smith"}},{"type":"text","text":", "},{"type":"mention","attrs":{"accessLevel":"CONTAINER","id":"557058:f58131cb-b67d-43c7-b30d-6b58d40bd077","text":"@bob.jones","userType":"DEFAULT"}},{"type":"text","text":" and "},{"type":"mention","attrs":{"id":"5d53f3cbc6b9320d9ea5bdc2","text":"@Release Bot","userType":"APP"}},{"type":"text","text":"."}]}]`,
+		},
+		{
+			name:  "unknown username",
+			opts:  []Option{WithMentionResolver(directory)},
+			input: "**@carol** reviewed",
+			want:  `[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"@carol"},{"type":"text","text":" reviewed"}]}]`,
+		},
+		{
+			name:  "email and code are not mentions",
+			opts:  []Option{WithMentionResolver(directory)},
+			input: "Mail alice@example.com or run `@alice`",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Mail "},{"type":"text","marks":[{"type":"link","attrs":{"href":"alice@example.com"}}],"text":"alice@example.com"},{"type":"text","text":" or run "},{"type":"text","marks":[{"type":"code"}],"text":"@alice"}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvert_MentionResolverError(t *testing.T) {
+	errLookup := errors.New("directory unavailable")
+	resolver := MentionResolverFunc(func(username string) (*MentionUser, error) {
+		return nil, errLookup
+	})
+
+	_, diagnostics, err := ConvertWithReport([]byte("Hi @alice"), WithMentionResolver(resolver))
+	if !errors.Is(err, errLookup) {
+		t.Fatalf("Expected resolver error, got %v", err)
+	}
+	if len(diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", diagnostics)
+	}
+
+	_, diagnostics, err = ConvertWithReport([]byte("Hi\n@alice"), WithMentionResolver(MentionDirectory{}))
+	if err != nil {
+		t.Fatalf("ConvertWithReport failed: %v", err)
+	}
+	want := []Diagnostic{{SeverityWarning, DiagnosticMentionUnresolved, "mention @alice could not be resolved; rendered as text", 2, 1}}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, diagnostics)
+	}
+}
+
+func TestConvertWithGFM_Emoji(t *testing.T) {
+	custom := WithCustomEmoji(map[string]Emoji{
+		":party-parrot:": {ID: "9a3f1b2c-5d6e-4f70-8a9b-0c1d2e3f4a5b"},
+		"rocket":         {ID: "atlassian-rocket", Text: "🚀"},
+	})
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{
+			name:  "standard",
+			input: "Shipped :rocket: :white_check_mark:",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Shipped "},{"type":"emoji","attrs":{"id":"1f680","shortName":":rocket:","text":"🚀"}},{"type":"text","text":" "},{"type":"emoji","attrs":{"id":"2705","shortName":":white_check_mark:","text":"✅"}}]}]`,
+		},
+		{
+			name:  "sequence",
+			input: "**:+1:**:man_technologist:",
+			want:  `[{"type":"paragraph","content":[{"type":"emoji","attrs":{"id":"1f44d","shortName":":+1:","text":"👍"}},{"type":"emoji","attrs":{"id":"1f468-200d-1f4bb","shortName":":man_technologist:","text":"👨‍💻"}}]}]`,
+		},
+		{
+			name:  "unknown and embedded",
+			input: "Time 12:30:45 and :not_an_emoji: or foo:smile:",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Time 12:30:45 and :not_an_emoji: or foo:smile:"}]}]`,
+		},
+		{
+			name:  "custom",
+			opts:  []Option{custom},
+			input: ":party-parrot: :rocket:",
+			want:  `[{"type":"paragraph","content":[{"type":"emoji","attrs":{"id":"9a3f1b2c-5d6e-4f70-8a9b-0c1d2e3f4a5b","shortName":":party-parrot:"}},{"type":"text","text":" "},{"type":"emoji","attrs":{"id":"atlassian-rocket","shortName":":rocket:","text":"🚀"}}]}]`,
+		},
+		{
+			name:  "code",
+			input: "`:rocket:`",
+			want:  `[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"code"}],"text":":rocket:"}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvert_Status(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{
+			name:  "default syntax",
+			input: "State: {status:In Progress|blue} and {status: Done | Green }",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"State: "},{"type":"status","attrs":{"color":"blue","localId":"00000000-0000-4000-8000-000000000001","text":"In Progress"}},{"type":"text","text":" and "},{"type":"status","attrs":{"color":"green","localId":"00000000-0000-4000-8000-000000000002","text":"Done"}}]}]`,
+		},
+		{
+			name:  "no color",
+			input: "{status:Blocked}",
+			want:  `[{"type":"paragraph","content":[{"type":"status","attrs":{"color":"neutral","localId":"00000000-0000-4000-8000-000000000001","text":"Blocked"}}]}]`,
+		},
+		{
+			name:  "not a status",
+			input: "{status:} {status:open",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"{status:} {status:open"}]}]`,
+		},
+		{
+			name:  "table cell",
+			input: "| Release | State |\n|---|---|\n| 1.0 | {status:Shipped\\|green} |",
+			want:  `[{"type":"table","attrs":{"isNumberColumnEnabled":false,"layout":"default"},"content":[{"type":"tableRow","content":[{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"Release"}]}]},{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"State"}]}]}]},{"type":"tableRow","content":[{"type":"tableCell","content":[{"type":"paragraph","content":[{"type":"text","text":"1.0"}]}]},{"type":"tableCell","content":[{"type":"paragraph","content":[{"type":"status","attrs":{"color":"green","localId":"00000000-0000-4000-8000-000000000001","text":"Shipped"}}]}]}]}]}]`,
+		},
+		{
+			name:  "custom syntax",
+			opts:  []Option{WithStatusSyntax(StatusSyntax{Open: "[[", Separator: ":", Close: "]]"})},
+			input: "[[Review:purple]] {status:Old|red}",
+			want:  `[{"type":"paragraph","content":[{"type":"status","attrs":{"color":"purple","localId":"00000000-0000-4000-8000-000000000001","text":"Review"}},{"type":"text","text":" {status:Old|red}"}]}]`,
+		},
+		{
+			name:  "disabled",
+			opts:  []Option{WithStatusSyntax(StatusSyntax{})},
+			input: "{status:Done|green}",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"{status:Done|green}"}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvert_StatusInvalidColor(t *testing.T) {
+	output, diagnostics, err := ConvertWithReport([]byte("Ship it\n\n{status:Done|orange}"))
+	if err != nil {
+		t.Fatalf("ConvertWithReport failed: %v", err)
+	}
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+	if !bytes.Contains(output, []byte(`"color": "neutral"`)) {
+		t.Errorf("Expected a neutral status, got %s", output)
+	}
+
+	want := []Diagnostic{{SeverityWarning, DiagnosticStatusColorInvalid, `status color "orange" is not one of neutral, purple, blue, red, yellow, green; used neutral`, 3, 1}}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, diagnostics)
+	}
+}
+
+func TestConvert_Date(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{name: "iso date", input: "{date:2026-11-01}", want: "1793491200000"},
+		{name: "spaces", input: "{date: 2026-11-01 }", want: "1793491200000"},
+		{name: "rfc 3339", input: "{date:2026-11-01T09:30:00+02:00}", want: "1793518200000"},
+		{name: "slashes", input: "{date:2026/03/05}", want: "1772668800000"},
+		{name: "day month year", input: "{date:5 Mar 2026}", want: "1772668800000"},
+		{name: "month day year", input: "{date:March 5, 2026}", want: "1772668800000"},
+		{name: "location", opts: []Option{WithDateLocation(time.FixedZone("UTC-4", -4*60*60))}, input: "{date:2026-11-01}", want: "1793505600000"},
+		{name: "location ignored with offset", opts: []Option{WithDateLocation(time.FixedZone("UTC-4", -4*60*60))}, input: "{date:2026-11-01T09:30:00+02:00}", want: "1793518200000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte("Due "+tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			para := doc.Content[0]
+			if len(para.Content) != 2 || para.Content[1].Type != "date" {
+				t.Fatalf("Expected text and date, got %+v", para.Content)
+			}
+			if got := para.Content[1].Attrs["timestamp"]; got != tt.want {
+				t.Errorf("Expected timestamp %s, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvert_DateInvalid(t *testing.T) {
+	output, diagnostics, err := ConvertWithReport([]byte("Due *{date:2026-13-45}*"))
+	if err != nil {
+		t.Fatalf("ConvertWithReport failed: %v", err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	text := doc.Content[0].Content[1]
+	if text.Type != "text" || text.Text != "{date:2026-13-45}" || !hasMark(text.Marks, "em") {
+		t.Errorf("Expected the macro as emphasised text, got %+v", text)
+	}
+
+	want := []Diagnostic{{SeverityWarning, DiagnosticDateInvalid, `date "2026-13-45" is not in a recognised format; rendered as text`, 1, 6}}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, diagnostics)
+	}
+}
+
+func TestNewDate(t *testing.T) {
+	n := NewDate(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC))
+	if n.Type != "date" || n.Attrs["timestamp"] != "1793491200000" {
+		t.Errorf("Unexpected date node %+v", n)
+	}
+}
+
+func TestConvertWithGFM_DecisionList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "decisions",
+			input: "- [D] We will use **Postgres**\n- [d] Hosting\n  still open",
+			want:  `[{"type":"decisionList","attrs":{"localId":"00000000-0000-4000-8000-000000000001"},"content":[{"type":"decisionItem","attrs":{"localId":"00000000-0000-4000-8000-000000000002","state":"DECIDED"},"content":[{"type":"text","text":"We will use "},{"type":"text","marks":[{"type":"strong"}],"text":"Postgres"}]},{"type":"decisionItem","attrs":{"localId":"00000000-0000-4000-8000-000000000003","state":"UNDECIDED"},"content":[{"type":"text","text":"Hosting still open"}]}]}]`,
+		},
+		{
+			name:  "mixed with tasks",
+			input: "- [D] Decided\n- [ ] Follow up\n- plain",
+			want:  `[{"type":"decisionList","attrs":{"localId":"00000000-0000-4000-8000-000000000001"},"content":[{"type":"decisionItem","attrs":{"localId":"00000000-0000-4000-8000-000000000002","state":"DECIDED"},"content":[{"type":"text","text":"Decided"}]}]},{"type":"taskList","attrs":{"localId":"00000000-0000-4000-8000-000000000003"},"content":[{"type":"taskItem","attrs":{"localId":"00000000-0000-4000-8000-000000000004","state":"TODO"},"content":[{"type":"text","text":"Follow up"}]}]},{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"plain"}]}]}]}]`,
+		},
+		{
+			name:  "not a marker",
+			input: "- [D]efinitely\n- text [D] later",
+			want:  `[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"[D]efinitely"}]}]},{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"text [D] later"}]}]}]}]`,
+		},
+		{
+			name:  "nested list falls back",
+			input: "- [D] Parent\n  - child",
+			want:  `[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"[D] Parent"}]},{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"child"}]}]}]}]}]}]`,
+		},
+		{
+			name:  "nested in a list item",
+			input: "- top\n  - [D] nested",
+			want:  `[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"top"}]},{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"[D] nested"}]}]}]}]}]}]`,
+		},
+		{
+			name:  "in a blockquote",
+			input: "> - [d] open",
+			want:  `[{"type":"blockquote","content":[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"[d] open"}]}]}]}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertWithGFM_SmartLinks(t *testing.T) {
+	rules := WithSmartLinks(
+		SmartLinkRule{Host: "*.atlassian.net", Inline: SmartLinkInline, Standalone: SmartLinkBlock},
+		SmartLinkRule{Host: "www.youtube.com", Standalone: SmartLinkEmbed},
+	)
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{
+			name:  "no rules",
+			input: "https://acme.atlassian.net/browse/ABC-1",
+			want:  `[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"link","attrs":{"href":"https://acme.atlassian.net/browse/ABC-1"}}],"text":"https://acme.atlassian.net/browse/ABC-1"}]}]`,
+		},
+		{
+			name:  "standalone block card",
+			opts:  []Option{rules},
+			input: "Before\n\n<https://acme.atlassian.net/browse/ABC-1>\n\nAfter",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Before"}]},{"type":"blockCard","attrs":{"url":"https://acme.atlassian.net/browse/ABC-1"}},{"type":"paragraph","content":[{"type":"text","text":"After"}]}]`,
+		},
+		{
+			name:  "standalone embed card",
+			opts:  []Option{rules},
+			input: "https://www.youtube.com/watch?v=abc",
+			want:  `[{"type":"embedCard","attrs":{"layout":"center","url":"https://www.youtube.com/watch?v=abc"}}]`,
+		},
+		{
+			name:  "inline card",
+			opts:  []Option{rules},
+			input: "See https://ACME.atlassian.net/browse/ABC-1 and https://www.youtube.com/watch?v=abc",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"See "},{"type":"inlineCard","attrs":{"url":"https://ACME.atlassian.net/browse/ABC-1"}},{"type":"text","text":" and "},{"type":"text","marks":[{"type":"link","attrs":{"href":"https://www.youtube.com/watch?v=abc"}}],"text":"https://www.youtube.com/watch?v=abc"}]}]`,
+		},
+		{
+			name:  "explicit link",
+			opts:  []Option{rules},
+			input: "[ABC-1](https://acme.atlassian.net/browse/ABC-1)",
+			want:  `[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"link","attrs":{"href":"https://acme.atlassian.net/browse/ABC-1"}}],"text":"ABC-1"}]}]`,
+		},
+		{
+			name:  "list item falls back to inline card",
+			opts:  []Option{rules},
+			input: "- https://acme.atlassian.net/browse/ABC-1",
+			want:  `[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"inlineCard","attrs":{"url":"https://acme.atlassian.net/browse/ABC-1"}}]}]}]}]`,
+		},
+		{
+			name:  "task item falls back to inline card",
+			opts:  []Option{rules},
+			input: "- [ ] https://acme.atlassian.net/browse/ABC-1",
+			want:  `[{"type":"taskList","attrs":{"localId":"00000000-0000-4000-8000-000000000001"},"content":[{"type":"taskItem","attrs":{"localId":"00000000-0000-4000-8000-000000000002","state":"TODO"},"content":[{"type":"inlineCard","attrs":{"url":"https://acme.atlassian.net/browse/ABC-1"}}]}]}]`,
+		},
+		{
+			name:  "table cell",
+			opts:  []Option{rules},
+			input: "| Issue |\n| --- |\n| https://acme.atlassian.net/browse/ABC-1 |",
+			want:  `[{"type":"table","attrs":{"isNumberColumnEnabled":false,"layout":"default"},"content":[{"type":"tableRow","content":[{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"Issue"}]}]}]},{"type":"tableRow","content":[{"type":"tableCell","content":[{"type":"blockCard","attrs":{"url":"https://acme.atlassian.net/browse/ABC-1"}}]}]}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertWithGFM_Layout(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "two columns",
+			input: ":::columns\n:::column width=50\n**Before**\n:::\n:::column width=50%\n**After**\n\n- one\n- two\n:::\n:::\n\nAfter the layout",
+			want:  `[{"type":"layoutSection","content":[{"type":"layoutColumn","attrs":{"width":50},"content":[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"Before"}]}]},{"type":"layoutColumn","attrs":{"width":50},"content":[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"After"}]},{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"one"}]}]},{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"two"}]}]}]}]}]},{"type":"paragraph","content":[{"type":"text","text":"After the layout"}]}]`,
+		},
+		{
+			name:  "widths shared equally",
+			input: ":::columns\n:::column\nA\n:::\n:::column\nB\n:::\n:::column\nC\n:::\n:::",
+			want:  `[{"type":"layoutSection","content":[{"type":"layoutColumn","attrs":{"width":33.33},"content":[{"type":"paragraph","content":[{"type":"text","text":"A"}]}]},{"type":"layoutColumn","attrs":{"width":33.33},"content":[{"type":"paragraph","content":[{"type":"text","text":"B"}]}]},{"type":"layoutColumn","attrs":{"width":33.33},"content":[{"type":"paragraph","content":[{"type":"text","text":"C"}]}]}]}]`,
+		},
+		{
+			name:  "remaining width",
+			input: ":::columns\n:::column width=25\nA\n:::\n:::column\nB\n:::\n:::",
+			want:  `[{"type":"layoutSection","content":[{"type":"layoutColumn","attrs":{"width":25},"content":[{"type":"paragraph","content":[{"type":"text","text":"A"}]}]},{"type":"layoutColumn","attrs":{"width":75},"content":[{"type":"paragraph","content":[{"type":"text","text":"B"}]}]}]}]`,
+		},
+		{
+			name:  "fence in code block and empty column",
+			input: ":::columns\n:::column\n```\n:::\n```\n:::\n:::column\n:::\n:::",
+			want:  `[{"type":"layoutSection","content":[{"type":"layoutColumn","attrs":{"width":50},"content":[{"type":"codeBlock","content":[{"type":"text","text":":::\n"}]}]},{"type":"layoutColumn","attrs":{"width":50},"content":[{"type":"paragraph"}]}]}]`,
+		},
+		{
+			name:  "details in a column",
+			input: ":::columns\n:::column\n<details>\n<summary>More</summary>\n\nHidden\n\n</details>\n\n:::\n:::column\nB\n:::\n:::",
+			want:  `[{"type":"layoutSection","content":[{"type":"layoutColumn","attrs":{"width":50},"content":[{"type":"expand","attrs":{"title":"More"},"content":[{"type":"paragraph","content":[{"type":"text","text":"Hidden"}]}]}]},{"type":"layoutColumn","attrs":{"width":50},"content":[{"type":"paragraph","content":[{"type":"text","text":"B"}]}]}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertWithGFM_Containers(t *testing.T) {
+	quote := WithContainers(map[string]ContainerHandler{
+		"quote": func(c ContainerContext) (*Node, error) {
+			return NewBlockquote(), nil
+		},
+		"warning": func(c ContainerContext) (*Node, error) {
+			return NewPanel("warning"), nil
+		},
+		"panel": nil,
+	})
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{
+			name:  "panel",
+			input: ":::panel\nDefaults to info\n:::\n\n:::panel {panelType=warning panelIconText=\"⚠ Careful\"}\n# Heading\n\nText\n:::",
+			want:  `[{"type":"panel","attrs":{"panelType":"info"},"content":[{"type":"paragraph","content":[{"type":"text","text":"Defaults to info"}]}]},{"type":"panel","attrs":{"panelIconText":"⚠ Careful","panelType":"warning"},"content":[{"type":"heading","attrs":{"level":1},"content":[{"type":"text","text":"Heading"}]},{"type":"paragraph","content":[{"type":"text","text":"Text"}]}]}]`,
+		},
+		{
+			name:  "nested expands",
+			input: "::::expand {title='Outer'}\n:::expand {title=\"Inner \\\"quoted\\\"\"}\n:::\n::::",
+			want:  `[{"type":"expand","attrs":{"title":"Outer"},"content":[{"type":"nestedExpand","attrs":{"title":"Inner \"quoted\""},"content":[{"type":"paragraph"}]}]}]`,
+		},
+		{
+			name:  "details in an expand container",
+			input: ":::expand {title=Outer}\n<details>\n<summary>Inner</summary>\n\nText\n\n</details>\n\n:::",
+			want:  `[{"type":"expand","attrs":{"title":"Outer"},"content":[{"type":"nestedExpand","attrs":{"title":"Inner"},"content":[{"type":"paragraph","content":[{"type":"text","text":"Text"}]}]}]}]`,
+		},
+		{
+			name:  "bodied extension",
+			input: ":::bodiedExtension {extensionType=com.atlassian.confluence.macro.core extensionKey=details layout=wide}\nOwner: Jane\n:::",
+			want:  `[{"type":"bodiedExtension","attrs":{"extensionKey":"details","extensionType":"com.atlassian.confluence.macro.core","layout":"wide"},"content":[{"type":"paragraph","content":[{"type":"text","text":"Owner: Jane"}]}]}]`,
+		},
+		{
+			name:  "layout by ADF names",
+			input: ":::layoutSection\n:::layoutColumn {width=70}\nA\n:::\n:::layoutColumn {width=30}\nB\n:::\n:::",
+			want:  `[{"type":"layoutSection","content":[{"type":"layoutColumn","attrs":{"width":70},"content":[{"type":"paragraph","content":[{"type":"text","text":"A"}]}]},{"type":"layoutColumn","attrs":{"width":30},"content":[{"type":"paragraph","content":[{"type":"text","text":"B"}]}]}]}]`,
+		},
+		{
+			name:  "custom handlers",
+			opts:  []Option{quote},
+			input: ":::quote\nQuoted\n:::\n\n:::warning\nCareful\n:::\n\n:::panel\nNot a container\n:::",
+			want:  `[{"type":"blockquote","content":[{"type":"paragraph","content":[{"type":"text","text":"Quoted"}]}]},{"type":"panel","attrs":{"panelType":"warning"},"content":[{"type":"paragraph","content":[{"type":"text","text":"Careful"}]}]},{"type":"paragraph","content":[{"type":"text","text":":::panel Not a container :::"}]}]`,
+		},
+		{
+			name:  "unknown name",
+			input: ":::note\nText\n:::",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":":::note Text :::"}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertWithGFM_ContainerErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "one column",
+			input: ":::columns\n:::column\nA\n:::\n:::",
+			want:  "adf: 1:1: :::columns: layoutSection must contain 2 or 3 layoutColumn nodes, found 1",
+		},
+		{
+			name:  "four columns",
+			input: ":::columns\n:::column\nA\n:::\n:::column\nB\n:::\n:::column\nC\n:::\n:::column\nD\n:::\n:::",
+			want:  "adf: 1:1: :::columns: layoutSection must contain 2 or 3 layoutColumn nodes, found 4",
+		},
+		{
+			name:  "widths over 100",
+			input: ":::columns\n:::column width=60\nA\n:::\n:::column width=50\nB\n:::\n:::",
+			want:  "adf: 1:1: :::columns: column widths add up to 110, not 100",
+		},
+		{
+			name:  "no width left",
+			input: ":::columns\n:::column width=100\nA\n:::\n:::column\nB\n:::\n:::",
+			want:  "adf: 1:1: :::columns: column widths add up to 100, leaving no room for the columns without a width",
+		},
+		{
+			name:  "invalid width",
+			input: ":::columns\n:::column width=half\nA\n:::\n:::column\nB\n:::\n:::",
+			want:  `adf: 2:1: :::column: column width "half" is not a number between 0 and 100`,
+		},
+		{
+			name:  "content outside columns",
+			input: ":::columns\nIntro\n\n:::column\nA\n:::\n:::",
+			want:  "adf: 1:1: :::columns: layoutSection cannot contain paragraph",
+		},
+		{
+			name:  "nested layout",
+			input: "- :::columns\n  :::column\n  A\n  :::\n  :::column\n  B\n  :::\n  :::",
+			want:  "adf: 1:3: :::columns: listItem cannot contain layoutSection",
+		},
+		{
+			name:  "column without layout",
+			input: ":::column\nA\n:::",
+			want:  "adf: 1:1: :::column: doc cannot contain layoutColumn",
+		},
+		{
+			name:  "content not allowed in a panel",
+			input: ":::panel\n| a |\n| - |\n| b |\n:::",
+			want:  "adf: 1:1: :::panel: panel cannot contain table",
+		},
+		{
+			name:  "invalid panel type",
+			input: ":::panel {panelType=danger}\nText\n:::",
+			want:  `adf: 1:1: :::panel: panelType "danger" is not one of info, note, tip, warning, error, success, custom`,
+		},
+		{
+			name:  "unknown attribute",
+			input: ":::expand {title=Logs colour=red}\nText\n:::",
+			want:  `adf: 1:1: :::expand: unknown attribute "colour"; allowed: title, localId`,
+		},
+		{
+			name:  "malformed attribute",
+			input: ":::expand {title=\"Logs}\nText\n:::",
+			want:  `adf: 1:1: :::expand: invalid attribute "title=\"Logs", expected name=value`,
+		},
+		{
+			name:  "missing extension key",
+			input: ":::bodiedExtension {extensionType=com.example}\nText\n:::",
+			want:  `adf: 1:1: :::bodiedExtension: attribute "extensionKey" is required`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, diagnostics, err := ConvertWithReport([]byte(tt.input))
+			var containerErr *ContainerError
+			if !errors.As(err, &containerErr) {
+				t.Fatalf("Expected ContainerError, got %v", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Expected error %q, got %q", tt.want, err)
+			}
+			if len(diagnostics) != 1 || diagnostics[0].Severity != SeverityError || diagnostics[0].Code != DiagnosticContainerInvalid || !strings.HasSuffix(err.Error(), diagnostics[0].Message) {
+				t.Errorf("Unexpected diagnostics %v", diagnostics)
+			}
+		})
+	}
+}
+
+func TestConvertWithGFM_Macros(t *testing.T) {
+	custom := WithMacros(map[string]Macro{
+		"roadmap": {ExtensionType: "com.example.roadmap", ExtensionKey: "roadmap-planner", Layout: "full-width"},
+		"toc":     {Layout: "wide"},
+	})
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{
+			name:  "extension",
+			input: "```confluence-macro toc\nmaxLevel: 3\nstyle: \"disc\" # bullets\n```",
+			want:  `[{"type":"extension","attrs":{"extensionKey":"toc","extensionType":"com.atlassian.confluence.macro.core","layout":"default","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"maxLevel":{"value":"3"},"style":{"value":"disc"}}}}}]`,
+		},
+		{
+			name:  "lists and layout",
+			input: "~~~confluence-macro jira {layout=full-width}\n# Open bugs\njqlQuery: 'project = ADF AND type = ''Bug'''\ncolumns: [key, summary, \"status\"]\nfields:\n  - priority\n  - assignee\n~~~",
+			want:  `[{"type":"extension","attrs":{"extensionKey":"jira","extensionType":"com.atlassian.confluence.macro.core","layout":"full-width","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"columns":{"value":"key,summary,status"},"fields":{"value":"priority,assignee"},"jqlQuery":{"value":"project = ADF AND type = 'Bug'"}}}}}]`,
+		},
+		{
+			name:  "bodied extension",
+			input: "````confluence-macro excerpt\nhidden: true\n---\nThe **short** version.\n\n```go\nfmt.Println()\n```\n````",
+			want:  `[{"type":"bodiedExtension","attrs":{"extensionKey":"excerpt","extensionType":"com.atlassian.confluence.macro.core","layout":"default","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"hidden":{"value":"true"}}}},"content":[{"type":"paragraph","content":[{"type":"text","text":"The "},{"type":"text","marks":[{"type":"strong"}],"text":"short"},{"type":"text","text":" version."}]},{"type":"codeBlock","attrs":{"language":"go"},"content":[{"type":"text","text":"fmt.Println()\n"}]}]}]`,
+		},
+		{
+			name:  "inline extension",
+			input: "```confluence-macro anchor\nname: top\n```",
+			want:  `[{"type":"paragraph","content":[{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"name":{"value":"top"}}}}}]}]`,
+		},
+		{
+			name:  "quoted names",
+			input: "```confluence-macro anchor\n'': top\n\"a: b\" : c\n```",
+			want:  `[{"type":"paragraph","content":[{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"":{"value":"top"},"a: b":{"value":"c"}}}}}]}]`,
+		},
+		{
+			name:  "quoted list name",
+			input: "```confluence-macro anchor\n'':\n  - top\n  - bottom\n```",
+			want:  `[{"type":"paragraph","content":[{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"":{"value":"top,bottom"}}}}}]}]`,
+		},
+		{
+			name:  "registered macros",
+			opts:  []Option{custom},
+			input: "```confluence-macro roadmap\n```\n\n- ```confluence-macro toc\n  ```",
+			want:  `[{"type":"extension","attrs":{"extensionKey":"roadmap-planner","extensionType":"com.example.roadmap","layout":"full-width","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{}}}},{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"extension","attrs":{"extensionKey":"toc","extensionType":"com.atlassian.confluence.macro.core","layout":"wide","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{}}}}]}]}]`,
+		},
+		{
+			name:  "unknown macro",
+			input: "```confluence-macro gallery\ninclude: a.png\n```",
+			want:  `[{"type":"codeBlock","attrs":{"language":"confluence-macro"},"content":[{"type":"text","text":"include: a.png\n"}]}]`,
+		},
+		{
+			name:  "not a macro",
+			input: "```confluence-macro\nmaxLevel: 3\n```",
+			want:  `[{"type":"codeBlock","attrs":{"language":"confluence-macro"},"content":[{"type":"text","text":"maxLevel: 3\n"}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertWithReport_UnknownMacro(t *testing.T) {
+	_, diagnostics, err := ConvertWithReport([]byte("# Gallery\n\n```confluence-macro gallery\ninclude: a.png\n```"))
+	if err != nil {
+		t.Fatalf("ConvertWithReport failed: %v", err)
+	}
+
+	want := []Diagnostic{{SeverityWarning, DiagnosticMacroUnknown, `unknown macro "gallery"; rendered as a code block`, 3, 1}}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, diagnostics)
+	}
+}
+
+func TestConvertWithGFM_MacroErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+		code  string
+	}{
+		{
+			name:  "unknown macro",
+			opts:  []Option{WithUnknownMacros(UnknownMacroError)},
+			input: "Intro\n\n```confluence-macro gallery\ninclude: a.png\n```",
+			want:  "adf: 3:1: confluence-macro gallery: unknown macro",
+			code:  DiagnosticMacroUnknown,
+		},
+		{
+			name:  "unknown macro without parameters",
+			opts:  []Option{WithUnknownMacros(UnknownMacroError)},
+			input: "intro\n\n```confluence-macro foo\n```",
+			want:  "adf: 3:1: confluence-macro foo: unknown macro",
+			code:  DiagnosticMacroUnknown,
+		},
+		{
+			name:  "invalid layout without parameters",
+			input: "```confluence-macro toc {layout=bogus}\n```",
+			want:  `adf: 1:1: confluence-macro toc: layout "bogus" is not one of default, wide, full-width`,
+			code:  DiagnosticMacroInvalid,
+		},
+		{
+			name:  "malformed parameter",
+			input: "```confluence-macro toc\nmaxLevel 3\n```",
+			want:  "adf: 1:1: confluence-macro toc: parameter line 1: expected name: value",
+			code:  DiagnosticMacroInvalid,
+		},
+		{
+			name:  "quoted name without a colon",
+			input: "```confluence-macro toc\n'maxLevel' 3\n```",
+			want:  "adf: 1:1: confluence-macro toc: parameter line 1: expected name: value",
+			code:  DiagnosticMacroInvalid,
+		},
+		{
+			name:  "nested mapping",
+			input: "```confluence-macro jira\nserver:\n  name: System JIRA\n```",
+			want:  "adf: 1:1: confluence-macro jira: parameter line 2: nested mappings are not supported",
+			code:  DiagnosticMacroInvalid,
+		},
+		{
+			name:  "unterminated string",
+			input: "```confluence-macro toc\nstyle: \"disc\n```",
+			want:  `adf: 1:1: confluence-macro toc: parameter line 1: unterminated string "disc`,
+			code:  DiagnosticMacroInvalid,
+		},
+		{
+			name:  "duplicate parameter",
+			input: "```confluence-macro toc\nmaxLevel: 3\nmaxLevel: 4\n```",
+			want:  `adf: 1:1: confluence-macro toc: parameter "maxLevel" given twice`,
+			code:  DiagnosticMacroInvalid,
+		},
+		{
+			name:  "body without a bodied macro",
+			input: "```confluence-macro toc\n---\nText\n```",
+			want:  "adf: 1:1: confluence-macro toc: macro does not take a body",
+			code:  DiagnosticMacroInvalid,
+		},
+		{
+			name:  "invalid layout",
+			input: "```confluence-macro toc {layout=center}\nmaxLevel: 3\n```",
+			want:  `adf: 1:1: confluence-macro toc: layout "center" is not one of default, wide, full-width`,
+			code:  DiagnosticMacroInvalid,
+		},
+		{
+			name:  "inline layout",
+			input: "```confluence-macro anchor layout=wide\nname: top\n```",
+			want:  "adf: 1:1: confluence-macro anchor: inline macros have no layout",
+			code:  DiagnosticMacroInvalid,
+		},
+		{
+			name:  "bodied macro in a list",
+			input: "- ```confluence-macro excerpt\n  ---\n  Text\n  ```",
+			want:  "adf: 1:3: confluence-macro excerpt: listItem cannot contain bodiedExtension",
+			code:  DiagnosticMacroInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, diagnostics, err := ConvertWithReport([]byte(tt.input), tt.opts...)
+			var macroErr *MacroError
+			if !errors.As(err, &macroErr) {
+				t.Fatalf("Expected MacroError, got %v", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Expected error %q, got %q", tt.want, err)
+			}
+			if len(diagnostics) != 1 || diagnostics[0].Severity != SeverityError || diagnostics[0].Code != tt.code || !strings.HasSuffix(err.Error(), diagnostics[0].Message) {
+				t.Errorf("Unexpected diagnostics %v", diagnostics)
+			}
+		})
+	}
+}
+
+func TestConvertWithGFM_Footnotes(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{
+			name:  "footnote section",
+			input: "Markdown[^md] is *plain*[^2].\n\n[^md]: A markup language.\n[^2]: Mostly.",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Markdown"},{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"":{"value":"fnref-1"}}}}},{"type":"text","marks":[{"type":"link","attrs":{"href":"#fn-1"}},{"type":"subsup","attrs":{"type":"sup"}}],"text":"1"},{"type":"text","text":" is "},{"type":"text","marks":[{"type":"em"}],"text":"plain"},{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"":{"value":"fnref-2"}}}}},{"type":"text","marks":[{"type":"link","attrs":{"href":"#fn-2"}},{"type":"subsup","attrs":{"type":"sup"}}],"text":"2"},{"type":"text","text":"."}]},{"type":"rule"},{"type":"orderedList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"":{"value":"fn-1"}}}}},{"type":"text","text":"A markup language. "},{"type":"text","marks":[{"type":"link","attrs":{"href":"#fnref-1"}}],"text":"↩"}]}]},{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"":{"value":"fn-2"}}}}},{"type":"text","text":"Mostly. "},{"type":"text","marks":[{"type":"link","attrs":{"href":"#fnref-2"}}],"text":"↩"}]}]}]}]`,
+		},
+		{
+			name:  "repeated reference and block content",
+			input: "One[^a] and two[^a].\n\n[^a]: First paragraph.\n\n    ```\n    code\n    ```",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"One"},{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"":{"value":"fnref-1"}}}}},{"type":"text","marks":[{"type":"link","attrs":{"href":"#fn-1"}},{"type":"subsup","attrs":{"type":"sup"}}],"text":"1"},{"type":"text","text":" and two"},{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"":{"value":"fnref-1-2"}}}}},{"type":"text","marks":[{"type":"link","attrs":{"href":"#fn-1"}},{"type":"subsup","attrs":{"type":"sup"}}],"text":"1"},{"type":"text","text":"."}]},{"type":"rule"},{"type":"orderedList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"inlineExtension","attrs":{"extensionKey":"anchor","extensionType":"com.atlassian.confluence.macro.core","parameters":{"macroMetadata":{"schemaVersion":{"value":"1"}},"macroParams":{"":{"value":"fn-1"}}}}},{"type":"text","text":"First paragraph."}]},{"type":"codeBlock","content":[{"type":"text","text":"code\n"}]},{"type":"paragraph","content":[{"type":"text","marks":[{"type":"link","attrs":{"href":"#fnref-1"}}],"text":"↩"},{"type":"text","text":" "},{"type":"text","marks":[{"type":"link","attrs":{"href":"#fnref-1-2"}}],"text":"↩"}]}]}]}]`,
+		},
+		{
+			name:  "undefined reference",
+			input: "Missing[^x].",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Missing[^x]."}]}]`,
+		},
+		{
+			name:  "inline footnotes",
+			opts:  []Option{WithInlineFootnotes(true)},
+			input: "Markdown[^md] is **plain [^2]**.\n\n[^md]: A *markup* language.\n\n    Simple.\n[^2]: Mostly.",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Markdown (A "},{"type":"text","marks":[{"type":"em"}],"text":"markup"},{"type":"text","text":" language. Simple.) is "},{"type":"text","marks":[{"type":"strong"}],"text":"plain (Mostly.)"},{"type":"text","text":"."}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertWithReport_InlineFootnoteBlocks(t *testing.T) {
+	_, diagnostics, err := ConvertWithReport([]byte("Text[^1]\n\n[^1]: Note.\n\n    - item"), WithInlineFootnotes(true))
+	if err != nil {
+		t.Fatalf("ConvertWithReport failed: %v", err)
+	}
+
+	want := []Diagnostic{{SeverityWarning, DiagnosticFootnoteBlockDropped, "footnote 1 holds a List, which cannot be inlined; dropped", 5, 7}}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, diagnostics)
+	}
+}
+
+func TestConvertWithGFM_DefinitionLists(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		input string
+		want  string
+	}{
+		{
+			name:  "blockquote",
+			input: "Term `code`\n: First *meaning*.\n: Second meaning.",
+			want:  `[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"Term "},{"type":"text","marks":[{"type":"code"}],"text":"code"}]},{"type":"blockquote","content":[{"type":"paragraph","content":[{"type":"text","text":"First "},{"type":"text","marks":[{"type":"em"}],"text":"meaning"},{"type":"text","text":"."}]}]},{"type":"blockquote","content":[{"type":"paragraph","content":[{"type":"text","text":"Second meaning."}]}]}]`,
+		},
+		{
+			name:  "indented",
+			opts:  []Option{WithDefinitionLists(DefinitionListIndented)},
+			input: "Term\n: Meaning.\n\n    ```\n    code\n    ```",
+			want:  `[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"Term"}]},{"type":"paragraph","content":[{"type":"text","text":"Meaning."}],"marks":[{"type":"indentation","attrs":{"level":1}}]},{"type":"codeBlock","content":[{"type":"text","text":"code\n"}]}]`,
+		},
+		{
+			name:  "table",
+			opts:  []Option{WithDefinitionLists(DefinitionListTable)},
+			input: "One\nUno\n: The number 1.\n\nTwo\n: The number 2.\n: A pair.",
+			want:  `[{"type":"table","attrs":{"isNumberColumnEnabled":false,"layout":"default"},"content":[{"type":"tableRow","content":[{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"One"}]},{"type":"paragraph","content":[{"type":"text","text":"Uno"}]}]},{"type":"tableCell","content":[{"type":"paragraph","content":[{"type":"text","text":"The number 1."}]}]}]},{"type":"tableRow","content":[{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"Two"}]}]},{"type":"tableCell","content":[{"type":"paragraph","content":[{"type":"text","text":"The number 2."}]},{"type":"paragraph","content":[{"type":"text","text":"A pair."}]}]}]}]}]`,
+		},
+		{
+			name:  "bullet list",
+			opts:  []Option{WithDefinitionLists(DefinitionListBulletList)},
+			input: "One\n: The number 1.\n\nTwo\n: The number 2.",
+			want:  `[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"One"}]},{"type":"paragraph","content":[{"type":"text","text":"The number 1."}]}]},{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"Two"}]},{"type":"paragraph","content":[{"type":"text","text":"The number 2."}]}]}]}]`,
+		},
+		{
+			name:  "table in a layout column",
+			opts:  []Option{WithDefinitionLists(DefinitionListTable)},
+			input: ":::layoutSection\n:::column\nTerm\n: Meaning.\n:::\n:::column\nText\n:::\n:::",
+			want:  `[{"type":"layoutSection","content":[{"type":"layoutColumn","attrs":{"width":50},"content":[{"type":"table","attrs":{"isNumberColumnEnabled":false,"layout":"default"},"content":[{"type":"tableRow","content":[{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"Term"}]}]},{"type":"tableCell","content":[{"type":"paragraph","content":[{"type":"text","text":"Meaning."}]}]}]}]}]},{"type":"layoutColumn","attrs":{"width":50},"content":[{"type":"paragraph","content":[{"type":"text","text":"Text"}]}]}]}]`,
+		},
+		{
+			name:  "flattened in a list item",
+			input: "- Term\n  : Meaning.",
+			want:  `[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"Term"}]},{"type":"paragraph","content":[{"type":"text","text":"Meaning."}]}]}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := convertWithGFMOptions([]byte(tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertWithReport_DefinitionListFlattened(t *testing.T) {
+	_, diagnostics, err := ConvertWithReport([]byte("> Term\n> : Meaning."), WithDefinitionLists(DefinitionListIndented))
+	if err != nil {
+		t.Fatalf("ConvertWithReport failed: %v", err)
+	}
+
+	want := []Diagnostic{{SeverityWarning, DiagnosticDefinitionListFlattened, "blockquote cannot contain indented paragraphs; definition list rendered as paragraphs", 1, 3}}
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, diagnostics)
+	}
+}
+
+func TestConvert_CommonMarkOnly(t *testing.T) {
+	// New parses plain CommonMark; the extensions need NewWithGFM
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "details",
+			input: "<details>\n<summary>S</summary>\n\nbody\n\n</details>",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"body"}]}]`,
+		},
+		{
+			name:  "mention",
+			input: "Hi @[Alice](accountId:5b10ac8d82e05b22cc7d4ef5)",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Hi @"},{"type":"text","marks":[{"type":"link","attrs":{"href":"accountId:5b10ac8d82e05b22cc7d4ef5"}}],"text":"Alice"}]}]`,
+		},
+		{
+			name:  "emoji",
+			input: "Score :100: and a:smile:b",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Score :100: and a:smile:b"}]}]`,
+		},
+		{
+			name:  "status",
+			input: "Build {status:Done|green}",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Build {status:Done|green}"}]}]`,
+		},
+		{
+			name:  "date",
+			input: "Due {date:2026-11-01}",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":"Due {date:2026-11-01}"}]}]`,
+		},
+		{
+			name:  "containers",
+			input: ":::columns\n:::column\nLeft\n:::\n:::column\nRight\n:::\n:::",
+			want:  `[{"type":"paragraph","content":[{"type":"text","text":":::columns :::column Left ::: :::column Right ::: :::"}]}]`,
+		},
+		{
+			name:  "confluence macro",
+			input: "```confluence-macro toc\nmaxLevel: 3\n```",
+			want:  `[{"type":"codeBlock","attrs":{"language":"confluence-macro"},"content":[{"type":"text","text":"maxLevel: 3\n"}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := Convert([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNew_ReusableInstance(t *testing.T) {
+	md := New()
+
+	inputs := []string{
+		"# Hello",
+		"Paragraph",
+		"- List item",
+	}
+
+	for _, input := range inputs {
+		var buf bytes.Buffer
+		if err := md.Convert([]byte(input), &buf); err != nil {
+			t.Errorf("Convert failed for %q: %v", input, err)
+		}
+		if err := adfschema.Validate(buf.Bytes()); err != nil {
+			t.Errorf("Invalid ADF for %q: %v", input, err)
+		}
+	}
+}
+
+func TestNew_ConcurrentConvert(t *testing.T) {
+	const count = 3000
+
+	// Build distinct documents from the round-trip corpus
+	inputs := make([][]byte, count)
+	for i := range inputs {
+		inputs[i] = fmt.Appendf(nil, "%s\n\nDocument %d with **bold %d**\n\n%s",
+			markdownCorpus[i%len(markdownCorpus)], i, i, markdownCorpus[(i*7)%len(markdownCorpus)])
+	}
+
+	instances := []struct {
+		name string
+		md   goldmark.Markdown
+	}{
+		{"New", New(WithExternalMedia(true))},
+		{"NewWithGFM", NewWithGFM()},
+	}
+
+	for _, inst := range instances {
+		t.Run(inst.name, func(t *testing.T) {
+			want := make([][]byte, count)
+			for i, input := range inputs {
+				var buf bytes.Buffer
+				if err := inst.md.Convert(input, &buf); err != nil {
+					t.Fatalf("Convert failed for %q: %v", input, err)
+				}
+				want[i] = buf.Bytes()
+			}
+
+			var wg sync.WaitGroup
+			jobs := make(chan int)
+			for range 16 {
+				wg.Go(func() {
+					for i := range jobs {
+						var buf bytes.Buffer
+						if err := inst.md.Convert(inputs[i], &buf); err != nil {
+							t.Errorf("Convert failed for %q: %v", inputs[i], err)
+							continue
+						}
+						if !bytes.Equal(buf.Bytes(), want[i]) {
+							t.Errorf("Parallel output differs from serial output for %q\nserial:   %s\nparallel: %s", inputs[i], want[i], buf.Bytes())
+						}
+					}
+				})
+			}
+			for i := range inputs {
+				jobs <- i
+			}
+			close(jobs)
+			wg.Wait()
+		})
+	}
+}
+
+// convertWithOptions is a test helper that converts markdown with options
+func convertWithOptions(source []byte, opts ...Option) ([]byte, error) {
+	var buf bytes.Buffer
+	md := New(opts...)
+	if err := md.Convert(source, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func TestConvert_Image_DefaultFallback(t *testing.T) {
+	// By default, images are converted to text with link marks
+	input := []byte("![Alt text](https://example.com/image.png)")
+	output, err := Convert(input)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	// Should have a paragraph with text that has a link mark
+	if len(doc.Content) != 1 {
+		t.Fatalf("Expected 1 content node, got %d", len(doc.Content))
+	}
+	if doc.Content[0].Type != "paragraph" {
+		t.Errorf("Expected paragraph, got %s", doc.Content[0].Type)
+	}
+	if len(doc.Content[0].Content) != 1 {
+		t.Fatalf("Expected 1 child in paragraph, got %d", len(doc.Content[0].Content))
+	}
+	textNode := doc.Content[0].Content[0]
+	if textNode.Type != "text" {
+		t.Errorf("Expected text, got %s", textNode.Type)
+	}
+	if textNode.Text != "Alt text" {
+		t.Errorf("Expected text 'Alt text', got %s", textNode.Text)
+	}
+	if len(textNode.Marks) != 1 || textNode.Marks[0].Type != "link" {
+		t.Errorf("Expected link mark on text")
+	}
+}
+
+func TestConvert_Image_ExternalMedia_Simple(t *testing.T) {
+	input := []byte("![Alt text](https://example.com/image.png)")
+	output, err := convertWithOptions(input, WithExternalMedia(true))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	// Should have a mediaSingle containing a media node
+	if len(doc.Content) != 1 {
+		t.Fatalf("Expected 1 content node, got %d", len(doc.Content))
+	}
+	mediaSingle := doc.Content[0]
+	if mediaSingle.Type != "mediaSingle" {
+		t.Errorf("Expected mediaSingle, got %s", mediaSingle.Type)
+	}
+	if mediaSingle.Attrs["layout"] != "center" {
+		t.Errorf("Expected layout 'center', got %v", mediaSingle.Attrs["layout"])
+	}
+	if len(mediaSingle.Content) != 1 {
+		t.Fatalf("Expected 1 child in mediaSingle, got %d", len(mediaSingle.Content))
+	}
+	media := mediaSingle.Content[0]
+	if media.Type != "media" {
+		t.Errorf("Expected media, got %s", media.Type)
+	}
+	if media.Attrs["type"] != "external" {
+		t.Errorf("Expected type 'external', got %v", media.Attrs["type"])
+	}
+	if media.Attrs["url"] != "https://example.com/image.png" {
+		t.Errorf("Expected url 'https://example.com/image.png', got %v", media.Attrs["url"])
+	}
+	if media.Attrs["alt"] != "Alt text" {
+		t.Errorf("Expected alt 'Alt text', got %v", media.Attrs["alt"])
+	}
+}
+
+func TestConvert_Image_ExternalMedia_WithCaption(t *testing.T) {
+	input := []byte(`![Alt text](https://example.com/image.png "This is a caption")`)
+	output, err := convertWithOptions(input, WithExternalMedia(true))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	// Should have a mediaSingle with media + caption
+	if len(doc.Content) != 1 {
+		t.Fatalf("Expected 1 content node, got %d", len(doc.Content))
+	}
+	mediaSingle := doc.Content[0]
+	if mediaSingle.Type != "mediaSingle" {
+		t.Errorf("Expected mediaSingle, got %s", mediaSingle.Type)
+	}
+	if len(mediaSingle.Content) != 2 {
+		t.Fatalf("Expected 2 children in mediaSingle (media + caption), got %d", len(mediaSingle.Content))
+	}
+
+	// Check media
+	media := mediaSingle.Content[0]
+	if media.Type != "media" {
+		t.Errorf("Expected media, got %s", media.Type)
+	}
+
+	// Check caption
+	caption := mediaSingle.Content[1]
+	if caption.Type != "caption" {
+		t.Errorf("Expected caption, got %s", caption.Type)
+	}
+	if len(caption.Content) != 1 {
+		t.Fatalf("Expected 1 child in caption, got %d", len(caption.Content))
+	}
+	if caption.Content[0].Text != "This is a caption" {
+		t.Errorf("Expected caption text 'This is a caption', got %s", caption.Content[0].Text)
+	}
+}
+
+func TestConvert_Image_ExternalMedia_InlineParagraphSplitting(t *testing.T) {
+	input := []byte("Before image ![img](https://example.com/image.png) after image")
+	output, err := convertWithOptions(input, WithExternalMedia(true))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	// Should have: paragraph, mediaSingle, paragraph
+	if len(doc.Content) != 3 {
+		t.Fatalf("Expected 3 content nodes (para, media, para), got %d\nOutput: %s", len(doc.Content), output)
+	}
+
+	// First paragraph with "Before image "
+	if doc.Content[0].Type != "paragraph" {
+		t.Errorf("Expected first node to be paragraph, got %s", doc.Content[0].Type)
+	}
+	if len(doc.Content[0].Content) == 0 || doc.Content[0].Content[0].Text != "Before image " {
+		t.Errorf("Expected first paragraph to contain 'Before image ', got %v", doc.Content[0].Content)
+	}
+
+	// MediaSingle
+	if doc.Content[1].Type != "mediaSingle" {
+		t.Errorf("Expected second node to be mediaSingle, got %s", doc.Content[1].Type)
+	}
+
+	// Second paragraph with " after image"
+	if doc.Content[2].Type != "paragraph" {
+		t.Errorf("Expected third node to be paragraph, got %s", doc.Content[2].Type)
+	}
+	if len(doc.Content[2].Content) == 0 || doc.Content[2].Content[0].Text != " after image" {
+		t.Errorf("Expected second paragraph to contain ' after image', got %v", doc.Content[2].Content)
+	}
+}
+
+func TestConvert_Image_ExternalMedia_InList(t *testing.T) {
+	input := []byte("- Item with ![image](https://example.com/image.png) inline")
+	output, err := convertWithOptions(input, WithExternalMedia(true))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	// Should have bulletList -> listItem -> [para, mediaSingle, para]
+	if len(doc.Content) != 1 || doc.Content[0].Type != "bulletList" {
+		t.Fatalf("Expected bulletList, got %v", doc.Content)
+	}
+	bulletList := doc.Content[0]
+	if len(bulletList.Content) != 1 || bulletList.Content[0].Type != "listItem" {
+		t.Fatalf("Expected listItem, got %v", bulletList.Content)
+	}
+	listItem := bulletList.Content[0]
+
+	// List item should contain: paragraph, mediaSingle, paragraph
+	if len(listItem.Content) != 3 {
+		t.Fatalf("Expected 3 nodes in listItem, got %d\nOutput: %s", len(listItem.Content), output)
+	}
+	if listItem.Content[0].Type != "paragraph" {
+		t.Errorf("Expected first child to be paragraph, got %s", listItem.Content[0].Type)
+	}
+	if listItem.Content[1].Type != "mediaSingle" {
+		t.Errorf("Expected second child to be mediaSingle, got %s", listItem.Content[1].Type)
+	}
+	if listItem.Content[2].Type != "paragraph" {
+		t.Errorf("Expected third child to be paragraph, got %s", listItem.Content[2].Type)
+	}
+}
+
+func TestConvert_Image_ExternalMedia_InBlockquote(t *testing.T) {
+	input := []byte("> Quote with ![image](https://example.com/image.png)")
+	output, err := convertWithOptions(input, WithExternalMedia(true))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	// Should have blockquote -> [para, mediaSingle]
+	if len(doc.Content) != 1 || doc.Content[0].Type != "blockquote" {
+		t.Fatalf("Expected blockquote, got %v", doc.Content)
+	}
+	blockquote := doc.Content[0]
+
+	// Blockquote should contain: paragraph, mediaSingle
+	if len(blockquote.Content) != 2 {
+		t.Fatalf("Expected 2 nodes in blockquote, got %d\nOutput: %s", len(blockquote.Content), output)
+	}
+	if blockquote.Content[0].Type != "paragraph" {
+		t.Errorf("Expected first child to be paragraph, got %s", blockquote.Content[0].Type)
+	}
+	if blockquote.Content[1].Type != "mediaSingle" {
+		t.Errorf("Expected second child to be mediaSingle, got %s", blockquote.Content[1].Type)
+	}
+}
+
+func TestConvert_Image_ExternalMedia_CustomLayout(t *testing.T) {
+	input := []byte("![Alt text](https://example.com/image.png)")
+	output, err := convertWithOptions(input, WithExternalMedia(true), WithImageLayout("wide"))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	mediaSingle := doc.Content[0]
+	if mediaSingle.Attrs["layout"] != "wide" {
+		t.Errorf("Expected layout 'wide', got %v", mediaSingle.Attrs["layout"])
+	}
+}
+
+func TestConvert_Image_Handler_Inline(t *testing.T) {
+	handler := func(img ImageContext) *Node {
+		return NewTextWithMarks("["+img.Alt+"]", []Mark{NewLinkMark(img.Destination, "")})
+	}
+	input := []byte("See ![diagram](https://example.com/d.png) here")
+	output, err := convertWithOptions(input, WithExternalMedia(true), WithImageHandler(handler))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	// An inline node stays in the paragraph instead of splitting it
+	if len(doc.Content) != 1 || doc.Content[0].Type != "paragraph" {
+		t.Fatalf("Expected a single paragraph, got %v", doc.Content)
+	}
+	content := doc.Content[0].Content
+	if len(content) != 3 || content[1].Text != "[diagram]" || content[1].Marks[0].Attrs["href"] != "https://example.com/d.png" {
+		t.Errorf("Expected handler node between text nodes, got %v", content)
+	}
+}
+
+func TestConvert_Image_Handler_Block(t *testing.T) {
+	handler := func(img ImageContext) *Node {
+		media := NewMediaSingle("wide")
+		media.AppendChild(*NewExternalMedia("https://cdn.example.com/"+img.Destination, img.Alt))
+		return media
+	}
+	input := []byte("Before ![Alt](a.png) after")
+	output, err := convertWithOptions(input, WithImageHandler(handler))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	// A block node splits the paragraph, even without WithExternalMedia
+	if len(doc.Content) != 3 {
+		t.Fatalf("Expected 3 content nodes, got %d: %v", len(doc.Content), doc.Content)
+	}
+	if doc.Content[0].Type != "paragraph" || doc.Content[1].Type != "mediaSingle" || doc.Content[2].Type != "paragraph" {
+		t.Errorf("Expected paragraph, mediaSingle, paragraph, got %v", doc.Content)
+	}
+	if url := doc.Content[1].Content[0].Attrs["url"]; url != "https://cdn.example.com/a.png" {
+		t.Errorf("Expected handler URL, got %v", url)
+	}
+}
+
+func TestConvert_Image_Handler_Fallback(t *testing.T) {
+	calls := 0
+	handler := func(img ImageContext) *Node {
+		calls++
+		return nil
+	}
+	input := []byte("![Alt text](https://example.com/image.png)")
+	output, err := convertWithOptions(input, WithImageHandler(handler))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected handler to be called once, got %d", calls)
+	}
+	// Returning nil falls back to the default link rendering
+	textNode := doc.Content[0].Content[0]
+	if textNode.Text != "Alt text" || textNode.Marks[0].Type != "link" {
+		t.Errorf("Expected default link rendering, got %v", textNode)
+	}
+}
+
+func TestConvert_Image_Handler_Context(t *testing.T) {
+	var got []ImageContext
+	handler := func(img ImageContext) *Node {
+		got = append(got, img)
+		return nil
+	}
+	input := []byte(`![Alone](a.png "Title A")
+
+Text with ![inline](b.png) image
+
+- ![](c.png)
+
+> ![Quoted](d.png)
+
+| Header |
+|--------|
+| ![Cell](e.png) |`)
+	if _, err := convertWithGFMOptions(input, WithImageHandler(handler)); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	want := []ImageContext{
+		{Destination: "a.png", Alt: "Alone", Title: "Title A", Block: true, ParentType: "doc"},
+		{Destination: "b.png", Alt: "inline", Block: false, ParentType: "doc"},
+		{Destination: "c.png", Alt: "", Block: true, ParentType: "listItem"},
+		{Destination: "d.png", Alt: "Quoted", Block: true, ParentType: "blockquote"},
+		{Destination: "e.png", Alt: "Cell", Block: true, ParentType: "tableCell"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected image contexts\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestConvertWithGFM_TaskList(t *testing.T) {
+	input := []byte("- [x] Done item\n- [ ] Todo item")
+	output, err := ConvertWithGFM(input)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	if len(doc.Content) != 1 || doc.Content[0].Type != "taskList" {
+		t.Fatalf("Expected taskList, got %v", doc.Content)
+	}
+	taskList := doc.Content[0]
+	if taskList.Attrs["localId"] == "" {
+		t.Error("Expected taskList to have a localId")
+	}
+	if len(taskList.Content) != 2 {
+		t.Fatalf("Expected 2 task items, got %d", len(taskList.Content))
+	}
+
+	expected := []struct {
+		state string
+		text  string
+	}{
+		{"DONE", "Done item"},
+		{"TODO", "Todo item"},
+	}
+	seen := map[any]bool{taskList.Attrs["localId"]: true}
+	for i, want := range expected {
+		item := taskList.Content[i]
+		if item.Type != "taskItem" {
+			t.Fatalf("Expected taskItem, got %s", item.Type)
+		}
+		if item.Attrs["state"] != want.state {
+			t.Errorf("Expected state %s, got %v", want.state, item.Attrs["state"])
+		}
+		if seen[item.Attrs["localId"]] {
+			t.Errorf("Expected unique localId, got duplicate %v", item.Attrs["localId"])
+		}
+		seen[item.Attrs["localId"]] = true
+		// Task items hold inline content directly, without a paragraph
+		text := ""
+		for _, child := range item.Content {
+			if child.Type != "text" {
+				t.Errorf("Expected inline text content, got %s", child.Type)
+			}
+			text += child.Text
+		}
+		if text != want.text {
+			t.Errorf("Expected text %q, got %q", want.text, text)
+		}
+	}
+}
+
+func TestConvertWithGFM_TaskList_Mixed(t *testing.T) {
+	input := []byte("- [x] Task one\n- Plain item\n- [ ] Task two")
+	output, err := ConvertWithGFM(input)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	// Mixed lists are split into runs: taskList, bulletList, taskList
+	expected := []string{"taskList", "bulletList", "taskList"}
+	if len(doc.Content) != len(expected) {
+		t.Fatalf("Expected %d content nodes, got %d\nOutput: %s", len(expected), len(doc.Content), output)
+	}
+	for i, typ := range expected {
+		if doc.Content[i].Type != typ {
+			t.Errorf("Expected node %d to be %s, got %s", i, typ, doc.Content[i].Type)
+		}
+		if len(doc.Content[i].Content) != 1 {
+			t.Errorf("Expected node %d to have 1 item, got %d", i, len(doc.Content[i].Content))
+		}
+	}
+}
+
+func TestConvertWithGFM_TaskList_OrderedSplit(t *testing.T) {
+	input := []byte("3. [ ] Task\n4. Second\n5. Third")
+	output, err := ConvertWithGFM(input)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	if len(doc.Content) != 2 {
+		t.Fatalf("Expected 2 content nodes, got %d\nOutput: %s", len(doc.Content), output)
+	}
+	if doc.Content[0].Type != "taskList" {
+		t.Errorf("Expected taskList, got %s", doc.Content[0].Type)
+	}
+	// The ordered run continues numbering from its position in the list
+	if doc.Content[1].Type != "orderedList" || doc.Content[1].Attrs["order"] != float64(4) {
+		t.Errorf("Expected orderedList starting at 4, got %s %v", doc.Content[1].Type, doc.Content[1].Attrs)
+	}
+}
+
+func TestConvertWithGFM_TaskList_Nested(t *testing.T) {
+	input := []byte("- [ ] Parent\n  - [x] Child one\n  - [ ] Child two\n- [x] Sibling")
+	output, err := ConvertWithGFM(input)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(output, &doc); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+
+	if len(doc.Content) != 1 || doc.Content[0].Type != "taskList" {
+		t.Fatalf("Expected taskList, got %v", doc.Content)
+	}
+
+	// Nested task lists follow their parent item inside the outer taskList
+	taskList := doc.Content[0]
+	expected := []string{"taskItem", "taskList", "taskItem"}
+	if len(taskList.Content) != len(expected) {
+		t.Fatalf("Expected %d children in taskList, got %d\nOutput: %s", len(expected), len(taskList.Content), output)
+	}
+	for i, typ := range expected {
+		if taskList.Content[i].Type != typ {
+			t.Errorf("Expected child %d to be %s, got %s", i, typ, taskList.Content[i].Type)
+		}
+	}
+	if len(taskList.Content[1].Content) != 2 {
+		t.Errorf("Expected 2 nested task items, got %d", len(taskList.Content[1].Content))
+	}
+}
+
+func TestConvertWithGFM_TaskList_UnsupportedContent(t *testing.T) {
+	// A plain list nested under a task item cannot live in a taskList,
+	// so the item keeps the text checkbox prefix
+	input := []byte("- [x] Parent\n  - Plain child")
+	output, err := ConvertWithGFM(input)
 	if err != nil {
 		t.Fatalf("Convert failed: %v", err)
 	}
@@ -335,31 +2569,19 @@ func TestConvert_Image_DefaultFallback(t *testing.T) {
 		t.Fatalf("Failed to parse output: %v", err)
 	}
 
-	// Should have a paragraph with text that has a link mark
-	if len(doc.Content) != 1 {
-		t.Fatalf("Expected 1 content node, got %d", len(doc.Content))
-	}
-	if doc.Content[0].Type != "paragraph" {
-		t.Errorf("Expected paragraph, got %s", doc.Content[0].Type)
-	}
-	if len(doc.Content[0].Content) != 1 {
-		t.Fatalf("Expected 1 child in paragraph, got %d", len(doc.Content[0].Content))
-	}
-	textNode := doc.Content[0].Content[0]
-	if textNode.Type != "text" {
-		t.Errorf("Expected text, got %s", textNode.Type)
-	}
-	if textNode.Text != "Alt text" {
-		t.Errorf("Expected text 'Alt text', got %s", textNode.Text)
+	if len(doc.Content) != 1 || doc.Content[0].Type != "bulletList" {
+		t.Fatalf("Expected bulletList, got %v", doc.Content)
 	}
-	if len(textNode.Marks) != 1 || textNode.Marks[0].Type != "link" {
-		t.Errorf("Expected link mark on text")
+	paragraph := doc.Content[0].Content[0].Content[0]
+	if len(paragraph.Content) == 0 || paragraph.Content[0].Text != "[x] Parent" {
+		t.Errorf("Expected text checkbox prefix, got %v", paragraph.Content)
 	}
 }
 
-func TestConvert_Image_ExternalMedia_Simple(t *testing.T) {
-	input := []byte("![Alt text](https://example.com/image.png)")
-	output, err := convertWithOptions(input, WithExternalMedia(true))
+func TestConvertWithGFM_TaskList_InBlockquote(t *testing.T) {
+	// Blockquotes cannot hold a taskList, so the items stay in a bulletList
+	// with text checkbox prefixes
+	output, diagnostics, err := ConvertWithReport([]byte("> - [ ] Open\n> - [x] Done"))
 	if err != nil {
 		t.Fatalf("Convert failed: %v", err)
 	}
@@ -373,38 +2595,162 @@ func TestConvert_Image_ExternalMedia_Simple(t *testing.T) {
 		t.Fatalf("Failed to parse output: %v", err)
 	}
 
-	// Should have a mediaSingle containing a media node
-	if len(doc.Content) != 1 {
-		t.Fatalf("Expected 1 content node, got %d", len(doc.Content))
+	list := doc.Content[0].Content[0]
+	if list.Type != "bulletList" || len(list.Content) != 2 {
+		t.Fatalf("Expected bulletList with 2 items, got %v", doc.Content)
 	}
-	mediaSingle := doc.Content[0]
-	if mediaSingle.Type != "mediaSingle" {
-		t.Errorf("Expected mediaSingle, got %s", mediaSingle.Type)
+	for i, want := range []string{"[ ] Open", "[x] Done"} {
+		if got := list.Content[i].Content[0].Content[0].Text; got != want {
+			t.Errorf("Item %d: expected %q, got %q", i, want, got)
+		}
 	}
-	if mediaSingle.Attrs["layout"] != "center" {
-		t.Errorf("Expected layout 'center', got %v", mediaSingle.Attrs["layout"])
+
+	want := []Diagnostic{
+		{SeverityWarning, DiagnosticTaskItemAsText, "blockquote cannot contain taskList; rendered with a text checkbox", 1, 5},
+		{SeverityWarning, DiagnosticTaskItemAsText, "blockquote cannot contain taskList; rendered with a text checkbox", 2, 5},
 	}
-	if len(mediaSingle.Content) != 1 {
-		t.Fatalf("Expected 1 child in mediaSingle, got %d", len(mediaSingle.Content))
+	if !reflect.DeepEqual(diagnostics, want) {
+		t.Errorf("Expected diagnostics %v, got %v", want, diagnostics)
 	}
-	media := mediaSingle.Content[0]
-	if media.Type != "media" {
-		t.Errorf("Expected media, got %s", media.Type)
+}
+
+// convertWithGFMOptions is a test helper that converts markdown with GFM and options
+func convertWithGFMOptions(source []byte, opts ...Option) ([]byte, error) {
+	var buf bytes.Buffer
+	md := NewWithGFM(opts...)
+	if err := md.Convert(source, &buf); err != nil {
+		return nil, err
 	}
-	if media.Attrs["type"] != "external" {
-		t.Errorf("Expected type 'external', got %v", media.Attrs["type"])
+	return buf.Bytes(), nil
+}
+
+func TestConvertWithGFM_Alert(t *testing.T) {
+	tests := []struct {
+		input     string
+		panelType string
+	}{
+		{"> [!NOTE]\n> Useful information.", "info"},
+		{"> [!TIP]\n> Helpful advice.", "success"},
+		{"> [!IMPORTANT]\n> Key information.", "note"},
+		{"> [!WARNING]\n> Urgent info.", "warning"},
+		{"> [!CAUTION]\n> Risky action.", "error"},
+		{"> [!note]\n> Lower case marker.", "info"},
+		{"> [!WARNING]\n> **Do not** deploy.", "warning"},
+		{"> [!NOTE]\n> `make` first.", "info"},
+		{"> [!TIP]\n> [Docs](https://example.com) help.", "success"},
+		{"> [!NOTE]\n> <https://example.com>", "info"},
 	}
-	if media.Attrs["url"] != "https://example.com/image.png" {
-		t.Errorf("Expected url 'https://example.com/image.png', got %v", media.Attrs["url"])
+
+	for _, tc := range tests {
+		output, err := ConvertWithGFM([]byte(tc.input))
+		if err != nil {
+			t.Fatalf("Convert failed for %q: %v", tc.input, err)
+		}
+
+		if err := adfschema.Validate(output); err != nil {
+			t.Errorf("Invalid ADF for %q: %v\nOutput: %s", tc.input, err, output)
+		}
+
+		var doc Document
+		if err := json.Unmarshal(output, &doc); err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+
+		if len(doc.Content) != 1 || doc.Content[0].Type != "panel" {
+			t.Fatalf("Expected panel for %q, got %v", tc.input, doc.Content)
+		}
+		panel := doc.Content[0]
+		if panel.Attrs["panelType"] != tc.panelType {
+			t.Errorf("Expected panelType %s for %q, got %v", tc.panelType, tc.input, panel.Attrs["panelType"])
+		}
+		// The marker line is removed, leaving only the body paragraph
+		if len(panel.Content) != 1 || panel.Content[0].Type != "paragraph" {
+			t.Fatalf("Expected a single paragraph in panel for %q, got %v", tc.input, panel.Content)
+		}
+		for _, child := range panel.Content[0].Content {
+			if child.Text == "[" || child.Text == "]" {
+				t.Errorf("Expected marker to be removed for %q, got %v", tc.input, panel.Content[0].Content)
+			}
+		}
 	}
-	if media.Attrs["alt"] != "Alt text" {
-		t.Errorf("Expected alt 'Alt text', got %v", media.Attrs["alt"])
+}
+
+func TestConvertWithGFM_Alert_MarkerOnly(t *testing.T) {
+	output, err := ConvertWithGFM([]byte("> [!WARNING]"))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	// Panels require content, so an empty paragraph is added
+	if err := adfschema.Validate(output); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
 	}
 }
 
-func TestConvert_Image_ExternalMedia_WithCaption(t *testing.T) {
-	input := []byte(`![Alt text](https://example.com/image.png "This is a caption")`)
-	output, err := convertWithOptions(input, WithExternalMedia(true))
+func TestConvertWithGFM_Alert_Placement(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		want        string
+		diagnostics []Diagnostic
+	}{
+		{
+			name:  "in a list item",
+			input: "- item\n\n  > [!NOTE]\n  > Body",
+			want:  `[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"item"}]},{"type":"paragraph","content":[{"type":"text","text":"Body"}]}]}]}]`,
+			diagnostics: []Diagnostic{
+				{SeverityWarning, DiagnosticAlertFlattened, "listItem cannot contain panel; alert rendered as its content", 3, 5},
+			},
+		},
+		{
+			name:  "holding a table",
+			input: "> [!NOTE]\n> | A |\n> |---|\n> | 1 |",
+			want:  `[{"type":"table","attrs":{"isNumberColumnEnabled":false,"layout":"default"},"content":[{"type":"tableRow","content":[{"type":"tableHeader","content":[{"type":"paragraph","content":[{"type":"text","text":"A"}]}]}]},{"type":"tableRow","content":[{"type":"tableCell","content":[{"type":"paragraph","content":[{"type":"text","text":"1"}]}]}]}]}]`,
+			diagnostics: []Diagnostic{
+				{SeverityWarning, DiagnosticAlertFlattened, "panel cannot contain table; alert rendered as its content", 1, 3},
+			},
+		},
+		{
+			name:  "holding a blockquote",
+			input: "> [!TIP]\n> > Quoted",
+			want:  `[{"type":"blockquote","content":[{"type":"paragraph","content":[{"type":"text","text":"Quoted"}]}]}]`,
+			diagnostics: []Diagnostic{
+				{SeverityWarning, DiagnosticAlertFlattened, "panel cannot contain blockquote; alert rendered as its content", 1, 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, diagnostics, err := ConvertWithReport([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("ConvertWithReport failed: %v", err)
+			}
+
+			if err := adfschema.Validate(output); err != nil {
+				t.Errorf("Invalid ADF output: %v\nOutput: %s", err, output)
+			}
+			if !reflect.DeepEqual(diagnostics, tt.diagnostics) {
+				t.Errorf("Expected diagnostics %v, got %v", tt.diagnostics, diagnostics)
+			}
+
+			var doc Document
+			if err := json.Unmarshal(output, &doc); err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			got, err := json.Marshal(doc.Content, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected %s\ngot      %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestConvertWithGFM_Alert_UnknownKind(t *testing.T) {
+	output, err := ConvertWithGFM([]byte("> [!DANGER]\n> Unknown kind."))
 	if err != nil {
 		t.Fatalf("Convert failed: %v", err)
 	}
@@ -418,40 +2764,58 @@ func TestConvert_Image_ExternalMedia_WithCaption(t *testing.T) {
 		t.Fatalf("Failed to parse output: %v", err)
 	}
 
-	// Should have a mediaSingle with media + caption
-	if len(doc.Content) != 1 {
-		t.Fatalf("Expected 1 content node, got %d", len(doc.Content))
-	}
-	mediaSingle := doc.Content[0]
-	if mediaSingle.Type != "mediaSingle" {
-		t.Errorf("Expected mediaSingle, got %s", mediaSingle.Type)
+	if len(doc.Content) != 1 || doc.Content[0].Type != "blockquote" {
+		t.Fatalf("Expected blockquote, got %v", doc.Content)
 	}
-	if len(mediaSingle.Content) != 2 {
-		t.Fatalf("Expected 2 children in mediaSingle (media + caption), got %d", len(mediaSingle.Content))
+	if !strings.HasPrefix(doc.Content[0].Content[0].Content[0].Text, "[!DANGER]") {
+		t.Errorf("Expected marker text to be kept, got %v", doc.Content[0].Content[0].Content)
 	}
+}
 
-	// Check media
-	media := mediaSingle.Content[0]
-	if media.Type != "media" {
-		t.Errorf("Expected media, got %s", media.Type)
+func TestConvertWithGFM_Alert_CustomPanels(t *testing.T) {
+	opts := WithAlertPanels(map[string]string{
+		"danger": "error",
+		"NOTE":   "note",
+		"TIP":    "",
+	})
+	tests := []struct {
+		input string
+		typ   string
+		panel string
+	}{
+		{"> [!DANGER]\n> Custom kind.", "panel", "error"},
+		{"> [!NOTE]\n> Overridden kind.", "panel", "note"},
+		{"> [!WARNING]\n> Default kind.", "panel", "warning"},
+		{"> [!TIP]\n> Disabled kind.", "blockquote", ""},
 	}
 
-	// Check caption
-	caption := mediaSingle.Content[1]
-	if caption.Type != "caption" {
-		t.Errorf("Expected caption, got %s", caption.Type)
-	}
-	if len(caption.Content) != 1 {
-		t.Fatalf("Expected 1 child in caption, got %d", len(caption.Content))
-	}
-	if caption.Content[0].Text != "This is a caption" {
-		t.Errorf("Expected caption text 'This is a caption', got %s", caption.Content[0].Text)
+	for _, tc := range tests {
+		output, err := convertWithGFMOptions([]byte(tc.input), opts)
+		if err != nil {
+			t.Fatalf("Convert failed for %q: %v", tc.input, err)
+		}
+
+		if err := adfschema.Validate(output); err != nil {
+			t.Errorf("Invalid ADF for %q: %v\nOutput: %s", tc.input, err, output)
+		}
+
+		var doc Document
+		if err := json.Unmarshal(output, &doc); err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+
+		if len(doc.Content) != 1 || doc.Content[0].Type != tc.typ {
+			t.Fatalf("Expected %s for %q, got %v", tc.typ, tc.input, doc.Content)
+		}
+		if tc.panel != "" && doc.Content[0].Attrs["panelType"] != tc.panel {
+			t.Errorf("Expected panelType %s for %q, got %v", tc.panel, tc.input, doc.Content[0].Attrs["panelType"])
+		}
 	}
 }
 
-func TestConvert_Image_ExternalMedia_InlineParagraphSplitting(t *testing.T) {
-	input := []byte("Before image ![img](https://example.com/image.png) after image")
-	output, err := convertWithOptions(input, WithExternalMedia(true))
+func TestConvertWithGFM_Details(t *testing.T) {
+	input := []byte("<details>\n<summary>Stack trace</summary>\n\nSome **details** here.\n\n```\npanic: oops\n```\n\n</details>")
+	output, err := ConvertWithGFM(input)
 	if err != nil {
 		t.Fatalf("Convert failed: %v", err)
 	}
@@ -465,36 +2829,41 @@ func TestConvert_Image_ExternalMedia_InlineParagraphSplitting(t *testing.T) {
 		t.Fatalf("Failed to parse output: %v", err)
 	}
 
-	// Should have: paragraph, mediaSingle, paragraph
-	if len(doc.Content) != 3 {
-		t.Fatalf("Expected 3 content nodes (para, media, para), got %d\nOutput: %s", len(doc.Content), output)
-	}
-
-	// First paragraph with "Before image "
-	if doc.Content[0].Type != "paragraph" {
-		t.Errorf("Expected first node to be paragraph, got %s", doc.Content[0].Type)
+	if len(doc.Content) != 1 || doc.Content[0].Type != "expand" {
+		t.Fatalf("Expected expand, got %v\nOutput: %s", doc.Content, output)
 	}
-	if len(doc.Content[0].Content) == 0 || doc.Content[0].Content[0].Text != "Before image " {
-		t.Errorf("Expected first paragraph to contain 'Before image ', got %v", doc.Content[0].Content)
+	expand := doc.Content[0]
+	if expand.Attrs["title"] != "Stack trace" {
+		t.Errorf("Expected title 'Stack trace', got %v", expand.Attrs["title"])
 	}
-
-	// MediaSingle
-	if doc.Content[1].Type != "mediaSingle" {
-		t.Errorf("Expected second node to be mediaSingle, got %s", doc.Content[1].Type)
+	if len(expand.Content) != 2 {
+		t.Fatalf("Expected 2 children in expand, got %d", len(expand.Content))
 	}
-
-	// Second paragraph with " after image"
-	if doc.Content[2].Type != "paragraph" {
-		t.Errorf("Expected third node to be paragraph, got %s", doc.Content[2].Type)
+	if expand.Content[0].Type != "paragraph" {
+		t.Errorf("Expected first child to be paragraph, got %s", expand.Content[0].Type)
 	}
-	if len(doc.Content[2].Content) == 0 || doc.Content[2].Content[0].Text != " after image" {
-		t.Errorf("Expected second paragraph to contain ' after image', got %v", doc.Content[2].Content)
+	if expand.Content[1].Type != "codeBlock" {
+		t.Errorf("Expected second child to be codeBlock, got %s", expand.Content[1].Type)
 	}
 }
 
-func TestConvert_Image_ExternalMedia_InList(t *testing.T) {
-	input := []byte("- Item with ![image](https://example.com/image.png) inline")
-	output, err := convertWithOptions(input, WithExternalMedia(true))
+func TestConvertWithGFM_Details_Nested(t *testing.T) {
+	input := []byte(`<details>
+<summary>Outer</summary>
+
+Outer text
+
+<details>
+<summary>Inner</summary>
+
+Inner text
+
+<details><summary>Deepest</summary>Deepest text</details>
+
+</details>
+
+</details>`)
+	output, err := ConvertWithGFM(input)
 	if err != nil {
 		t.Fatalf("Convert failed: %v", err)
 	}
@@ -508,34 +2877,37 @@ func TestConvert_Image_ExternalMedia_InList(t *testing.T) {
 		t.Fatalf("Failed to parse output: %v", err)
 	}
 
-	// Should have bulletList -> listItem -> [para, mediaSingle, para]
-	if len(doc.Content) != 1 || doc.Content[0].Type != "bulletList" {
-		t.Fatalf("Expected bulletList, got %v", doc.Content)
+	if len(doc.Content) != 1 || doc.Content[0].Type != "expand" {
+		t.Fatalf("Expected expand, got %v\nOutput: %s", doc.Content, output)
 	}
-	bulletList := doc.Content[0]
-	if len(bulletList.Content) != 1 || bulletList.Content[0].Type != "listItem" {
-		t.Fatalf("Expected listItem, got %v", bulletList.Content)
+	expand := doc.Content[0]
+	if len(expand.Content) != 2 || expand.Content[1].Type != "nestedExpand" {
+		t.Fatalf("Expected paragraph and nestedExpand in expand, got %v", expand.Content)
 	}
-	listItem := bulletList.Content[0]
-
-	// List item should contain: paragraph, mediaSingle, paragraph
-	if len(listItem.Content) != 3 {
-		t.Fatalf("Expected 3 nodes in listItem, got %d\nOutput: %s", len(listItem.Content), output)
+	nested := expand.Content[1]
+	if nested.Attrs["title"] != "Inner" {
+		t.Errorf("Expected title 'Inner', got %v", nested.Attrs["title"])
 	}
-	if listItem.Content[0].Type != "paragraph" {
-		t.Errorf("Expected first child to be paragraph, got %s", listItem.Content[0].Type)
+
+	// nestedExpand cannot contain another expand, so the deepest section is
+	// flattened into a bold title paragraph followed by its content
+	for _, child := range nested.Content {
+		if child.Type != "paragraph" {
+			t.Errorf("Expected only paragraphs in nestedExpand, got %s", child.Type)
+		}
 	}
-	if listItem.Content[1].Type != "mediaSingle" {
-		t.Errorf("Expected second child to be mediaSingle, got %s", listItem.Content[1].Type)
+	if len(nested.Content) != 3 {
+		t.Fatalf("Expected 3 paragraphs in nestedExpand, got %d\nOutput: %s", len(nested.Content), output)
 	}
-	if listItem.Content[2].Type != "paragraph" {
-		t.Errorf("Expected third child to be paragraph, got %s", listItem.Content[2].Type)
+	title := nested.Content[1].Content[0]
+	if title.Text != "Deepest" || len(title.Marks) != 1 || title.Marks[0].Type != "strong" {
+		t.Errorf("Expected bold 'Deepest' title, got %v", title)
 	}
 }
 
-func TestConvert_Image_ExternalMedia_InBlockquote(t *testing.T) {
-	input := []byte("> Quote with ![image](https://example.com/image.png)")
-	output, err := convertWithOptions(input, WithExternalMedia(true))
+func TestConvertWithGFM_Details_SingleBlock(t *testing.T) {
+	input := []byte("<details><summary>Logs</summary>Everything in one block</details>")
+	output, err := ConvertWithGFM(input)
 	if err != nil {
 		t.Fatalf("Convert failed: %v", err)
 	}
@@ -549,27 +2921,18 @@ func TestConvert_Image_ExternalMedia_InBlockquote(t *testing.T) {
 		t.Fatalf("Failed to parse output: %v", err)
 	}
 
-	// Should have blockquote -> [para, mediaSingle]
-	if len(doc.Content) != 1 || doc.Content[0].Type != "blockquote" {
-		t.Fatalf("Expected blockquote, got %v", doc.Content)
-	}
-	blockquote := doc.Content[0]
-
-	// Blockquote should contain: paragraph, mediaSingle
-	if len(blockquote.Content) != 2 {
-		t.Fatalf("Expected 2 nodes in blockquote, got %d\nOutput: %s", len(blockquote.Content), output)
-	}
-	if blockquote.Content[0].Type != "paragraph" {
-		t.Errorf("Expected first child to be paragraph, got %s", blockquote.Content[0].Type)
+	if len(doc.Content) != 1 || doc.Content[0].Type != "expand" {
+		t.Fatalf("Expected expand, got %v", doc.Content)
 	}
-	if blockquote.Content[1].Type != "mediaSingle" {
-		t.Errorf("Expected second child to be mediaSingle, got %s", blockquote.Content[1].Type)
+	content := doc.Content[0].Content
+	if len(content) != 1 || len(content[0].Content) != 1 || content[0].Content[0].Text != "Everything in one block" {
+		t.Errorf("Expected body paragraph, got %v", content)
 	}
 }
 
-func TestConvert_Image_ExternalMedia_CustomLayout(t *testing.T) {
-	input := []byte("![Alt text](https://example.com/image.png)")
-	output, err := convertWithOptions(input, WithExternalMedia(true), WithImageLayout("wide"))
+func TestConvertWithGFM_Details_Unterminated(t *testing.T) {
+	input := []byte("<details>\n<summary>Logs</summary>\n\nNo closing tag")
+	output, err := ConvertWithGFM(input)
 	if err != nil {
 		t.Fatalf("Convert failed: %v", err)
 	}
@@ -583,8 +2946,156 @@ func TestConvert_Image_ExternalMedia_CustomLayout(t *testing.T) {
 		t.Fatalf("Failed to parse output: %v", err)
 	}
 
-	mediaSingle := doc.Content[0]
-	if mediaSingle.Attrs["layout"] != "wide" {
-		t.Errorf("Expected layout 'wide', got %v", mediaSingle.Attrs["layout"])
+	// Without a closing tag the HTML is dropped as before
+	if len(doc.Content) != 1 || doc.Content[0].Type != "paragraph" {
+		t.Errorf("Expected only the paragraph, got %v", doc.Content)
+	}
+}
+
+func TestParseDocument_RoundTrip(t *testing.T) {
+	output, err := ConvertWithGFM([]byte("# Title\n\n- [x] Done\n\n| A |\n|---|\n| **b** |\n\n> [!NOTE]\n> See [docs](https://example.com)"))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	doc, err := ParseDocument(output)
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+
+	again, err := doc.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var want Document
+	if err := json.Unmarshal(output, &want); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	var got Document
+	if err := json.Unmarshal(again, &got); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Round trip changed output\nbefore: %s\nafter:  %s", output, again)
+	}
+}
+
+func TestParseDocument_Lossless(t *testing.T) {
+	input := []byte(`{
+		"version": 1,
+		"type": "doc",
+		"content": [
+			{
+				"type": "mysteryBlock",
+				"attrs": {"custom": {"nested": [1, "two", null]}, "flag": true},
+				"localId": "abc",
+				"content": [
+					{"type": "text", "text": "hi", "marks": [{"type": "fancy", "attrs": {"level": 3}, "extra": "kept"}]}
+				]
+			}
+		],
+		"meta": {"source": "jira"}
+	}`)
+
+	doc, err := ParseDocument(input)
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+	if doc.Content[0].Type != "mysteryBlock" || doc.Content[0].Content[0].Marks[0].Type != "fancy" {
+		t.Errorf("Expected unknown types to be kept, got %v", doc.Content)
+	}
+
+	output, err := doc.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var want, got any
+	if err := json.Unmarshal(input, &want); err != nil {
+		t.Fatalf("Failed to parse input: %v", err)
+	}
+	if err := json.Unmarshal(output, &got); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Round trip lost data\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+func TestParseDocument_Numbers(t *testing.T) {
+	doc, err := ParseDocument([]byte(`{
+		"version": 1,
+		"type": "doc",
+		"content": [
+			{"type": "heading", "attrs": {"level": 2}},
+			{"type": "mediaSingle", "attrs": {"width": 66.5, "widths": [100, 1e2, 12345678901234567]}}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+
+	if level, ok := doc.Content[0].Attrs["level"].(int); !ok || level != 2 {
+		t.Errorf("Expected level 2 as int, got %T %v", doc.Content[0].Attrs["level"], doc.Content[0].Attrs["level"])
+	}
+	if width, ok := doc.Content[1].Attrs["width"].(float64); !ok || width != 66.5 {
+		t.Errorf("Expected width 66.5 as float64, got %T %v", doc.Content[1].Attrs["width"], doc.Content[1].Attrs["width"])
+	}
+	want := []any{100, 100.0, 12345678901234567}
+	if got := doc.Content[1].Attrs["widths"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected widths %#v, got %#v", want, got)
+	}
+}
+
+func TestParseDocument_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		line   int
+		column int
+		path   string
+	}{
+		{
+			name:   "syntax",
+			input:  "{\n  \"type\": \"doc\",\n  \"content\": [}\n",
+			line:   3,
+			column: 15,
+			path:   "/content/0",
+		},
+		{
+			name:   "wrong type",
+			input:  "{\n  \"type\": \"doc\",\n  \"content\": [{\"type\": 7}]\n}",
+			line:   3,
+			column: 24,
+			path:   "/content/0/type",
+		},
+		{
+			name:   "truncated",
+			input:  "{\"type\": \"doc\", \"content\": [",
+			line:   1,
+			column: 29,
+			path:   "/content",
+		},
+		{
+			name:   "not a document",
+			input:  `{"type": "paragraph", "content": []}`,
+			line:   1,
+			column: 1,
+			path:   "/type",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseDocument([]byte(tc.input))
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Expected *ParseError, got %T: %v", err, err)
+			}
+			if pe.Line != tc.line || pe.Column != tc.column || pe.Path != tc.path {
+				t.Errorf("Expected %d:%d at %q, got %d:%d at %q (%v)", tc.line, tc.column, tc.path, pe.Line, pe.Column, pe.Path, err)
+			}
+		})
 	}
 }
@@ -0,0 +1,367 @@
+package adfschema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// Violation describes a single way in which a document does not conform to
+// the ADF schema.
+type Violation struct {
+	// Path is the JSON Pointer (RFC 6901) of the offending value, e.g.
+	// "/content/3/content/0/marks/1". The empty string refers to the whole
+	// document.
+	Path string
+
+	// NodeType is the type of the node or mark at or containing Path, e.g.
+	// "heading" or "link". It is empty if the type is not known.
+	NodeType string
+
+	// Keyword is the JSON Schema keyword that failed, e.g. "required",
+	// "enum", "maximum" or "anyOf".
+	Keyword string
+
+	// Message is a human readable description of the violation.
+	Message string
+}
+
+// String returns the violation as "path (type): message".
+func (v Violation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "/"
+	}
+	if v.NodeType == "" {
+		return fmt.Sprintf("%s: %s", path, v.Message)
+	}
+	return fmt.Sprintf("%s (%s): %s", path, v.NodeType, v.Message)
+}
+
+// ValidationError is returned by [Validate] when a document does not conform
+// to the ADF schema. Use errors.As to access the individual violations:
+//
+//	var verr *adfschema.ValidationError
+//	if errors.As(err, &verr) {
+//	    for _, v := range verr.Violations {
+//	        log.Printf("%s: %s", v.Path, v.Message)
+//	    }
+//	}
+type ValidationError struct {
+	// Violations lists the problems found, in document order. It always has
+	// at least one entry.
+	Violations []Violation
+
+	err error
+}
+
+// Error implements the error interface, describing the first violation.
+func (e *ValidationError) Error() string {
+	msg := "adfschema: invalid ADF at " + e.Violations[0].String()
+	if n := len(e.Violations) - 1; n == 1 {
+		msg += " (and 1 more violation)"
+	} else if n > 1 {
+		msg += fmt.Sprintf(" (and %d more violations)", n)
+	}
+	return msg
+}
+
+// Unwrap returns the error reported by the underlying schema validator.
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+var (
+	// validatingRegexp matches the context prefixes jsonschema-go adds to
+	// validation errors, capturing the schema location.
+	validatingRegexp = regexp.MustCompile(`^validating ([^:]*): `)
+	keywordRegexp    = regexp.MustCompile(`^([A-Za-z]+)(?:\[[^\]]*\])?: `)
+	schemaPathRegexp = regexp.MustCompile(`^/definitions/[^/]+((?:/properties/[^/]+)*)`)
+	rationalRegexp   = regexp.MustCompile(`\b(-?\d+)/1\b`)
+	decimalRegexp    = regexp.MustCompile(`\b(\d+)\.0+\b`)
+)
+
+// fragments caches resolved schemas for individual definitions and content
+// rules, built on demand when locating violations.
+var fragments = struct {
+	sync.Mutex
+	resolved map[string]*jsonschema.Resolved
+}{resolved: map[string]*jsonschema.Resolved{}}
+
+// resolveFragment resolves s in the context of the ADF schema definitions,
+// caching the result under key.
+func resolveFragment(key string, s *jsonschema.Schema) (*jsonschema.Resolved, error) {
+	fragments.Lock()
+	defer fragments.Unlock()
+	if rs, ok := fragments.resolved[key]; ok {
+		return rs, nil
+	}
+	root := &jsonschema.Schema{
+		Schema:      rootSchema.Schema,
+		Definitions: rootSchema.CloneSchemas().Definitions,
+		AllOf:       []*jsonschema.Schema{s.CloneSchemas()},
+	}
+	rs, err := root.Resolve(nil)
+	if err != nil {
+		return nil, err
+	}
+	fragments.resolved[key] = rs
+	return rs, nil
+}
+
+// definitionsFor returns the names of the definitions describing the node
+// (suffix "_node") or mark (suffix "_mark") of the given type.
+func definitionsFor(typ, suffix string) []string {
+	var names []string
+	for name, def := range rootSchema.Definitions {
+		if !strings.HasSuffix(name, suffix) || def.Properties["type"] == nil {
+			continue
+		}
+		for _, v := range def.Properties["type"].Enum {
+			if v == typ {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// itemSchema returns the schema for item i of the given array property of a
+// definition, following references, or nil if the item is unconstrained.
+func itemSchema(definition, property string, i int) (*jsonschema.Schema, string) {
+	s := rootSchema.Definitions[definition].Properties[property]
+	for s != nil && s.Ref != "" {
+		s = rootSchema.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	}
+	switch {
+	case s == nil:
+		return nil, ""
+	case s.Items != nil:
+		return s.Items, fmt.Sprintf("%s/%s/items", definition, property)
+	case i < len(s.ItemsArray):
+		return s.ItemsArray[i], fmt.Sprintf("%s/%s/items/%d", definition, property, i)
+	case s.AdditionalItems != nil:
+		return s.AdditionalItems, fmt.Sprintf("%s/%s/additionalItems", definition, property)
+	}
+	return nil, ""
+}
+
+// allowedTypes adds the node and mark types a schema can match to types.
+func allowedTypes(s *jsonschema.Schema, types map[string]bool) {
+	if s == nil {
+		return
+	}
+	if s.Ref != "" {
+		allowedTypes(rootSchema.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")], types)
+	}
+	for _, sub := range append(s.AnyOf, s.AllOf...) {
+		allowedTypes(sub, types)
+	}
+	if t := s.Properties["type"]; t != nil {
+		for _, v := range t.Enum {
+			if typ, ok := v.(string); ok {
+				types[typ] = true
+			}
+		}
+	}
+}
+
+// newValidationError locates the violations in an instance that failed
+// validation with err.
+func newValidationError(instance any, err error) *ValidationError {
+	l := &locator{}
+	l.node(instance, "", "_node")
+	if len(l.violations) == 0 {
+		// The walk could not narrow the problem down
+		l.violations = append(l.violations, violationFromError("", "", err))
+	}
+	return &ValidationError{Violations: l.violations, err: err}
+}
+
+// locator walks an invalid document to find the most specific violations.
+type locator struct {
+	violations []Violation
+}
+
+// node checks a node (or a mark, depending on suffix) at path, reporting the
+// deepest violations found. It reports whether the value is valid.
+func (l *locator) node(v any, path, suffix string) bool {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		l.add(Violation{Path: path, Keyword: "type", Message: fmt.Sprintf("expected an object, got %s", jsonType(v))})
+		return false
+	}
+	typ, ok := obj["type"].(string)
+	if !ok {
+		l.add(Violation{Path: path + "/type", Keyword: "required", Message: `missing or non-string "type"`})
+		return false
+	}
+
+	// Problems in children explain a failure better than the parent's
+	// anyOf over every allowed child
+	valid := true
+	for i, c := range arrayProperty(obj, "content") {
+		valid = l.node(c, path+"/content/"+strconv.Itoa(i), "_node") && valid
+	}
+	for i, m := range arrayProperty(obj, "marks") {
+		valid = l.node(m, path+"/marks/"+strconv.Itoa(i), "_mark") && valid
+	}
+	if !valid {
+		return false
+	}
+
+	kind := "node"
+	if suffix == "_mark" {
+		kind = "mark"
+	}
+	defs := definitionsFor(typ, suffix)
+	if len(defs) == 0 {
+		l.add(Violation{Path: path + "/type", NodeType: typ, Keyword: "enum", Message: fmt.Sprintf("unknown %s type %q", kind, typ)})
+		return false
+	}
+
+	var firstErr error
+	for _, def := range defs {
+		rs, err := resolveFragment(def, &jsonschema.Schema{Ref: "#/definitions/" + def})
+		if err != nil {
+			return true
+		}
+		err = rs.Validate(obj)
+		if err == nil {
+			return true
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// Each child is valid on its own, so look for children that are not
+	// allowed here
+	before := len(l.violations)
+	l.children(obj, path, typ, defs[0], "content", "node")
+	l.children(obj, path, typ, defs[0], "marks", "mark")
+	if len(l.violations) == before {
+		l.add(violationFromError(path, typ, firstErr))
+	}
+	return false
+}
+
+// children reports the items of an array property that do not match the
+// definition's item schema.
+func (l *locator) children(obj map[string]any, path, typ, definition, property, kind string) {
+	for i, c := range arrayProperty(obj, property) {
+		items, key := itemSchema(definition, property, i)
+		if items == nil {
+			continue
+		}
+		rs, err := resolveFragment(key, items)
+		if err != nil {
+			return
+		}
+		err = rs.Validate(c)
+		if err == nil {
+			continue
+		}
+
+		child, _ := c.(map[string]any)
+		childType, _ := child["type"].(string)
+		types := map[string]bool{}
+		allowedTypes(items, types)
+		msg := fmt.Sprintf("%s %q is not allowed here in %q", kind, childType, typ)
+		if types[childType] {
+			// The type is allowed, but not in this form (e.g. a text node
+			// with marks that this parent does not permit)
+			msg = fmt.Sprintf("%s %q does not match the forms allowed here in %q", kind, childType, typ)
+		}
+		keyword, _ := parseError(err)
+		l.add(Violation{
+			Path:     path + "/" + property + "/" + strconv.Itoa(i),
+			NodeType: childType,
+			Keyword:  keyword,
+			Message:  msg,
+		})
+	}
+}
+
+func (l *locator) add(v Violation) {
+	l.violations = append(l.violations, v)
+}
+
+// violationFromError converts a jsonschema-go error for the value at path
+// into a Violation.
+func violationFromError(path, typ string, err error) Violation {
+	keyword, msg, subpath := parseErrorPath(err)
+	if keyword == "anyOf" || keyword == "oneOf" {
+		msg = "does not match any of the allowed schemas"
+	}
+	return Violation{Path: path + subpath, NodeType: typ, Keyword: keyword, Message: msg}
+}
+
+// parseError returns the failing keyword and message of a jsonschema-go
+// error.
+func parseError(err error) (keyword, msg string) {
+	keyword, msg, _ = parseErrorPath(err)
+	return keyword, msg
+}
+
+// parseErrorPath is like parseError but also returns the instance path of the
+// failing value relative to the validated definition, e.g. "/attrs/level".
+func parseErrorPath(err error) (keyword, msg, subpath string) {
+	msg = err.Error()
+	schemaPath := ""
+	for {
+		m := validatingRegexp.FindStringSubmatch(msg)
+		if m == nil {
+			break
+		}
+		if strings.HasPrefix(m[1], "/definitions/") {
+			schemaPath = m[1]
+		}
+		msg = msg[len(m[0]):]
+	}
+
+	if m := keywordRegexp.FindStringSubmatch(msg); m != nil {
+		keyword = m[1]
+		msg = msg[len(m[0]):]
+	} else if strings.HasPrefix(msg, "unexpected additional properties") {
+		keyword = "additionalProperties"
+	}
+
+	// Numbers are formatted as rationals and with fixed precision
+	msg = rationalRegexp.ReplaceAllString(msg, "$1")
+	msg = decimalRegexp.ReplaceAllString(msg, "$1")
+
+	if m := schemaPathRegexp.FindStringSubmatch(schemaPath); m != nil {
+		subpath = strings.ReplaceAll(m[1], "/properties/", "/")
+	}
+	return keyword, msg, subpath
+}
+
+// arrayProperty returns obj[key] if it is an array.
+func arrayProperty(obj map[string]any, key string) []any {
+	a, _ := obj[key].([]any)
+	return a
+}
+
+// jsonType returns the JSON type name of a decoded value.
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
@@ -1,6 +1,7 @@
 package adfschema_test
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/ajbeck/goldmark-adf/adfschema"
@@ -51,3 +52,23 @@ func ExampleValidate_invalid() {
 	// Output:
 	// Validation failed
 }
+
+// This example demonstrates locating schema violations with [ValidationError].
+func ExampleValidationError() {
+	invalidADF := []byte(`{
+		"version": 1,
+		"type": "doc",
+		"content": [
+			{"type": "heading", "attrs": {"level": 7}, "content": []}
+		]
+	}`)
+
+	var verr *adfschema.ValidationError
+	if err := adfschema.Validate(invalidADF); errors.As(err, &verr) {
+		for _, v := range verr.Violations {
+			fmt.Printf("%s (%s, %s): %s\n", v.Path, v.NodeType, v.Keyword, v.Message)
+		}
+	}
+	// Output:
+	// /content/0/attrs/level (heading, maximum): 7 is greater than 6
+}
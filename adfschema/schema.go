@@ -10,6 +10,16 @@
 //	    log.Printf("Invalid ADF: %v", err)
 //	}
 //
+// Schema violations are reported as a [*ValidationError] listing each problem
+// with its JSON Pointer path, node type and failing keyword:
+//
+//	var verr *adfschema.ValidationError
+//	if errors.As(err, &verr) {
+//	    for _, v := range verr.Violations {
+//	        log.Printf("%s (%s): %s", v.Path, v.NodeType, v.Message)
+//	    }
+//	}
+//
 // For tests where validation failure should be fatal, use [MustValidate]:
 //
 //	adfschema.MustValidate(jsonBytes) // panics on error
@@ -27,6 +37,7 @@ import (
 var schemaJSON []byte
 
 var (
+	rootSchema     *jsonschema.Schema
 	resolvedSchema *jsonschema.Resolved
 	initOnce       sync.Once
 	initErr        error
@@ -40,6 +51,7 @@ func initSchema() error {
 			initErr = err
 			return
 		}
+		rootSchema = &s
 		resolvedSchema, initErr = s.Resolve(nil)
 	})
 	return initErr
@@ -47,6 +59,9 @@ func initSchema() error {
 
 // Validate validates ADF JSON against the Atlassian Document Format schema.
 // It returns nil if the document is valid, or an error describing the validation failure.
+//
+// If the JSON is well formed but does not conform to the schema, the error is
+// a [*ValidationError] locating each violation within the document.
 func Validate(data []byte) error {
 	if err := initSchema(); err != nil {
 		return err
@@ -57,7 +72,10 @@ func Validate(data []byte) error {
 		return err
 	}
 
-	return resolvedSchema.Validate(instance)
+	if err := resolvedSchema.Validate(instance); err != nil {
+		return newValidationError(instance, err)
+	}
+	return nil
 }
 
 // MustValidate is like Validate but panics on error.
@@ -1,6 +1,9 @@
 package adfschema
 
 import (
+	"errors"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -222,3 +225,142 @@ func TestValidate_Table(t *testing.T) {
 		t.Errorf("table should be valid: %v", err)
 	}
 }
+
+func TestValidate_IDAttributes(t *testing.T) {
+	// Attributes named "id" are properties, not schema identifiers
+	doc := []byte(`{
+		"version": 1,
+		"type": "doc",
+		"content": [
+			{
+				"type": "paragraph",
+				"content": [
+					{"type": "mention", "attrs": {"id": "5b10ac8d82e05b22cc7d4ef5", "text": "@Alice"}},
+					{"type": "emoji", "attrs": {"shortName": ":smile:", "id": "1f604", "text": "😄"}}
+				]
+			}
+		]
+	}`)
+
+	if err := Validate(doc); err != nil {
+		t.Errorf("mention and emoji with ids should be valid: %v", err)
+	}
+
+	missing := []byte(`{"version": 1, "type": "doc", "content": [{"type": "paragraph", "content": [{"type": "mention", "attrs": {"text": "@Alice"}}]}]}`)
+	if err := Validate(missing); err == nil {
+		t.Error("mention without id should be invalid")
+	}
+}
+
+func TestValidate_ValidationError(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		path     string
+		nodeType string
+		keyword  string
+	}{
+		{
+			name:     "missing version",
+			doc:      `{"type": "doc", "content": []}`,
+			path:     "",
+			nodeType: "doc",
+			keyword:  "required",
+		},
+		{
+			name:     "attribute out of range",
+			doc:      `{"version": 1, "type": "doc", "content": [{"type": "heading", "attrs": {"level": 9}}]}`,
+			path:     "/content/0/attrs/level",
+			nodeType: "heading",
+			keyword:  "maximum",
+		},
+		{
+			name:     "unknown mark",
+			doc:      `{"version": 1, "type": "doc", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "x", "marks": [{"type": "strong"}, {"type": "bogus"}]}]}]}`,
+			path:     "/content/0/content/0/marks/1/type",
+			nodeType: "bogus",
+			keyword:  "enum",
+		},
+		{
+			name:     "node not allowed in parent",
+			doc:      `{"version": 1, "type": "doc", "content": [{"type": "bulletList", "content": [{"type": "listItem", "content": [{"type": "heading", "attrs": {"level": 1}}]}]}]}`,
+			path:     "/content/0/content/0/content/0",
+			nodeType: "heading",
+			keyword:  "anyOf",
+		},
+		{
+			name:     "incompatible marks",
+			doc:      `{"version": 1, "type": "doc", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "x", "marks": [{"type": "code"}, {"type": "strong"}]}]}]}`,
+			path:     "/content/0/content/0",
+			nodeType: "text",
+			keyword:  "anyOf",
+		},
+		{
+			name:     "missing link href",
+			doc:      `{"version": 1, "type": "doc", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "x", "marks": [{"type": "link", "attrs": {}}]}]}]}`,
+			path:     "/content/0/content/0/marks/0/attrs",
+			nodeType: "link",
+			keyword:  "required",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate([]byte(tc.doc))
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected *ValidationError, got %T: %v", err, err)
+			}
+			if len(verr.Violations) != 1 {
+				t.Fatalf("expected 1 violation, got %v", verr.Violations)
+			}
+			v := verr.Violations[0]
+			if v.Path != tc.path || v.NodeType != tc.nodeType || v.Keyword != tc.keyword {
+				t.Errorf("expected %q (%s) %s, got %q (%s) %s: %s", tc.path, tc.nodeType, tc.keyword, v.Path, v.NodeType, v.Keyword, v.Message)
+			}
+			if v.Message == "" {
+				t.Error("expected a message")
+			}
+		})
+	}
+}
+
+func TestValidate_ValidationError_Multiple(t *testing.T) {
+	doc := []byte(`{
+		"version": 1,
+		"type": "doc",
+		"content": [
+			{"type": "heading", "attrs": {"level": 0}},
+			{"type": "panel", "attrs": {"panelType": "bad"}, "content": [{"type": "paragraph"}]}
+		]
+	}`)
+
+	err := Validate(doc)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %T: %v", err, err)
+	}
+
+	var paths []string
+	for _, v := range verr.Violations {
+		paths = append(paths, v.Path)
+	}
+	want := []string{"/content/0/attrs/level", "/content/1/attrs/panelType"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("expected violations at %v, got %v", want, verr.Violations)
+	}
+	if !strings.Contains(err.Error(), "/content/0/attrs/level") || !strings.Contains(err.Error(), "1 more violation") {
+		t.Errorf("unexpected error message: %v", err)
+	}
+}
+
+func TestValidate_MalformedJSON(t *testing.T) {
+	err := Validate([]byte(`{"version": 1,`))
+	if err == nil {
+		t.Fatal("malformed JSON should be invalid")
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		t.Errorf("malformed JSON should not be a ValidationError: %v", err)
+	}
+}
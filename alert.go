@@ -0,0 +1,202 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindAlert is a NodeKind of the Alert node.
+var KindAlert = ast.NewNodeKind("Alert")
+
+// Alert is a block node representing a GitHub-style alert: a blockquote whose
+// first line is a marker such as [!NOTE] or [!WARNING].
+type Alert struct {
+	ast.BaseBlock
+
+	// AlertType is the upper-cased alert kind, e.g. "NOTE" or "WARNING".
+	AlertType string
+}
+
+// NewAlert creates a new Alert node of the given kind.
+func NewAlert(alertType string) *Alert {
+	return &Alert{AlertType: alertType}
+}
+
+// Kind implements ast.Node.Kind.
+func (n *Alert) Kind() ast.NodeKind {
+	return KindAlert
+}
+
+// Dump implements ast.Node.Dump.
+func (n *Alert) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"AlertType": n.AlertType}, nil)
+}
+
+// defaultAlertPanels maps GitHub alert kinds to ADF panel types.
+var defaultAlertPanels = map[string]string{
+	"NOTE":      "info",
+	"TIP":       "success",
+	"IMPORTANT": "note",
+	"WARNING":   "warning",
+	"CAUTION":   "error",
+}
+
+var alertMarkerRegexp = regexp.MustCompile(`^\[!([A-Za-z]+)\]\s*$`)
+
+// alertTransformer rewrites blockquotes that start with an alert marker into
+// Alert nodes.
+type alertTransformer struct {
+	kinds map[string]bool
+}
+
+// NewAlertTransformer returns a parser.ASTTransformer that converts
+// GitHub-style alert blockquotes into [Alert] nodes. Only the given kinds are
+// recognised (case-insensitively); other blockquotes are left untouched. If no
+// kinds are given, the five GitHub alert kinds are recognised.
+func NewAlertTransformer(kinds ...string) parser.ASTTransformer {
+	t := &alertTransformer{kinds: map[string]bool{}}
+	if len(kinds) == 0 {
+		for kind := range defaultAlertPanels {
+			t.kinds[kind] = true
+		}
+	}
+	for _, kind := range kinds {
+		t.kinds[strings.ToUpper(kind)] = true
+	}
+	return t
+}
+
+// Transform implements parser.ASTTransformer.
+func (t *alertTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	// Collect first so the tree is not modified during the walk
+	var quotes []*ast.Blockquote
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if q, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, q)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, q := range quotes {
+		t.transform(q, source)
+	}
+}
+
+// transform replaces the blockquote with an Alert if its first line is a
+// recognised marker.
+func (t *alertTransformer) transform(q *ast.Blockquote, source []byte) {
+	para, ok := q.FirstChild().(*ast.Paragraph)
+	if !ok || para.Lines().Len() == 0 {
+		return
+	}
+	first := para.Lines().At(0)
+	m := alertMarkerRegexp.FindSubmatch(first.Value(source))
+	if m == nil {
+		return
+	}
+	kind := string(bytes.ToUpper(m[1]))
+	if !t.kinds[kind] {
+		return
+	}
+
+	// Find the inline nodes making up the marker line. Nodes without a
+	// source segment (such as autolinks) cannot be part of it.
+	var marker []ast.Node
+	for c := para.FirstChild(); c != nil; c = c.NextSibling() {
+		if start, ok := firstSegmentStart(c); !ok || start >= first.Stop {
+			break
+		}
+		if _, ok := c.(*ast.Text); !ok {
+			// Something other than plain text (e.g. a link reference named
+			// like the marker); leave the blockquote as is
+			return
+		}
+		marker = append(marker, c)
+	}
+	for _, c := range marker {
+		para.RemoveChild(para, c)
+	}
+	para.Lines().SetSliced(1, para.Lines().Len())
+	if !para.HasChildren() {
+		q.RemoveChild(q, para)
+	}
+
+	// The marker line locates the alert in diagnostics
+	alert := NewAlert(kind)
+	alert.Lines().Append(first)
+	for c := q.FirstChild(); c != nil; {
+		next := c.NextSibling()
+		alert.AppendChild(alert, c)
+		c = next
+	}
+	q.Parent().ReplaceChild(q.Parent(), q, alert)
+}
+
+// addAlertParser adds the alert transformer for the kinds mapped to a panel type.
+func addAlertParser(md goldmark.Markdown, panels map[string]string) {
+	var kinds []string
+	for kind, panelType := range panels {
+		if panelType != "" {
+			kinds = append(kinds, kind)
+		}
+	}
+	if len(kinds) == 0 {
+		return
+	}
+	md.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(NewAlertTransformer(kinds...), 100),
+		),
+	)
+}
+
+func (r *Renderer) renderAlert(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	if entering {
+		n := node.(*Alert)
+		panelType := r.config.AlertPanels[n.AlertType]
+		if panelType == "" {
+			// No panel mapping, render as a plain blockquote
+			st.pushNode(NewBlockquote())
+		} else {
+			st.pushNode(NewPanel(panelType))
+		}
+		return ast.WalkContinue, nil
+	}
+
+	// Where the panel, or what it holds, is not allowed, fall back to a
+	// blockquote, and failing that to the alert's content alone
+	alert := st.currentNode()
+	st.discardCurrentNode()
+	parentType := st.parentType()
+	err := checkPlacement(parentType, alert)
+	if err == nil {
+		st.appendToCurrentOrDocument(*alert)
+		return ast.WalkContinue, nil
+	}
+	if alert.Type == "panel" {
+		quote := NewBlockquote()
+		quote.Content = alert.Content
+		if checkPlacement(parentType, quote) == nil {
+			r.report(st, node, SeverityWarning, DiagnosticAlertFlattened, "%v; alert rendered as a blockquote", err)
+			st.appendToCurrentOrDocument(*quote)
+			return ast.WalkContinue, nil
+		}
+	}
+	r.report(st, node, SeverityWarning, DiagnosticAlertFlattened, "%v; alert rendered as its content", err)
+	for _, c := range alert.Content {
+		st.appendToCurrentOrDocument(c)
+	}
+	return ast.WalkContinue, nil
+}
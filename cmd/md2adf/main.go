@@ -0,0 +1,437 @@
+//go:build goexperiment.jsonv2
+
+// Command md2adf converts Markdown to Atlassian Document Format JSON.
+//
+// Usage:
+//
+//	md2adf [flags] [file|dir ...]
+//
+// With no arguments, or with "-", Markdown is read from stdin. Files are
+// converted to stdout, or to the file named by -o. Directories are walked
+// recursively and every Markdown file (.md, .markdown) is converted to a
+// .adf.json file next to it, or at the mirrored path under -out-dir.
+//
+// Flags:
+//
+//	-gfm                   enable GitHub Flavored Markdown extensions (default true)
+//	-external-media        render images as external mediaSingle nodes
+//	-image-layout string   mediaSingle layout for external images
+//	-table-layout string   table layout: default, center, wide, full-width
+//	-table-number-column   enable the numbered first column of tables
+//	-table-widths string   comma-separated column widths in pixels
+//	-table-display-mode    table displayMode: default, fixed
+//	-alert NAME=panel      map an alert to a panel type (repeatable)
+//	-smart-link HOST=INLINE,STANDALONE
+//	                       render autolinks to HOST as smart links: none, inline, block, embed (repeatable)
+//	-hard-wraps            render soft line breaks as hardBreak nodes
+//	-html string           raw HTML handling: drop, text, code, translate
+//	-mark-conflicts string resolve code combined with other marks: keep-code, drop-code, fail
+//	-mentions string       JSON file mapping usernames to users for @username mentions
+//	-emoji string          JSON file mapping short names to custom emoji for :shortcodes:
+//	-status-syntax string  status lozenge delimiters as "OPEN SEPARATOR CLOSE", e.g. "[[ : ]]"
+//	-timezone string       time zone of {date:...} macros without one, e.g. Europe/Berlin (default UTC)
+//	-unknown-macros string confluence-macro fences naming unknown macros: code, error
+//	-definition-lists string
+//	                       definition list style: blockquote, indented, table, bullet-list
+//	-inline-footnotes      render footnotes in parentheses where they are referenced
+//	-diagnostics           print conversion diagnostics to stderr
+//	-compact               write compact instead of indented JSON
+//	-validate              validate output against the ADF schema before writing
+//	-o string              output file for a single input
+//	-out-dir string        root of the mirrored output tree for directories
+//
+// Image handlers, :::name container handlers and registered Confluence macros
+// are Go values and only available through the library.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	adf "github.com/ajbeck/goldmark-adf"
+	"github.com/ajbeck/goldmark-adf/adfschema"
+	"github.com/yuin/goldmark"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "md2adf: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// converter holds the configured Markdown instance and output settings.
+type converter struct {
+	md       goldmark.Markdown
+	compact  bool
+	validate bool
+
+	// name is the name of the input being converted
+	name string
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fset := flag.NewFlagSet("md2adf", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	fset.Usage = func() {
+		fmt.Fprintln(stderr, "usage: md2adf [flags] [file|dir ...]")
+		fset.PrintDefaults()
+	}
+
+	gfm := fset.Bool("gfm", true, "enable GitHub Flavored Markdown extensions")
+	externalMedia := fset.Bool("external-media", false, "render images as external mediaSingle nodes")
+	imageLayout := fset.String("image-layout", "", "mediaSingle layout for external images")
+	tableLayout := fset.String("table-layout", "", "table layout: default, center, wide, full-width")
+	tableNumberColumn := fset.Bool("table-number-column", false, "enable the numbered first column of tables")
+	tableWidths := fset.String("table-widths", "", "comma-separated column widths in pixels")
+	tableDisplayMode := fset.String("table-display-mode", "", "table displayMode: default, fixed")
+	alerts := map[string]string{}
+	fset.Func("alert", "map an alert to a panel type, as NAME=panel (repeatable)", func(s string) error {
+		name, panel, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("expected NAME=panel, got %q", s)
+		}
+		alerts[name] = panel
+		return nil
+	})
+	var smartLinks []adf.SmartLinkRule
+	fset.Func("smart-link", "render autolinks to HOST as smart links, as HOST=INLINE,STANDALONE with none, inline, block or embed (repeatable)", func(s string) error {
+		rule, err := parseSmartLink(s)
+		if err != nil {
+			return err
+		}
+		smartLinks = append(smartLinks, rule)
+		return nil
+	})
+	hardWraps := fset.Bool("hard-wraps", false, "render soft line breaks as hardBreak nodes")
+	htmlPolicy := fset.String("html", "drop", "raw HTML handling: drop, text, code, translate")
+	markConflicts := fset.String("mark-conflicts", "keep-code", "resolve code combined with other marks: keep-code, drop-code, fail")
+	mentions := fset.String("mentions", "", "JSON file mapping usernames to users for @username mentions")
+	emoji := fset.String("emoji", "", "JSON file mapping short names to custom emoji for :shortcodes:")
+	statusSyntax := fset.String("status-syntax", "", `status lozenge delimiters as "OPEN SEPARATOR CLOSE", e.g. "[[ : ]]"`)
+	timezone := fset.String("timezone", "", "time zone of {date:...} macros without one, e.g. Europe/Berlin (default UTC)")
+	unknownMacros := fset.String("unknown-macros", "code", "confluence-macro fences naming unknown macros: code, error")
+	definitionLists := fset.String("definition-lists", "blockquote", "definition list style: blockquote, indented, table, bullet-list")
+	inlineFootnotes := fset.Bool("inline-footnotes", false, "render footnotes in parentheses where they are referenced")
+	diagnostics := fset.Bool("diagnostics", false, "print conversion diagnostics to stderr")
+	compact := fset.Bool("compact", false, "write compact instead of indented JSON")
+	validate := fset.Bool("validate", false, "validate output against the ADF schema before writing")
+	output := fset.String("o", "", "output file for a single input")
+	outDir := fset.String("out-dir", "", "root of the mirrored output tree for directories")
+
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	opts := []adf.Option{adf.WithExternalMedia(*externalMedia), adf.WithHardWraps(*hardWraps), adf.WithInlineFootnotes(*inlineFootnotes)}
+	if *imageLayout != "" {
+		opts = append(opts, adf.WithImageLayout(*imageLayout))
+	}
+	if *tableLayout != "" {
+		opts = append(opts, adf.WithTableLayout(*tableLayout))
+	}
+	if *tableNumberColumn {
+		opts = append(opts, adf.WithTableNumberColumn(true))
+	}
+	if *tableWidths != "" {
+		widths, err := parseWidths(*tableWidths)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, adf.WithTableColumnWidths(widths...))
+	}
+	if *tableDisplayMode != "" {
+		opts = append(opts, adf.WithTableDisplayMode(*tableDisplayMode))
+	}
+	if len(alerts) > 0 {
+		opts = append(opts, adf.WithAlertPanels(alerts))
+	}
+	if len(smartLinks) > 0 {
+		opts = append(opts, adf.WithSmartLinks(smartLinks...))
+	}
+
+	switch *htmlPolicy {
+	case "drop":
+		opts = append(opts, adf.WithHTMLPolicy(adf.HTMLDrop))
+	case "text":
+		opts = append(opts, adf.WithHTMLPolicy(adf.HTMLText))
+	case "code":
+		opts = append(opts, adf.WithHTMLPolicy(adf.HTMLCodeBlock))
+	case "translate":
+		opts = append(opts, adf.WithHTMLPolicy(adf.HTMLTranslate))
+	default:
+		return fmt.Errorf("invalid -html %q", *htmlPolicy)
+	}
+	switch *markConflicts {
+	case "keep-code":
+		opts = append(opts, adf.WithMarkConflicts(adf.MarkConflictKeepCode))
+	case "drop-code":
+		opts = append(opts, adf.WithMarkConflicts(adf.MarkConflictDropCode))
+	case "fail":
+		opts = append(opts, adf.WithMarkConflicts(adf.MarkConflictFail))
+	default:
+		return fmt.Errorf("invalid -mark-conflicts %q", *markConflicts)
+	}
+	switch *unknownMacros {
+	case "code":
+		opts = append(opts, adf.WithUnknownMacros(adf.UnknownMacroCodeBlock))
+	case "error":
+		opts = append(opts, adf.WithUnknownMacros(adf.UnknownMacroError))
+	default:
+		return fmt.Errorf("invalid -unknown-macros %q", *unknownMacros)
+	}
+	switch *definitionLists {
+	case "blockquote":
+		opts = append(opts, adf.WithDefinitionLists(adf.DefinitionListBlockquote))
+	case "indented":
+		opts = append(opts, adf.WithDefinitionLists(adf.DefinitionListIndented))
+	case "table":
+		opts = append(opts, adf.WithDefinitionLists(adf.DefinitionListTable))
+	case "bullet-list":
+		opts = append(opts, adf.WithDefinitionLists(adf.DefinitionListBulletList))
+	default:
+		return fmt.Errorf("invalid -definition-lists %q", *definitionLists)
+	}
+
+	if *mentions != "" {
+		directory, err := loadMentions(*mentions)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, adf.WithMentionResolver(directory))
+	}
+
+	if *emoji != "" {
+		custom, err := loadEmoji(*emoji)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, adf.WithCustomEmoji(custom))
+	}
+
+	if *statusSyntax != "" {
+		fields := strings.Fields(*statusSyntax)
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid -status-syntax %q, expected \"OPEN SEPARATOR CLOSE\"", *statusSyntax)
+		}
+		opts = append(opts, adf.WithStatusSyntax(adf.StatusSyntax{Open: fields[0], Separator: fields[1], Close: fields[2]}))
+	}
+
+	if *timezone != "" {
+		loc, err := time.LoadLocation(*timezone)
+		if err != nil {
+			return fmt.Errorf("invalid -timezone: %w", err)
+		}
+		opts = append(opts, adf.WithDateLocation(loc))
+	}
+
+	c := &converter{compact: *compact, validate: *validate}
+	if *diagnostics {
+		opts = append(opts, adf.WithDiagnosticHandler(func(d adf.Diagnostic) {
+			fmt.Fprintf(stderr, "%s:%s\n", c.name, d)
+		}))
+	}
+	if *gfm {
+		c.md = adf.NewWithGFM(opts...)
+	} else {
+		c.md = adf.New(opts...)
+	}
+
+	inputs := fset.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	if *output != "" && len(inputs) > 1 {
+		return errors.New("-o requires a single input")
+	}
+
+	for _, in := range inputs {
+		if in == "-" {
+			if err := c.convertReader(stdin, "<stdin>", *output, stdout); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := os.Stat(in)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if *output != "" {
+				return errors.New("-o cannot be used with a directory; use -out-dir")
+			}
+			if err := c.convertDir(in, *outDir); err != nil {
+				return err
+			}
+			continue
+		}
+		f, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		err = c.convertReader(f, in, *output, stdout)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseWidths parses a comma-separated list of column widths.
+func parseWidths(s string) ([]int, error) {
+	var widths []int
+	for _, field := range strings.Split(s, ",") {
+		w, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid table width %q", field)
+		}
+		widths = append(widths, w)
+	}
+	return widths, nil
+}
+
+// smartLinkAppearances maps -smart-link appearance names to their values.
+var smartLinkAppearances = map[string]adf.SmartLinkAppearance{
+	"none":   adf.SmartLinkNone,
+	"inline": adf.SmartLinkInline,
+	"block":  adf.SmartLinkBlock,
+	"embed":  adf.SmartLinkEmbed,
+}
+
+// parseSmartLink parses a HOST=INLINE,STANDALONE smart link rule.
+func parseSmartLink(s string) (adf.SmartLinkRule, error) {
+	host, appearances, _ := strings.Cut(s, "=")
+	inline, standalone, _ := strings.Cut(appearances, ",")
+	rule := adf.SmartLinkRule{Host: host}
+	var inlineOK, standaloneOK bool
+	rule.Inline, inlineOK = smartLinkAppearances[inline]
+	rule.Standalone, standaloneOK = smartLinkAppearances[standalone]
+	if host == "" || !inlineOK || !standaloneOK {
+		return adf.SmartLinkRule{}, fmt.Errorf("expected HOST=INLINE,STANDALONE with none, inline, block or embed, got %q", s)
+	}
+	return rule, nil
+}
+
+// loadMentions reads a JSON file mapping usernames to mention users.
+func loadMentions(name string) (adf.MentionDirectory, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var directory adf.MentionDirectory
+	if err := json.Unmarshal(data, &directory); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return directory, nil
+}
+
+// loadEmoji reads a JSON file mapping short names to custom emoji.
+func loadEmoji(name string) (map[string]adf.Emoji, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var emoji map[string]adf.Emoji
+	if err := json.Unmarshal(data, &emoji); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return emoji, nil
+}
+
+// convert converts Markdown source to ADF JSON in the configured format.
+func (c *converter) convert(source []byte, name string) ([]byte, error) {
+	c.name = name
+	var buf bytes.Buffer
+	if err := c.md.Convert(source, &buf); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	out := buf.Bytes()
+	if c.validate {
+		if err := adfschema.Validate(out); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if c.compact {
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, out); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		out = compacted.Bytes()
+	}
+	return append(out, '\n'), nil
+}
+
+// convertReader converts Markdown read from r and writes it to the file
+// output, or to w if output is empty.
+func (c *converter) convertReader(r io.Reader, name, output string, w io.Writer) error {
+	source, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	out, err := c.convert(source, name)
+	if err != nil {
+		return err
+	}
+	if output != "" {
+		return os.WriteFile(output, out, 0o644)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// convertDir converts every Markdown file under root, writing each result to
+// the mirrored path under outDir, or next to the source if outDir is empty.
+func (c *converter) convertDir(root, outDir string) error {
+	if outDir == "" {
+		outDir = root
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isMarkdown(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		out, err := c.convert(source, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(outDir, strings.TrimSuffix(rel, filepath.Ext(rel))+".adf.json")
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(dest, out, 0o644)
+	})
+}
+
+// isMarkdown reports whether path has a Markdown file extension.
+func isMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
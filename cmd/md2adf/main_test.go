@@ -0,0 +1,238 @@
+//go:build goexperiment.jsonv2
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ajbeck/goldmark-adf/adfschema"
+)
+
+func TestRun_Stdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-validate"}, strings.NewReader("Hello ~~world~~"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v\nstderr: %s", err, stderr.String())
+	}
+
+	if err := adfschema.Validate(stdout.Bytes()); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "\n  \"type\": \"doc\"") {
+		t.Errorf("Expected indented output, got %s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), `"strike"`) {
+		t.Errorf("Expected GFM strikethrough by default, got %s", stdout.String())
+	}
+}
+
+func TestRun_Compact(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-compact", "-gfm=false"}, strings.NewReader("Hello"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	want := `{"version":1,"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"Hello"}]}]}` + "\n"
+	if stdout.String() != want {
+		t.Errorf("Expected %s, got %s", want, stdout.String())
+	}
+}
+
+func TestRun_Options(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"-compact", "-table-layout", "wide", "-table-widths", "100,200", "-table-number-column", "-html", "translate", "-timezone", "UTC", "-status-syntax", "[[ : ]]"}
+	err := run(args, strings.NewReader("| A | B |\n|---|---|\n| 1 [[Done:green]] | 2<sup>nd</sup> {date:2026-11-01} |"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	for _, want := range []string{`"layout":"wide"`, `"isNumberColumnEnabled":true`, `"colwidth":[200]`, `"subsup"`, `"timestamp":"1793491200000"`, `"color":"green"`} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected output to contain %s, got %s", want, stdout.String())
+		}
+	}
+}
+
+func TestRun_Diagnostics(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-diagnostics"}, strings.NewReader("text\n\n<div>x</div>"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	want := "<stdin>:3:1: warning: raw HTML \"<div>x</div>\" dropped (html-dropped)\n"
+	if stderr.String() != want {
+		t.Errorf("Expected %q, got %q", want, stderr.String())
+	}
+}
+
+func TestRun_Mentions(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"-compact", "-mentions", filepath.Join("..", "..", "testdata", "mentions.json")}
+	err := run(args, strings.NewReader("Thanks @alice"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	want := `{"type":"mention","attrs":{"id":"5b10ac8d82e05b22cc7d4ef5","text":"@Alice Smith"}}`
+	if !strings.Contains(stdout.String(), want) {
+		t.Errorf("Expected output to contain %s, got %s", want, stdout.String())
+	}
+}
+
+func TestRun_Emoji(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"-compact", "-emoji", filepath.Join("..", "..", "testdata", "emoji.json")}
+	err := run(args, strings.NewReader("Shipped :shipit:"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	want := `{"type":"emoji","attrs":{"id":"atlassian-shipit","shortName":":shipit:","text":"🐿️"}}`
+	if !strings.Contains(stdout.String(), want) {
+		t.Errorf("Expected output to contain %s, got %s", want, stdout.String())
+	}
+}
+
+func TestRun_SmartLinks(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"-compact", "-smart-link", "*.atlassian.net=inline,block"}
+	err := run(args, strings.NewReader("See https://example.atlassian.net/browse/ADF-1\n\nhttps://example.atlassian.net/wiki"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	for _, want := range []string{`{"type":"inlineCard","attrs":{"url":"https://example.atlassian.net/browse/ADF-1"}}`, `{"type":"blockCard","attrs":{"url":"https://example.atlassian.net/wiki"}}`} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected output to contain %s, got %s", want, stdout.String())
+		}
+	}
+}
+
+func TestRun_InlineFootnotes(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-compact", "-inline-footnotes"}, strings.NewReader("Text[^1]\n\n[^1]: Note"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	want := `{"version":1,"type":"doc","content":[{"type":"paragraph","content":[{"type":"text","text":"Text (Note)"}]}]}` + "\n"
+	if stdout.String() != want {
+		t.Errorf("Expected %s, got %s", want, stdout.String())
+	}
+}
+
+func TestRun_DefinitionLists(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-compact", "-definition-lists", "bullet-list"}, strings.NewReader("Term\n: Description"), &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	want := `{"version":1,"type":"doc","content":[{"type":"bulletList","content":[{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","marks":[{"type":"strong"}],"text":"Term"}]},{"type":"paragraph","content":[{"type":"text","text":"Description"}]}]}]}]}` + "\n"
+	if stdout.String() != want {
+		t.Errorf("Expected %s, got %s", want, stdout.String())
+	}
+}
+
+func TestRun_OutputFile(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.md")
+	out := filepath.Join(dir, "out.json")
+	if err := os.WriteFile(in, []byte("# Title"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-o", out, in}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no stdout, got %s", stdout.String())
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if err := adfschema.Validate(data); err != nil {
+		t.Errorf("Invalid ADF output: %v\nOutput: %s", err, data)
+	}
+}
+
+func TestRun_Directory(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	files := map[string]string{
+		"README.md":              "# Readme",
+		"guides/setup.markdown":  "Setup *steps*",
+		"guides/notes.txt":       "not markdown",
+		"guides/deep/runbook.md": "- one\n- two",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"-validate", "-out-dir", dst, src}, nil, &stdout, &stderr); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	for _, name := range []string{"README.adf.json", "guides/setup.adf.json", "guides/deep/runbook.adf.json"} {
+		data, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Errorf("Expected %s: %v", name, err)
+			continue
+		}
+		if err := adfschema.Validate(data); err != nil {
+			t.Errorf("Invalid ADF in %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "guides/notes.adf.json")); err == nil {
+		t.Error("Expected non-Markdown file to be skipped")
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+	}{
+		{name: "bad width", args: []string{"-table-widths", "10,x"}},
+		{name: "bad alert", args: []string{"-alert", "NOTE"}},
+		{name: "bad smart link", args: []string{"-smart-link", "example.com=inline"}},
+		{name: "bad smart link appearance", args: []string{"-smart-link", "example.com=inline,card"}},
+		{name: "bad html policy", args: []string{"-html", "keep"}},
+		{name: "bad mark policy", args: []string{"-mark-conflicts", "maybe"}},
+		{name: "missing mentions file", args: []string{"-mentions", filepath.Join(t.TempDir(), "missing.json")}},
+		{name: "missing emoji file", args: []string{"-emoji", filepath.Join(t.TempDir(), "missing.json")}},
+		{name: "bad status syntax", args: []string{"-status-syntax", "{status: }"}},
+		{name: "bad timezone", args: []string{"-timezone", "Nowhere/Special"}},
+		{name: "bad definition list style", args: []string{"-definition-lists", "dl"}},
+		{name: "bad unknown macro policy", args: []string{"-unknown-macros", "drop"}},
+		{name: "unknown macro", args: []string{"-unknown-macros", "error"}, stdin: "```confluence-macro gallery\n```"},
+		{name: "mark conflict", args: []string{"-mark-conflicts", "fail"}, stdin: "**`x`**"},
+		{name: "output with several inputs", args: []string{"-o", "out.json", "a.md", "b.md"}},
+		{name: "missing file", args: []string{filepath.Join(t.TempDir(), "missing.md")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
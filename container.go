@@ -0,0 +1,478 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindContainer is a NodeKind of the Container node.
+var KindContainer = ast.NewNodeKind("Container")
+
+// Container is a block node representing a :::name {attrs} fenced container.
+// Its children are the Markdown blocks between the fences.
+type Container struct {
+	ast.BaseBlock
+
+	// Name is the container name, e.g. "panel".
+	Name string
+
+	// Attrs is the attribute list as written, without braces, e.g.
+	// `panelType=warning`. It is parsed when rendering.
+	Attrs string
+
+	// fence is the opening fence line, which locates the container in errors
+	// and diagnostics.
+	fence text.Segment
+}
+
+// NewContainer creates a new Container node.
+func NewContainer(name, attrs string) *Container {
+	return &Container{Name: name, Attrs: attrs}
+}
+
+// Kind implements ast.Node.Kind.
+func (n *Container) Kind() ast.NodeKind {
+	return KindContainer
+}
+
+// Dump implements ast.Node.Dump.
+func (n *Container) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.Name, "Attrs": n.Attrs}, nil)
+}
+
+// ContainerHandler creates the ADF node a :::name container renders as. The
+// node is returned without content; the container's blocks are rendered into
+// it. An error stops the conversion with a [*ContainerError].
+type ContainerHandler func(c ContainerContext) (*Node, error)
+
+// ContainerContext describes a container passed to a [ContainerHandler].
+type ContainerContext struct {
+	// Name is the container name, e.g. "panel".
+	Name string
+
+	// Attrs holds the attributes written on the opening fence. Quoted values
+	// are strings; unquoted values are bool, int or float64 where they parse
+	// as one, and strings otherwise.
+	Attrs map[string]any
+
+	// ParentType is the ADF type of the node the container is in, e.g. "doc"
+	// or "expand".
+	ParentType string
+}
+
+// defaultContainers returns the built-in container handlers.
+func defaultContainers() map[string]ContainerHandler {
+	return map[string]ContainerHandler{
+		"panel":           panelContainer,
+		"expand":          expandContainer,
+		"layoutSection":   layoutSectionContainer,
+		"columns":         layoutSectionContainer,
+		"layoutColumn":    layoutColumnContainer,
+		"column":          layoutColumnContainer,
+		"bodiedExtension": bodiedExtensionContainer,
+	}
+}
+
+var (
+	containerOpenRegexp  = regexp.MustCompile(`^:{3,}[ \t]*([A-Za-z][A-Za-z0-9_-]*)(?:[ \t]+(.*?))?[ \t]*\n?$`)
+	containerCloseRegexp = regexp.MustCompile(`^:{3,}[ \t]*\n?$`)
+)
+
+// containerParser parses :::name fenced containers.
+type containerParser struct {
+	names map[string]bool
+}
+
+// NewContainerParser returns a parser.BlockParser that parses fenced
+// containers opened by a :::name line, optionally followed by attributes
+// such as {title="Read me" localId=abc}, into [Container] nodes. Only the
+// given names open a container. A line of three or more colons closes the
+// innermost open container, so containers nest:
+//
+//	:::columns
+//	:::column width=50
+//	Before
+//	:::
+//	:::column width=50
+//	After
+//	:::
+//	:::
+func NewContainerParser(names ...string) parser.BlockParser {
+	p := &containerParser{names: map[string]bool{}}
+	for _, name := range names {
+		p.names[name] = true
+	}
+	return p
+}
+
+// Trigger implements parser.BlockParser.
+func (p *containerParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+// Open implements parser.BlockParser.
+func (p *containerParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+	m := containerOpenRegexp.FindSubmatch(line[pos:])
+	if m == nil || !p.names[string(m[1])] {
+		return nil, parser.NoChildren
+	}
+	attrs := string(m[2])
+	if strings.HasPrefix(attrs, "{") && strings.HasSuffix(attrs, "}") {
+		attrs = strings.TrimSpace(attrs[1 : len(attrs)-1])
+	}
+	node := NewContainer(string(m[1]), attrs)
+	node.fence = text.NewSegment(segment.Start+pos, segment.Stop)
+	reader.Advance(segment.Len() - util.TrimRightSpaceLength(line))
+	return node, parser.HasChildren
+}
+
+// Continue implements parser.BlockParser.
+func (p *containerParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w < 4 && containerCloseRegexp.Match(line[pos:]) && isInnermostContainer(node, pc) {
+		reader.Advance(segment.Len() - util.TrimRightSpaceLength(line))
+		return parser.Close
+	}
+	return parser.Continue | parser.HasChildren
+}
+
+// isInnermostContainer reports whether node is the innermost open container
+// or macro, so that a closing fence belongs to it. A fence inside an open code
+// or HTML block is part of that block.
+func isInnermostContainer(node ast.Node, pc parser.Context) bool {
+	blocks := pc.OpenedBlocks()
+	for i, b := range blocks {
+		if b.Node != node {
+			continue
+		}
+		for _, inner := range blocks[i+1:] {
+			switch inner.Node.(type) {
+			case *Container, *ConfluenceMacro:
+				return false
+			}
+			if inner.Node.IsRaw() {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Close implements parser.BlockParser.
+func (p *containerParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	// nothing to do
+}
+
+// CanInterruptParagraph implements parser.BlockParser.
+func (p *containerParser) CanInterruptParagraph() bool {
+	return true
+}
+
+// CanAcceptIndentedLine implements parser.BlockParser.
+func (p *containerParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// addContainerParser adds the container parser for the registered names.
+func addContainerParser(md goldmark.Markdown, handlers map[string]ContainerHandler) {
+	var names []string
+	for name, handler := range handlers {
+		if handler != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	md.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(NewContainerParser(names...), 750),
+		),
+	)
+}
+
+// containerAttrRegexp matches one attribute: a name, '=' and a value that is
+// double-quoted (with backslash escapes), single-quoted or unquoted.
+var containerAttrRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_-]*)=(?:"((?:[^"\\]|\\.)*)"|'([^']*)'|([^\s"'{}]+))(?:\s+|$)`)
+
+// parseContainerAttrs parses a whitespace-separated list of name=value
+// attributes.
+func parseContainerAttrs(s string) (map[string]any, error) {
+	attrs := map[string]any{}
+	s = strings.TrimSpace(s)
+	for s != "" {
+		m := containerAttrRegexp.FindStringSubmatchIndex(s)
+		if m == nil {
+			return nil, fmt.Errorf("invalid attribute %q, expected name=value", strings.Fields(s)[0])
+		}
+		name := s[m[2]:m[3]]
+		var value any
+		switch {
+		case m[4] >= 0:
+			value = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s[m[4]:m[5]])
+		case m[6] >= 0:
+			value = s[m[6]:m[7]]
+		default:
+			value = parseAttrValue(s[m[8]:m[9]])
+		}
+		if _, dup := attrs[name]; dup {
+			return nil, fmt.Errorf("attribute %q given twice", name)
+		}
+		attrs[name] = value
+		s = s[m[1]:]
+	}
+	return attrs, nil
+}
+
+// parseAttrValue converts an unquoted attribute value to a bool, int or
+// float64 where it parses as one.
+func parseAttrValue(s string) any {
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// stringAttrs checks that attrs only holds the allowed names and returns them
+// with every value converted to a string.
+func stringAttrs(attrs map[string]any, allowed ...string) (map[string]any, error) {
+	out := make(map[string]any, len(attrs))
+	for name, value := range attrs {
+		if !slices.Contains(allowed, name) {
+			return nil, fmt.Errorf("unknown attribute %q; allowed: %s", name, strings.Join(allowed, ", "))
+		}
+		out[name] = fmt.Sprint(value)
+	}
+	return out, nil
+}
+
+// panelTypes lists the types a panel may have.
+var panelTypes = []string{"info", "note", "tip", "warning", "error", "success", "custom"}
+
+// panelContainer renders :::panel {panelType=warning} as a panel, an info
+// panel if no type is given.
+func panelContainer(c ContainerContext) (*Node, error) {
+	attrs, err := stringAttrs(c.Attrs, "panelType", "panelColor", "panelIcon", "panelIconId", "panelIconText", "localId")
+	if err != nil {
+		return nil, err
+	}
+	if attrs["panelType"] == nil {
+		attrs["panelType"] = "info"
+	}
+	if !slices.Contains(panelTypes, attrs["panelType"].(string)) {
+		return nil, fmt.Errorf("panelType %q is not one of %s", attrs["panelType"], strings.Join(panelTypes, ", "))
+	}
+	n := NewPanel("")
+	n.Attrs = attrs
+	return n, nil
+}
+
+// expandContainer renders :::expand {title="More"} as an expand, or a
+// nestedExpand inside another expand.
+func expandContainer(c ContainerContext) (*Node, error) {
+	attrs, err := stringAttrs(c.Attrs, "title", "localId")
+	if err != nil {
+		return nil, err
+	}
+	n := NewExpand("")
+	if c.ParentType == "expand" {
+		n = NewNestedExpand("")
+	}
+	n.Attrs = attrs
+	return n, nil
+}
+
+// bodiedExtensionContainer renders :::bodiedExtension {extensionType=...
+// extensionKey=...} as a bodiedExtension.
+func bodiedExtensionContainer(c ContainerContext) (*Node, error) {
+	attrs, err := stringAttrs(c.Attrs, "extensionType", "extensionKey", "layout", "localId", "text")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"extensionType", "extensionKey"} {
+		if attrs[name] == nil || attrs[name] == "" {
+			return nil, fmt.Errorf("attribute %q is required", name)
+		}
+	}
+	if layout, ok := attrs["layout"]; ok && layout != "default" && layout != "wide" && layout != "full-width" {
+		return nil, fmt.Errorf("layout %q is not one of default, wide, full-width", layout)
+	}
+	return &Node{Type: "bodiedExtension", Attrs: attrs, Content: []Node{}}, nil
+}
+
+// contentRule describes the content the ADF schema allows in a block node.
+type contentRule struct {
+	// types lists the node types the content may hold.
+	types []string
+
+	// min and max bound the number of nodes; max 0 means unbounded.
+	min, max int
+}
+
+// blockContent lists the block nodes allowed in the document and in the
+// nodes that may hold most blocks.
+var blockContent = []string{"blockCard", "blockquote", "bodiedExtension", "bulletList", "codeBlock", "decisionList", "embedCard", "expand", "extension", "heading", "mediaGroup", "mediaSingle", "orderedList", "panel", "paragraph", "rule", "table", "taskList"}
+
+// tableCellContent lists the block nodes allowed in table cells.
+var tableCellContent = []string{"blockCard", "blockquote", "bulletList", "codeBlock", "decisionList", "embedCard", "extension", "heading", "mediaGroup", "mediaSingle", "nestedExpand", "orderedList", "panel", "paragraph", "rule", "taskList"}
+
+// contentRules describes the content of the block nodes containers may render
+// as or be placed in, following the ADF schema.
+var contentRules = map[string]contentRule{
+	"doc":             {types: append(slices.Clone(blockContent), "layoutSection")},
+	"layoutSection":   {types: []string{"layoutColumn"}, min: 2, max: 3},
+	"layoutColumn":    {types: blockContent, min: 1},
+	"panel":           {types: []string{"blockCard", "bulletList", "codeBlock", "decisionList", "extension", "heading", "mediaGroup", "mediaSingle", "orderedList", "paragraph", "rule", "taskList"}, min: 1},
+	"expand":          {types: []string{"blockCard", "blockquote", "bulletList", "codeBlock", "decisionList", "embedCard", "extension", "heading", "mediaGroup", "mediaSingle", "nestedExpand", "orderedList", "panel", "paragraph", "rule", "table", "taskList"}, min: 1},
+	"nestedExpand":    {types: []string{"blockquote", "bulletList", "codeBlock", "decisionList", "extension", "heading", "mediaGroup", "mediaSingle", "orderedList", "panel", "paragraph", "rule", "taskList"}, min: 1},
+	"bodiedExtension": {types: []string{"blockCard", "blockquote", "bulletList", "codeBlock", "decisionList", "embedCard", "extension", "heading", "mediaGroup", "mediaSingle", "orderedList", "panel", "paragraph", "rule", "table", "taskList"}, min: 1},
+	"listItem":        {types: []string{"bulletList", "codeBlock", "extension", "mediaSingle", "orderedList", "paragraph", "taskList"}, min: 1},
+	"blockquote":      {types: []string{"bulletList", "codeBlock", "extension", "mediaGroup", "mediaSingle", "orderedList", "paragraph"}, min: 1},
+	"tableCell":       {types: tableCellContent, min: 1},
+	"tableHeader":     {types: tableCellContent, min: 1},
+}
+
+// canContain reports whether the ADF schema allows a node of nodeType in a
+// node of parentType. Parents without a content rule accept any node.
+func canContain(parentType, nodeType string) bool {
+	rule, ok := contentRules[parentType]
+	return !ok || slices.Contains(rule.types, nodeType)
+}
+
+// checkPlacement checks that a node of parentType may hold n, and that n may
+// hold its content (see checkContent).
+func checkPlacement(parentType string, n *Node) error {
+	if !canContain(parentType, n.Type) {
+		return fmt.Errorf("%s cannot contain %s", parentType, n.Type)
+	}
+	return checkContent(n)
+}
+
+// checkContent checks the content of a node rendered from a container against
+// its content rule, if it has one. A node that requires content but has none
+// is given an empty paragraph.
+func checkContent(n *Node) error {
+	rule, ok := contentRules[n.Type]
+	if !ok {
+		return nil
+	}
+	if len(n.Content) == 0 && rule.min > 0 && slices.Contains(rule.types, "paragraph") {
+		n.AppendChild(*NewParagraph())
+	}
+	for _, c := range n.Content {
+		if !slices.Contains(rule.types, c.Type) {
+			return fmt.Errorf("%s cannot contain %s", n.Type, c.Type)
+		}
+	}
+	if len(n.Content) < rule.min || rule.max > 0 && len(n.Content) > rule.max {
+		if rule.max == rule.min+1 {
+			return fmt.Errorf("%s must contain %d or %d %s nodes, found %d", n.Type, rule.min, rule.max, strings.Join(rule.types, " or "), len(n.Content))
+		}
+		return fmt.Errorf("%s must contain at least %d nodes, found %d", n.Type, rule.min, len(n.Content))
+	}
+	if n.Type == "layoutSection" {
+		return resolveColumnWidths(n)
+	}
+	return nil
+}
+
+// ContainerError is returned when a :::name container cannot be rendered, for
+// instance because its attributes are invalid or its content is not allowed
+// in the ADF node it renders as.
+type ContainerError struct {
+	// Line and Column are the 1-based position of the container's opening
+	// fence in the Markdown source, or 0 if unknown.
+	Line   int
+	Column int
+
+	// Name is the container name.
+	Name string
+
+	// Err describes the problem.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ContainerError) Error() string {
+	return fmt.Sprintf("adf: %d:%d: :::%s: %v", e.Line, e.Column, e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ContainerError) Unwrap() error {
+	return e.Err
+}
+
+// containerError reports a problem with a container as an error diagnostic
+// and returns it as a [*ContainerError].
+func (r *Renderer) containerError(st *renderState, n *Container, err error) error {
+	r.report(st, n, SeverityError, DiagnosticContainerInvalid, ":::%s: %v", n.Name, err)
+	cerr := &ContainerError{Name: n.Name, Err: err}
+	if offset, ok := sourceOffset(n); ok {
+		cerr.Line, cerr.Column = sourcePosition(st.source, offset)
+	}
+	return cerr
+}
+
+// parentType returns the ADF type of the node currently being built, or
+// "doc" at the top level.
+func (s *renderState) parentType() string {
+	if current := s.currentNode(); current != nil {
+		return current.Type
+	}
+	return "doc"
+}
+
+func (r *Renderer) renderContainer(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	n := node.(*Container)
+	if !entering {
+		current := st.currentNode()
+		if err := checkContent(current); err != nil {
+			return ast.WalkStop, r.containerError(st, n, err)
+		}
+		st.popNode()
+		return ast.WalkContinue, nil
+	}
+
+	attrs, err := parseContainerAttrs(n.Attrs)
+	if err != nil {
+		return ast.WalkStop, r.containerError(st, n, err)
+	}
+	parentType := st.parentType()
+	block, err := r.config.Containers[n.Name](ContainerContext{Name: n.Name, Attrs: attrs, ParentType: parentType})
+	if err == nil && block == nil {
+		err = fmt.Errorf("handler returned no node")
+	}
+	if err != nil {
+		return ast.WalkStop, r.containerError(st, n, err)
+	}
+	if rule, ok := contentRules[parentType]; ok && !slices.Contains(rule.types, block.Type) {
+		return ast.WalkStop, r.containerError(st, n, fmt.Errorf("%s cannot contain %s", parentType, block.Type))
+	}
+	st.pushNode(block)
+	return ast.WalkContinue, nil
+}
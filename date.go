@@ -0,0 +1,137 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindDateMacro is a NodeKind of the DateMacro node.
+var KindDateMacro = ast.NewNodeKind("DateMacro")
+
+// DateMacro is an inline node representing a date such as {date:2026-11-01}.
+// Its single child is the macro as written.
+type DateMacro struct {
+	ast.BaseInline
+
+	// Value is the date as written, trimmed. It is parsed when rendering.
+	Value string
+}
+
+// NewDateMacro creates a new DateMacro node.
+func NewDateMacro(value string) *DateMacro {
+	return &DateMacro{Value: value}
+}
+
+// Kind implements ast.Node.Kind.
+func (n *DateMacro) Kind() ast.NodeKind {
+	return KindDateMacro
+}
+
+// Dump implements ast.Node.Dump.
+func (n *DateMacro) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Value": n.Value}, nil)
+}
+
+// dateLayouts lists the accepted date formats, tried in order.
+var dateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+}
+
+// parseDate parses a date in one of the accepted formats. Dates without a
+// time zone are in loc.
+func parseDate(value string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var dateMacroRegexp = regexp.MustCompile(`^\{date:([^}\n]+)\}`)
+
+// dateParser parses {date:...} macros.
+type dateParser struct{}
+
+// NewDateParser returns a parser.InlineParser that parses {date:...} macros
+// into [DateMacro] nodes.
+func NewDateParser() parser.InlineParser {
+	return &dateParser{}
+}
+
+// Trigger implements parser.InlineParser.
+func (p *dateParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+// Parse implements parser.InlineParser.
+func (p *dateParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	m := dateMacroRegexp.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	value := strings.TrimSpace(string(m[1]))
+	if value == "" {
+		return nil
+	}
+
+	node := NewDateMacro(value)
+	node.AppendChild(node, ast.NewTextSegment(segment.WithStop(segment.Start+len(m[0]))))
+	block.Advance(len(m[0]))
+	return node
+}
+
+// addDateParser adds the date macro parser.
+func addDateParser(md goldmark.Markdown) {
+	md.Parser().AddOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(NewDateParser(), 150),
+		),
+	)
+}
+
+func (r *Renderer) renderDateMacro(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	st := r.state(node)
+	n := node.(*DateMacro)
+
+	loc := r.config.DateLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, ok := parseDate(n.Value, loc)
+	if !ok {
+		// Keep the macro as written
+		r.report(st, node, SeverityWarning, DiagnosticDateInvalid, "date %q is not in a recognised format; rendered as text", n.Value)
+		textNode, err := r.newMarkedText(st, node, string(n.FirstChild().(*ast.Text).Segment.Value(source)))
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		st.appendToCurrentOrDocument(*textNode)
+		return ast.WalkSkipChildren, nil
+	}
+	st.appendToCurrentOrDocument(*NewDate(t))
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,146 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"regexp"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindDecisionMarker is a NodeKind of the DecisionMarker node.
+var KindDecisionMarker = ast.NewNodeKind("DecisionMarker")
+
+// DecisionMarker is an inline node representing the [D] or [d] marker that
+// starts a decision list item, like the checkbox of a task list item.
+type DecisionMarker struct {
+	ast.BaseInline
+
+	// IsDecided is true for [D] and false for [d].
+	IsDecided bool
+}
+
+// NewDecisionMarker creates a new DecisionMarker node.
+func NewDecisionMarker(decided bool) *DecisionMarker {
+	return &DecisionMarker{IsDecided: decided}
+}
+
+// Kind implements ast.Node.Kind.
+func (n *DecisionMarker) Kind() ast.NodeKind {
+	return KindDecisionMarker
+}
+
+// Dump implements ast.Node.Dump.
+func (n *DecisionMarker) Dump(source []byte, level int) {
+	decided := "false"
+	if n.IsDecided {
+		decided = "true"
+	}
+	ast.DumpHelper(n, source, level, map[string]string{"Decided": decided}, nil)
+}
+
+var decisionMarkerRegexp = regexp.MustCompile(`^\[([Dd])\](?:\s+|$)`)
+
+// decisionMarkerParser parses decision markers at the start of list items.
+type decisionMarkerParser struct{}
+
+// NewDecisionMarkerParser returns a parser.InlineParser that parses a [D]
+// (decided) or [d] (undecided) marker followed by whitespace at the start of a
+// list item into a [DecisionMarker]. Like the task list checkbox parser, it
+// must take precedence over the link parser.
+func NewDecisionMarkerParser() parser.InlineParser {
+	return &decisionMarkerParser{}
+}
+
+// Trigger implements parser.InlineParser.
+func (p *decisionMarkerParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+// Parse implements parser.InlineParser.
+func (p *decisionMarkerParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	// Only the first text of the first block of a list item
+	if parent.Parent() == nil || parent.Parent().FirstChild() != parent || parent.HasChildren() {
+		return nil
+	}
+	if _, ok := parent.Parent().(*ast.ListItem); !ok {
+		return nil
+	}
+	line, _ := block.PeekLine()
+	m := decisionMarkerRegexp.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	block.Advance(len(m[0]))
+	return NewDecisionMarker(m[1][0] == 'D')
+}
+
+// addDecisionParser adds the decision marker parser.
+func addDecisionParser(md goldmark.Markdown) {
+	md.Parser().AddOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(NewDecisionMarkerParser(), 0),
+		),
+	)
+}
+
+// decisionMarker returns the decision marker that starts a list item, or nil
+// if the item is not a decision item.
+func decisionMarker(item ast.Node) *DecisionMarker {
+	block := item.FirstChild()
+	if block == nil {
+		return nil
+	}
+	marker, _ := block.FirstChild().(*DecisionMarker)
+	return marker
+}
+
+// isDecisionItem reports whether a list item can be rendered as an ADF
+// decisionItem. The item must start with a decision marker and may only
+// contain paragraphs, since decisionItem only accepts inline content and
+// decision lists cannot be nested. Items that do not qualify fall back to a
+// listItem with a text marker prefix.
+func (r *Renderer) isDecisionItem(item ast.Node) bool {
+	if decisionMarker(item) == nil {
+		return false
+	}
+	for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c.Kind() {
+		case ast.KindParagraph, ast.KindTextBlock:
+			if (r.config.ExternalMedia || r.config.ImageHandler != nil) && containsImage(c) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Renderer) renderDecisionMarker(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	// Decision items carry their state in attrs, so the marker emits nothing
+	item := node.Parent().Parent()
+	if r.listItemRun(st, item) == "decisionList" {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*DecisionMarker)
+	if r.isDecisionItem(item) {
+		r.report(st, item, SeverityWarning, DiagnosticDecisionItemAsText, "%s cannot contain decisionList; rendered with a text marker", st.listParents[item.Parent().(*ast.List)])
+	} else {
+		r.report(st, item, SeverityWarning, DiagnosticDecisionItemAsText, "decision item holds content a decisionItem cannot contain; rendered with a text marker")
+	}
+	text := "[d] "
+	if n.IsDecided {
+		text = "[D] "
+	}
+	st.appendToCurrentOrDocument(*NewText(text))
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,244 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/util"
+)
+
+// DefinitionListStyle selects how definition lists (a term line followed by
+// ": description" lines, with [NewWithGFM]) are rendered. ADF has no
+// definition list node.
+type DefinitionListStyle int
+
+const (
+	// DefinitionListBlockquote renders each term as a bold paragraph
+	// followed by each of its descriptions in a blockquote. This is the
+	// default.
+	DefinitionListBlockquote DefinitionListStyle = iota
+
+	// DefinitionListIndented renders each term as a bold paragraph followed
+	// by its descriptions, with their paragraphs indented. ADF only allows
+	// indentation at the top level of the document and in layout columns.
+	DefinitionListIndented
+
+	// DefinitionListTable renders the list as a two-column table with a row
+	// per term, the term in a header cell and its descriptions beside it.
+	DefinitionListTable
+
+	// DefinitionListBulletList renders the list as a bullet list with an item
+	// per term, holding the term as a bold paragraph and its descriptions.
+	DefinitionListBulletList
+)
+
+// addDefinitionListParser adds the parser parts of goldmark's definition list
+// extension, without its HTML renderer.
+func addDefinitionListParser(md goldmark.Markdown) {
+	md.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(extension.NewDefinitionListParser(), 101),
+			util.Prioritized(extension.NewDefinitionDescriptionParser(), 102),
+		),
+	)
+}
+
+// definitionGroup is one or more terms sharing one or more descriptions.
+type definitionGroup struct {
+	// terms holds a paragraph per term.
+	terms []Node
+
+	// descriptions holds the blocks of each description.
+	descriptions [][]Node
+}
+
+// definitionDescriptionType returns the ADF type of the node the blocks of a
+// description are placed in, for a definition list in parentType.
+func (r *Renderer) definitionDescriptionType(parentType string) string {
+	switch r.config.DefinitionLists {
+	case DefinitionListBlockquote:
+		return "blockquote"
+	case DefinitionListTable:
+		return "tableCell"
+	case DefinitionListBulletList:
+		return "listItem"
+	}
+	return parentType
+}
+
+func (r *Renderer) renderDefinitionList(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	if entering {
+		// Terms and descriptions are collected, then laid out on exit
+		st.pushNode(&Node{Type: "definitionList", Content: []Node{}})
+		return ast.WalkContinue, nil
+	}
+
+	list := st.currentNode()
+	st.discardCurrentNode()
+	var groups []definitionGroup
+	i := 0
+	for c := node.FirstChild(); c != nil; c, i = c.NextSibling(), i+1 {
+		if c.Kind() == extast.KindDefinitionTerm {
+			if len(groups) == 0 || len(groups[len(groups)-1].descriptions) > 0 {
+				groups = append(groups, definitionGroup{})
+			}
+			g := &groups[len(groups)-1]
+			g.terms = append(g.terms, list.Content[i])
+		} else {
+			if len(groups) == 0 {
+				groups = append(groups, definitionGroup{})
+			}
+			g := &groups[len(groups)-1]
+			g.descriptions = append(g.descriptions, list.Content[i].Content)
+		}
+	}
+
+	parentType := st.parentType()
+	nodes, err := r.layoutDefinitionList(groups, parentType)
+	if err == nil {
+		err = checkNesting(parentType, nodes)
+	}
+	if err != nil {
+		r.report(st, node, SeverityWarning, DiagnosticDefinitionListFlattened, "%v; definition list rendered as paragraphs", err)
+		nodes = flatDefinitionList(groups)
+	}
+	for _, n := range nodes {
+		st.appendToCurrentOrDocument(n)
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderDefinitionTerm(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	if entering {
+		st.pushNode(NewParagraph())
+	} else {
+		st.popNode()
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderDefinitionDescription(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	if entering {
+		// The list's own node is below the top of the stack
+		parentType := "doc"
+		if len(st.nodeStack) > 1 {
+			parentType = st.nodeStack[len(st.nodeStack)-2].Type
+		}
+		st.pushNode(&Node{Type: r.definitionDescriptionType(parentType), Content: []Node{}})
+	} else {
+		st.popNode()
+	}
+	return ast.WalkContinue, nil
+}
+
+// layoutDefinitionList lays out the groups of a definition list in
+// parentType in the configured style.
+func (r *Renderer) layoutDefinitionList(groups []definitionGroup, parentType string) ([]Node, error) {
+	switch r.config.DefinitionLists {
+	case DefinitionListIndented:
+		if parentType != "doc" && parentType != "layoutColumn" {
+			return nil, fmt.Errorf("%s cannot contain indented paragraphs", parentType)
+		}
+		var nodes []Node
+		for _, g := range groups {
+			nodes = append(nodes, boldTerms(g.terms)...)
+			for _, desc := range g.descriptions {
+				for _, block := range desc {
+					if block.Type == "paragraph" {
+						block.Marks = []Mark{NewIndentationMark(1)}
+					}
+					nodes = append(nodes, block)
+				}
+			}
+		}
+		return nodes, nil
+	case DefinitionListTable:
+		table := r.newTable()
+		for _, g := range groups {
+			term := NewTableHeader()
+			term.Content = append(term.Content, g.terms...)
+			desc := NewTableCell()
+			desc.Content = slices.Concat(g.descriptions...)
+			row := NewTableRow()
+			for _, cell := range []*Node{term, desc} {
+				if len(cell.Content) == 0 {
+					cell.AppendChild(*NewParagraph())
+				}
+				row.AppendChild(*cell)
+			}
+			table.AppendChild(*row)
+		}
+		return []Node{*table}, nil
+	case DefinitionListBulletList:
+		list := NewBulletList()
+		for _, g := range groups {
+			item := NewListItem()
+			item.Content = append(boldTerms(g.terms), slices.Concat(g.descriptions...)...)
+			if len(item.Content) == 0 {
+				item.AppendChild(*NewParagraph())
+			}
+			list.AppendChild(*item)
+		}
+		return []Node{*list}, nil
+	}
+
+	var nodes []Node
+	for _, g := range groups {
+		nodes = append(nodes, boldTerms(g.terms)...)
+		for _, desc := range g.descriptions {
+			quote := NewBlockquote()
+			quote.Content = append(quote.Content, desc...)
+			if len(quote.Content) == 0 {
+				quote.AppendChild(*NewParagraph())
+			}
+			nodes = append(nodes, *quote)
+		}
+	}
+	return nodes, nil
+}
+
+// flatDefinitionList lays out the groups of a definition list as bold term
+// paragraphs, each followed by the blocks of its descriptions.
+func flatDefinitionList(groups []definitionGroup) []Node {
+	var nodes []Node
+	for _, g := range groups {
+		nodes = append(nodes, boldTerms(g.terms)...)
+		nodes = append(nodes, slices.Concat(g.descriptions...)...)
+	}
+	return nodes
+}
+
+// boldTerms returns copies of term paragraphs with their text made strong.
+func boldTerms(terms []Node) []Node {
+	bold := make([]Node, len(terms))
+	for i, term := range terms {
+		bold[i] = withStrongText(term)
+	}
+	return bold
+}
+
+// checkNesting checks that parentType may hold nodes, and that each of them
+// may hold its content, where the ADF schema restricts it (see
+// contentRules).
+func checkNesting(parentType string, nodes []Node) error {
+	rule, ok := contentRules[parentType]
+	for _, n := range nodes {
+		if ok && !slices.Contains(rule.types, n.Type) {
+			return fmt.Errorf("%s cannot contain %s", parentType, n.Type)
+		}
+		if err := checkNesting(n.Type, n.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindDetails is a NodeKind of the Details node.
+var KindDetails = ast.NewNodeKind("Details")
+
+// Details is a block node representing a collapsible <details> section. Its
+// children are the Markdown blocks between the <details> and </details> tags.
+type Details struct {
+	ast.BaseBlock
+
+	// Summary is the plain text of the <summary> element, if any.
+	Summary string
+
+	// nodeType is the ADF type the section renders as, set when rendering
+	// starts.
+	nodeType string
+}
+
+// NewDetails creates a new Details node with the given summary.
+func NewDetails(summary string) *Details {
+	return &Details{Summary: summary}
+}
+
+// Kind implements ast.Node.Kind.
+func (n *Details) Kind() ast.NodeKind {
+	return KindDetails
+}
+
+// Dump implements ast.Node.Dump.
+func (n *Details) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Summary": n.Summary}, nil)
+}
+
+var (
+	detailsOpenRegexp  = regexp.MustCompile(`(?is)^\s*<details(?:\s[^>]*)?>(.*)$`)
+	detailsCloseRegexp = regexp.MustCompile(`(?is)^(.*?)</details>\s*$`)
+	summaryRegexp      = regexp.MustCompile(`(?is)^\s*<summary(?:\s[^>]*)?>(.*?)</summary>(.*)$`)
+	htmlTagRegexp      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// detailsTransformer wraps the blocks between <details> and </details> HTML
+// blocks into Details nodes.
+type detailsTransformer struct{}
+
+// NewDetailsTransformer returns a parser.ASTTransformer that converts
+// <details>/<summary> HTML blocks and the Markdown between them into
+// [Details] nodes. Unterminated <details> blocks are left untouched.
+func NewDetailsTransformer() parser.ASTTransformer {
+	return &detailsTransformer{}
+}
+
+// Transform implements parser.ASTTransformer.
+func (t *detailsTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	t.wrap(doc, reader.Source())
+}
+
+// wrap converts details sections among the children of parent, recursing into
+// nested blocks.
+func (t *detailsTransformer) wrap(parent ast.Node, source []byte) {
+	for c := parent.FirstChild(); c != nil; c = c.NextSibling() {
+		if d := t.wrapDetails(parent, c, source); d != nil {
+			c = d
+		}
+		if c.Type() == ast.TypeBlock && c.HasChildren() {
+			t.wrap(c, source)
+		}
+	}
+}
+
+// wrapDetails replaces open, its matching close and everything in between with
+// a Details node. It returns nil if open does not start a details section.
+func (t *detailsTransformer) wrapDetails(parent, open ast.Node, source []byte) *Details {
+	rest, ok := detailsOpen(open, source)
+	if !ok {
+		return nil
+	}
+
+	// A summary may be given in its own HTML block right after <details>
+	summary, rest, hasSummary := parseSummary(rest)
+	first := open.NextSibling()
+	if !hasSummary && strings.TrimSpace(rest) == "" {
+		if block, ok := first.(*ast.HTMLBlock); ok {
+			if s, after, ok := parseSummary(htmlBlockText(block, source)); ok && strings.TrimSpace(after) == "" {
+				summary = s
+				first = first.NextSibling()
+				parent.RemoveChild(parent, block)
+			}
+		}
+	}
+
+	details := NewDetails(summary)
+	// Keep the position of the opening tag for diagnostics
+	details.SetLines(open.Lines())
+
+	// Everything is in a single HTML block
+	if m := detailsCloseRegexp.FindStringSubmatch(rest); m != nil {
+		appendHTMLText(details, m[1])
+		parent.ReplaceChild(parent, open, details)
+		return details
+	}
+
+	// Find the matching close, allowing nested sections
+	depth := 1
+	var end ast.Node
+	for c := first; c != nil; c = c.NextSibling() {
+		if r, ok := detailsOpen(c, source); ok && detailsCloseRegexp.FindStringSubmatch(r) == nil {
+			depth++
+		} else if isDetailsClose(c, source) {
+			depth--
+			if depth == 0 {
+				end = c
+				break
+			}
+		}
+	}
+	if end == nil {
+		return nil
+	}
+
+	appendHTMLText(details, rest)
+	for c := first; c != end; {
+		next := c.NextSibling()
+		details.AppendChild(details, c)
+		c = next
+	}
+	parent.RemoveChild(parent, end)
+	parent.ReplaceChild(parent, open, details)
+	return details
+}
+
+// detailsOpen reports whether n is an HTML block starting with <details>,
+// returning the text following the opening tag.
+func detailsOpen(n ast.Node, source []byte) (string, bool) {
+	block, ok := n.(*ast.HTMLBlock)
+	if !ok {
+		return "", false
+	}
+	m := detailsOpenRegexp.FindStringSubmatch(htmlBlockText(block, source))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// isDetailsClose reports whether n is an HTML block containing only </details>.
+func isDetailsClose(n ast.Node, source []byte) bool {
+	block, ok := n.(*ast.HTMLBlock)
+	if !ok {
+		return false
+	}
+	m := detailsCloseRegexp.FindStringSubmatch(htmlBlockText(block, source))
+	return m != nil && strings.TrimSpace(m[1]) == ""
+}
+
+// parseSummary extracts a leading <summary> element from s, returning its
+// plain text and the remainder of s.
+func parseSummary(s string) (summary, rest string, ok bool) {
+	m := summaryRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return "", s, false
+	}
+	return htmlToText(m[1]), m[2], true
+}
+
+// htmlToText strips tags and entities from an HTML fragment and collapses
+// whitespace.
+func htmlToText(s string) string {
+	s = html.UnescapeString(htmlTagRegexp.ReplaceAllString(s, ""))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// appendHTMLText appends the plain text of an HTML fragment to n as a paragraph.
+func appendHTMLText(n ast.Node, s string) {
+	if s = htmlToText(s); s != "" {
+		para := ast.NewParagraph()
+		para.AppendChild(para, ast.NewString([]byte(s)))
+		n.AppendChild(n, para)
+	}
+}
+
+// htmlBlockText returns the raw source of an HTML block.
+func htmlBlockText(n *ast.HTMLBlock, source []byte) string {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		b.Write(line.Value(source))
+	}
+	if n.HasClosure() {
+		b.Write(n.ClosureLine.Value(source))
+	}
+	return b.String()
+}
+
+// addDetailsParser adds the details transformer.
+func addDetailsParser(md goldmark.Markdown) {
+	md.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(NewDetailsTransformer(), 100),
+		),
+	)
+}
+
+// detailsNodeType returns the ADF node type a Details node renders as in a
+// node of parentType: "expand" where the schema allows one (such as at the
+// top level or in a layout column), "nestedExpand" directly inside an expand,
+// or "" where the schema allows neither.
+func detailsNodeType(parentType string) string {
+	allowed := contentRules[parentType].types
+	switch {
+	case slices.Contains(allowed, "expand"):
+		return "expand"
+	case slices.Contains(allowed, "nestedExpand"):
+		return "nestedExpand"
+	}
+	return ""
+}
+
+func (r *Renderer) renderDetails(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	n := node.(*Details)
+	if entering {
+		n.nodeType = detailsNodeType(st.parentType())
+	}
+	nodeType := n.nodeType
+	if nodeType == "" {
+		// Expands cannot be nested here, so render the summary as a bold
+		// paragraph followed by the content
+		if entering {
+			r.report(st, node, SeverityInfo, DiagnosticDetailsFlattened, "<details> cannot be an expand here; rendered as a bold summary and its content")
+			if n.Summary != "" {
+				para := NewParagraph()
+				para.AppendChild(*NewTextWithMarks(n.Summary, []Mark{NewStrongMark()}))
+				st.appendToCurrentOrDocument(*para)
+			}
+		}
+		return ast.WalkContinue, nil
+	}
+
+	if entering {
+		if nodeType == "expand" {
+			st.pushNode(NewExpand(n.Summary))
+		} else {
+			st.pushNode(NewNestedExpand(n.Summary))
+		}
+	} else {
+		// Expands require at least one child
+		if current := st.currentNode(); current != nil && len(current.Content) == 0 {
+			current.AppendChild(*NewParagraph())
+		}
+		st.popNode()
+	}
+	return ast.WalkContinue, nil
+}
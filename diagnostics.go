@@ -0,0 +1,208 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Severity is the severity of a [Diagnostic].
+type Severity int
+
+const (
+	// SeverityInfo marks content that was rendered differently than it
+	// appears in Markdown, without losing information.
+	SeverityInfo Severity = iota
+
+	// SeverityWarning marks content that was dropped or degraded.
+	SeverityWarning
+
+	// SeverityError marks content that stopped the conversion.
+	SeverityError
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic codes reported by the renderer.
+const (
+	// DiagnosticHTMLDropped reports raw HTML discarded under [HTMLDrop].
+	DiagnosticHTMLDropped = "html-dropped"
+
+	// DiagnosticHTMLTagRemoved reports HTML tags that [HTMLTranslate] has no
+	// ADF equivalent for. Their text is kept.
+	DiagnosticHTMLTagRemoved = "html-tag-removed"
+
+	// DiagnosticHTMLBlockFlattened reports a block translated from HTML
+	// under [HTMLTranslate] that is not allowed where it appears, which was
+	// rendered as paragraphs or dropped.
+	DiagnosticHTMLBlockFlattened = "html-block-flattened"
+
+	// DiagnosticImageAsLink reports an image rendered as a link because
+	// external media is disabled.
+	DiagnosticImageAsLink = "image-as-link"
+
+	// DiagnosticMarkConflict reports marks ADF does not allow together, and
+	// how they were resolved (see [WithMarkConflicts]).
+	DiagnosticMarkConflict = "mark-conflict"
+
+	// DiagnosticDetailsFlattened reports a <details> section that could not
+	// be an expand where it appears and was rendered as a bold summary
+	// followed by its content.
+	DiagnosticDetailsFlattened = "details-flattened"
+
+	// DiagnosticTaskItemAsText reports a task list item whose content a
+	// taskItem cannot hold, rendered as a list item with a text checkbox.
+	DiagnosticTaskItemAsText = "task-item-as-text"
+
+	// DiagnosticDecisionItemAsText reports a decision list item whose content
+	// a decisionItem cannot hold, rendered as a list item with a text marker.
+	DiagnosticDecisionItemAsText = "decision-item-as-text"
+
+	// DiagnosticMentionUnresolved reports an @username mention that the
+	// [MentionResolver] did not know, rendered as text.
+	DiagnosticMentionUnresolved = "mention-unresolved"
+
+	// DiagnosticStatusColorInvalid reports a status lozenge whose colour ADF
+	// does not allow, rendered as neutral.
+	DiagnosticStatusColorInvalid = "status-color-invalid"
+
+	// DiagnosticDateInvalid reports a {date:...} macro whose date could not
+	// be parsed, rendered as text.
+	DiagnosticDateInvalid = "date-invalid"
+
+	// DiagnosticContainerInvalid reports a :::name container whose
+	// attributes or content the ADF node it renders as does not allow. The
+	// conversion fails with a [*ContainerError].
+	DiagnosticContainerInvalid = "container-invalid"
+
+	// DiagnosticMacroUnknown reports a confluence-macro fence naming a macro
+	// that is not registered, rendered as a code block or, under
+	// [UnknownMacroError], failing the conversion.
+	DiagnosticMacroUnknown = "macro-unknown"
+
+	// DiagnosticMacroInvalid reports a confluence-macro fence whose
+	// attributes, parameters or body are invalid. The conversion fails with a
+	// [*MacroError].
+	DiagnosticMacroInvalid = "macro-invalid"
+
+	// DiagnosticFootnoteBlockDropped reports a block other than a paragraph
+	// in a footnote rendered inline (see [WithInlineFootnotes]), which was
+	// dropped.
+	DiagnosticFootnoteBlockDropped = "footnote-block-dropped"
+
+	// DiagnosticAlertFlattened reports an alert placed where a panel, or
+	// what it holds, is not allowed, which was rendered as a blockquote or
+	// as its content alone.
+	DiagnosticAlertFlattened = "alert-flattened"
+
+	// DiagnosticDefinitionListFlattened reports a definition list placed
+	// where its configured style (see [WithDefinitionLists]) is not allowed,
+	// which was rendered as paragraphs.
+	DiagnosticDefinitionListFlattened = "definition-list-flattened"
+)
+
+// Diagnostic describes Markdown content that did not convert cleanly to ADF.
+type Diagnostic struct {
+	Severity Severity
+
+	// Code identifies the kind of diagnostic, e.g. [DiagnosticHTMLDropped].
+	Code string
+
+	// Message is a human readable description.
+	Message string
+
+	// Line and Column are the 1-based position of the content in the
+	// Markdown source. Column counts characters, not bytes. Both are 0 if the
+	// position is unknown.
+	Line   int
+	Column int
+}
+
+// String formats the diagnostic as "line:column: severity: message (code)".
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", d.Line, d.Column, d.Severity, d.Message, d.Code)
+}
+
+// report passes a diagnostic about node to the configured handler.
+func (r *Renderer) report(st *renderState, node ast.Node, severity Severity, code, format string, args ...any) {
+	if r.config.DiagnosticHandler == nil {
+		return
+	}
+	d := Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if offset, ok := sourceOffset(node); ok {
+		d.Line, d.Column = sourcePosition(st.source, offset)
+	}
+	r.config.DiagnosticHandler(d)
+}
+
+// sourceOffset returns the offset in the source of the first text of node,
+// falling back to the first line of its nearest ancestor block with lines.
+func sourceOffset(node ast.Node) (int, bool) {
+	if offset, ok := firstSegmentStart(node); ok {
+		return offset, true
+	}
+	for n := node.Parent(); n != nil; n = n.Parent() {
+		if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+			return n.Lines().At(0).Start, true
+		}
+	}
+	return 0, false
+}
+
+// firstSegmentStart returns the start of the first source segment of n or its
+// descendants.
+func firstSegmentStart(n ast.Node) (int, bool) {
+	switch v := n.(type) {
+	case *ast.Text:
+		return v.Segment.Start, true
+	case *ast.RawHTML:
+		if v.Segments.Len() > 0 {
+			return v.Segments.At(0).Start, true
+		}
+	case *Container:
+		if v.fence.Len() > 0 {
+			return v.fence.Start, true
+		}
+	case *ConfluenceMacro:
+		if v.fence.Len() > 0 {
+			return v.fence.Start, true
+		}
+	}
+	if n.Type() == ast.TypeBlock && n.Lines().Len() > 0 {
+		return n.Lines().At(0).Start, true
+	}
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if offset, ok := firstSegmentStart(c); ok {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// sourcePosition converts a byte offset in source to a 1-based line and
+// character column.
+func sourcePosition(source []byte, offset int) (line, column int) {
+	offset = min(offset, len(source))
+	before := source[:offset]
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return bytes.Count(before, []byte("\n")) + 1, utf8.RuneCount(before[lineStart:]) + 1
+}
@@ -8,14 +8,15 @@
 //
 // # Build Requirements
 //
-// This package requires Go 1.25+ with the experimental json/v2 package:
+// This package requires Go 1.27+ with the experimental json/v2 package:
 //
 //	GOEXPERIMENT=jsonv2 go build ./...
 //	GOEXPERIMENT=jsonv2 go test ./...
 //
 // # Basic Usage
 //
-// Use [New] to create a reusable goldmark instance:
+// Use [New] to create a reusable goldmark instance. An instance may be shared
+// by concurrent Convert calls:
 //
 //	md := adf.New()
 //	var buf bytes.Buffer
@@ -27,7 +28,7 @@
 // # With GFM Extensions
 //
 // Use [NewWithGFM] to enable GitHub Flavored Markdown extensions including
-// tables, strikethrough, autolinks, and task lists:
+// tables, strikethrough, autolinks, task lists, and alerts:
 //
 //	md := adf.NewWithGFM()
 //
@@ -47,6 +48,22 @@
 //	    adf.WithImageHandler(customHandler),
 //	)
 //
+// # Parsing ADF
+//
+// [ParseDocument] decodes ADF JSON into a [Document]. Unknown node types,
+// attributes and members are preserved, and malformed input is reported as a
+// [*ParseError] with the line and column of the problem:
+//
+//	doc, err := adf.ParseDocument(jsonBytes)
+//
+// # Converting to Markdown
+//
+// [ToMarkdown] converts ADF JSON back to CommonMark with GFM extensions. Nodes
+// without a Markdown equivalent fall back to the closest construct; see
+// [Document.Markdown] for the details:
+//
+//	markdown, err := adf.ToMarkdown(jsonBytes)
+//
 // # Schema Validation
 //
 // The [adfschema] subpackage provides JSON Schema validation for ADF documents:
@@ -66,9 +83,14 @@
 // converted to links with the image URL.
 //
 // GFM extensions (with [NewWithGFM]): tables, strikethrough, autolinks, and
-// task lists (rendered as "[x]" or "[ ]" text prefixes).
-//
-// Raw HTML is skipped as ADF does not support arbitrary HTML content.
+// task lists (rendered as native taskList and taskItem nodes). GitHub-style
+// alerts such as "> [!NOTE]" are rendered as panel nodes; use
+// [WithAlertPanels] to change which panel type each alert kind maps to.
+//
+// With [NewWithGFM], collapsible <details> sections with an optional
+// <summary> are rendered as expand nodes (or nestedExpand nodes inside another
+// expand). Other raw HTML is skipped as ADF does not support arbitrary HTML
+// content.
 //
 // [goldmark]: https://github.com/yuin/goldmark
 // [adfschema]: https://pkg.go.dev/github.com/ajbeck/goldmark-adf/adfschema
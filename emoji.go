@@ -0,0 +1,158 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindEmojiShortcode is a NodeKind of the EmojiShortcode node.
+var KindEmojiShortcode = ast.NewNodeKind("EmojiShortcode")
+
+// EmojiShortcode is an inline node representing a known :shortname: emoji
+// shortcode. Its single child is the shortcode as written.
+type EmojiShortcode struct {
+	ast.BaseInline
+
+	// ShortName is the shortcode without colons, e.g. "rocket".
+	ShortName string
+
+	// Emoji is the emoji the shortcode stands for.
+	Emoji Emoji
+}
+
+// NewEmojiShortcode creates a new EmojiShortcode node.
+func NewEmojiShortcode(shortName string, emoji Emoji) *EmojiShortcode {
+	return &EmojiShortcode{ShortName: shortName, Emoji: emoji}
+}
+
+// Kind implements ast.Node.Kind.
+func (n *EmojiShortcode) Kind() ast.NodeKind {
+	return KindEmojiShortcode
+}
+
+// Dump implements ast.Node.Dump.
+func (n *EmojiShortcode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"ShortName": n.ShortName, "ID": n.Emoji.ID, "Text": n.Emoji.Text}, nil)
+}
+
+// Emoji describes the ADF emoji a shortcode renders as.
+type Emoji struct {
+	// ID is the Atlassian emoji ID: the hexadecimal code points of a
+	// standard emoji (e.g. "1f680"), or the ID of a custom emoji.
+	ID string
+
+	// Text is the Unicode fallback shown where the emoji cannot be rendered.
+	// It may be empty for custom emoji.
+	Text string
+}
+
+//go:embed emoji.txt
+var standardEmojiData string
+
+// standardEmoji parses the embedded table of standard emoji on first use.
+var standardEmoji = sync.OnceValue(func() map[string]Emoji {
+	table := make(map[string]Emoji, 4096)
+	scanner := bufio.NewScanner(strings.NewReader(standardEmojiData))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, emoji, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		table[name] = Emoji{ID: emojiID(emoji), Text: emoji}
+	}
+	return table
+})
+
+// emojiID returns the Atlassian ID of a standard emoji: its code points in
+// lower-case hexadecimal joined by '-', without variation selectors.
+func emojiID(emoji string) string {
+	var parts []string
+	for _, r := range emoji {
+		if r == '\ufe0f' {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%x", r))
+	}
+	return strings.Join(parts, "-")
+}
+
+var emojiShortcodeRegexp = regexp.MustCompile(`^:([a-z0-9_+-]+):`)
+
+// emojiParser parses :shortname: emoji shortcodes.
+type emojiParser struct {
+	custom map[string]Emoji
+}
+
+// NewEmojiParser returns a parser.InlineParser that parses :shortname: emoji
+// shortcodes into [EmojiShortcode] nodes. Short names are looked up in custom
+// first, then in the embedded table of standard emoji; unknown shortcodes are
+// left as text. A ':' preceded by a letter or digit never starts a shortcode.
+func NewEmojiParser(custom map[string]Emoji) parser.InlineParser {
+	return &emojiParser{custom: custom}
+}
+
+// Trigger implements parser.InlineParser.
+func (p *emojiParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+// Parse implements parser.InlineParser.
+func (p *emojiParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if before := block.PrecendingCharacter(); unicode.IsLetter(before) || unicode.IsDigit(before) {
+		return nil
+	}
+	line, segment := block.PeekLine()
+	m := emojiShortcodeRegexp.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	name := string(m[1])
+	emoji, ok := p.custom[name]
+	if !ok {
+		if emoji, ok = standardEmoji()[name]; !ok {
+			return nil
+		}
+	}
+
+	node := NewEmojiShortcode(name, emoji)
+	node.AppendChild(node, ast.NewTextSegment(segment.WithStop(segment.Start+len(m[0]))))
+	block.Advance(len(m[0]))
+	return node
+}
+
+// addEmojiParser adds the emoji shortcode parser, recognising the custom
+// emoji in addition to the standard ones.
+func addEmojiParser(md goldmark.Markdown, custom map[string]Emoji) {
+	md.Parser().AddOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(NewEmojiParser(custom), 150),
+		),
+	)
+}
+
+func (r *Renderer) renderEmojiShortcode(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	st := r.state(node)
+	n := node.(*EmojiShortcode)
+	st.appendToCurrentOrDocument(*NewEmoji(":"+n.ShortName+":", n.Emoji.ID, n.Emoji.Text))
+	return ast.WalkSkipChildren, nil
+}
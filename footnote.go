@@ -0,0 +1,239 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// addFootnoteParser adds the parser parts of goldmark's footnote extension,
+// without its HTML renderer.
+func addFootnoteParser(md goldmark.Markdown) {
+	md.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(extension.NewFootnoteBlockParser(), 999),
+		),
+		parser.WithInlineParsers(
+			util.Prioritized(extension.NewFootnoteParser(), 101),
+		),
+		parser.WithASTTransformers(
+			util.Prioritized(extension.NewFootnoteASTTransformer(), 999),
+		),
+	)
+}
+
+// footnoteAnchor returns the name of the anchor of footnote index, or of its
+// ref-th reference (counting from 0) if ref is not negative.
+func footnoteAnchor(index, ref int) string {
+	switch {
+	case ref < 0:
+		return fmt.Sprintf("fn-%d", index)
+	case ref == 0:
+		return fmt.Sprintf("fnref-%d", index)
+	}
+	return fmt.Sprintf("fnref-%d-%d", index, ref+1)
+}
+
+// newAnchor creates a Confluence anchor macro, the target of links to
+// "#" + name.
+func newAnchor(name string) Node {
+	return Node{
+		Type: "inlineExtension",
+		Attrs: map[string]any{
+			"extensionType": DefaultMacroExtensionType,
+			"extensionKey":  "anchor",
+			"parameters": map[string]any{
+				"macroParams":   map[string]any{"": map[string]any{"value": name}},
+				"macroMetadata": map[string]any{"schemaVersion": map[string]any{"value": "1"}},
+			},
+		},
+	}
+}
+
+func (r *Renderer) renderFootnoteLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	st := r.state(node)
+	n := node.(*extast.FootnoteLink)
+	if r.config.InlineFootnotes {
+		return ast.WalkContinue, r.renderInlineFootnote(w, source, st, n)
+	}
+
+	st.appendToCurrentOrDocument(newAnchor(footnoteAnchor(n.Index, n.RefIndex)))
+	st.pushMark(NewLinkMark("#"+footnoteAnchor(n.Index, -1), ""))
+	st.pushMark(NewSubSupMark("sup"))
+	defer st.popMark()
+	defer st.popMark()
+	textNode, err := r.newMarkedText(st, node, fmt.Sprint(n.Index))
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	st.appendToCurrentOrDocument(*textNode)
+	return ast.WalkContinue, nil
+}
+
+// renderInlineFootnote renders the text of the footnote a reference points to
+// in parentheses, in place of the reference. Only the footnote's paragraphs
+// can be rendered inline; other blocks are dropped.
+func (r *Renderer) renderInlineFootnote(w util.BufWriter, source []byte, st *renderState, link *extast.FootnoteLink) error {
+	footnote := findFootnote(link)
+	if footnote == nil || slices.Contains(st.footnotes, link.Index) {
+		return nil
+	}
+	st.footnotes = append(st.footnotes, link.Index)
+	defer func() { st.footnotes = st.footnotes[:len(st.footnotes)-1] }()
+
+	// Separate the parentheses from a preceding word
+	open := " ("
+	switch prev := link.PreviousSibling().(type) {
+	case nil:
+		open = "("
+	case *ast.Text:
+		value := prev.Segment.Value(source)
+		if prev.SoftLineBreak() || prev.HardLineBreak() || len(value) > 0 && util.IsSpace(value[len(value)-1]) {
+			open = "("
+		}
+	}
+	textNode, err := r.newMarkedText(st, link, open)
+	if err != nil {
+		return err
+	}
+	st.appendToCurrentOrDocument(*textNode)
+
+	first := true
+	for block := footnote.FirstChild(); block != nil; block = block.NextSibling() {
+		if block.Kind() == extast.KindFootnoteBacklink {
+			continue
+		}
+		if block.Kind() != ast.KindParagraph && block.Kind() != ast.KindTextBlock {
+			r.report(st, block, SeverityWarning, DiagnosticFootnoteBlockDropped, "footnote %d holds a %s, which cannot be inlined; dropped", link.Index, block.Kind())
+			continue
+		}
+		if !first {
+			textNode, err := r.newMarkedText(st, link, " ")
+			if err != nil {
+				return err
+			}
+			st.appendToCurrentOrDocument(*textNode)
+		}
+		first = false
+		for c := block.FirstChild(); c != nil; c = c.NextSibling() {
+			err := ast.Walk(c, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+				if n.Kind() == extast.KindFootnoteBacklink {
+					return ast.WalkSkipChildren, nil
+				}
+				if f := r.funcs[n.Kind()]; f != nil {
+					return f(w, source, n, entering)
+				}
+				return ast.WalkContinue, nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	textNode, err = r.newMarkedText(st, link, ")")
+	if err != nil {
+		return err
+	}
+	st.appendToCurrentOrDocument(*textNode)
+	return nil
+}
+
+// findFootnote returns the footnote a reference points to, or nil if there is
+// none.
+func findFootnote(link *extast.FootnoteLink) *extast.Footnote {
+	doc := link.OwnerDocument()
+	for c := doc.LastChild(); c != nil; c = c.PreviousSibling() {
+		if _, ok := c.(*extast.FootnoteList); !ok {
+			continue
+		}
+		for fn := c.FirstChild(); fn != nil; fn = fn.NextSibling() {
+			if footnote := fn.(*extast.Footnote); footnote.Index == link.Index {
+				return footnote
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Renderer) renderFootnoteList(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	if r.config.InlineFootnotes {
+		return ast.WalkSkipChildren, nil
+	}
+	if entering {
+		st.appendToCurrentOrDocument(*NewRule())
+		st.pushNode(NewOrderedList(1))
+	} else {
+		st.popNode()
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderFootnote(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	if entering {
+		st.pushNode(NewListItem())
+		return ast.WalkContinue, nil
+	}
+
+	// The anchor starts the footnote's first paragraph
+	item := st.currentNode()
+	anchor := newAnchor(footnoteAnchor(node.(*extast.Footnote).Index, -1))
+	if len(item.Content) == 0 || item.Content[0].Type != "paragraph" {
+		item.Content = slices.Insert(item.Content, 0, *NewParagraph())
+	}
+	item.Content[0].Content = slices.Insert(item.Content[0].Content, 0, anchor)
+	st.popNode()
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderFootnoteBacklink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	st := r.state(node)
+	n := node.(*extast.FootnoteBacklink)
+
+	// Backlinks follow the last paragraph of the footnote, or get one of
+	// their own if the footnote ends with another block
+	target := st.currentNode()
+	if target.Type == "listItem" {
+		if len(target.Content) == 0 || target.Content[len(target.Content)-1].Type != "paragraph" {
+			target.AppendChild(*NewParagraph())
+		}
+		target = &target.Content[len(target.Content)-1]
+	}
+	st.pushMark(NewLinkMark("#"+footnoteAnchor(n.Index, n.RefIndex), ""))
+	defer st.popMark()
+	textNode, err := r.newMarkedText(st, node, "↩")
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	if len(target.Content) > 0 {
+		target.AppendChild(*NewText(" "))
+	}
+	target.AppendChild(*textNode)
+	return ast.WalkContinue, nil
+}
+
+// nodeRendererFuncs collects the functions a NodeRenderer registers, so that
+// nodes can be rendered outside of goldmark's walk of the document.
+type nodeRendererFuncs map[ast.NodeKind]renderer.NodeRendererFunc
+
+// Register implements renderer.NodeRendererFuncRegisterer.
+func (f nodeRendererFuncs) Register(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+	f[kind] = fn
+}
@@ -1,6 +1,6 @@
 module github.com/ajbeck/goldmark-adf
 
-go 1.25.6
+go 1.27
 
 require github.com/google/jsonschema-go v0.4.2
 
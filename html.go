@@ -0,0 +1,549 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+// HTMLPolicy selects how raw HTML in Markdown is rendered. ADF has no HTML
+// node, so HTML blocks (e.g. a <table>) and inline HTML (e.g. <sub>) can only
+// be dropped, shown as source, or translated to equivalent ADF nodes.
+type HTMLPolicy int
+
+const (
+	// HTMLDrop discards raw HTML. This is the default.
+	HTMLDrop HTMLPolicy = iota
+
+	// HTMLText renders raw HTML as literal text.
+	HTMLText
+
+	// HTMLCodeBlock renders HTML blocks as codeBlock nodes with the "html"
+	// language, and inline HTML as code-marked text.
+	HTMLCodeBlock
+
+	// HTMLTranslate translates a safe subset of HTML to ADF: <br> to
+	// hardBreak, <hr> to rule, <sub>/<sup> to subsup, <u>/<ins> to underline,
+	// <code>/<kbd>/<samp> to code, <mark> to backgroundColor, <b>/<strong>,
+	// <i>/<em> and <s>/<del> to strong, em and strike, <p> and <h1>-<h6> to
+	// paragraphs and headings, and <table> to a table. Other tags are dropped
+	// and their text kept, except for <script>, <style>, <template>,
+	// <noscript> and <iframe>, which are dropped with their content.
+	HTMLTranslate
+)
+
+// htmlHighlightColor is the backgroundColor used for <mark>.
+const htmlHighlightColor = "#fff0b3"
+
+var (
+	htmlTokenRegexp  = regexp.MustCompile(`(?s)<!--.*?-->|<(/?)([A-Za-z][A-Za-z0-9-]*)(?:\s[^>]*)?>`)
+	htmlSpaceRegexp  = regexp.MustCompile(`\s+`)
+	htmlHeadingLevel = map[string]int{"h1": 1, "h2": 2, "h3": 3, "h4": 4, "h5": 5, "h6": 6}
+
+	// htmlHiddenTags holds the tags dropped with their content under
+	// HTMLTranslate, as their content is not text to show.
+	htmlHiddenTags = map[string]bool{"script": true, "style": true, "template": true, "noscript": true, "iframe": true}
+)
+
+// htmlToken is a tag or a run of text in an HTML fragment.
+type htmlToken struct {
+	// Tag is the lowercase tag name, or "" for text.
+	Tag string
+
+	// Closing reports whether the tag is a closing tag.
+	Closing bool
+
+	// Text is the unescaped text of a text token.
+	Text string
+}
+
+// tokenizeHTML splits an HTML fragment into tags and text. Comments are
+// dropped.
+func tokenizeHTML(s string) []htmlToken {
+	var tokens []htmlToken
+	last := 0
+	for _, m := range htmlTokenRegexp.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] > last {
+			tokens = append(tokens, htmlToken{Text: html.UnescapeString(s[last:m[0]])})
+		}
+		last = m[1]
+		if m[4] < 0 {
+			continue // comment
+		}
+		tokens = append(tokens, htmlToken{
+			Tag:     strings.ToLower(s[m[4]:m[5]]),
+			Closing: m[3] > m[2],
+		})
+	}
+	if last < len(s) {
+		tokens = append(tokens, htmlToken{Text: html.UnescapeString(s[last:])})
+	}
+	return tokens
+}
+
+// dropHiddenContent removes the tokens between the opening and closing tags of
+// htmlHiddenTags, keeping the tags themselves. It also returns the hidden tag
+// left open at the end of tokens, if any.
+func dropHiddenContent(tokens []htmlToken) ([]htmlToken, string) {
+	var kept []htmlToken
+	open := ""
+	for _, tok := range tokens {
+		if open != "" {
+			if tok.Closing && tok.Tag == open {
+				open = ""
+				kept = append(kept, tok)
+			}
+			continue
+		}
+		if !tok.Closing && htmlHiddenTags[tok.Tag] {
+			open = tok.Tag
+		}
+		kept = append(kept, tok)
+	}
+	return kept, open
+}
+
+// dropHiddenSiblings removes the inline nodes following node up to the raw
+// HTML that closes tag, or up to the end of the block, so that the content of
+// a hidden tag opened by node is not rendered.
+func dropHiddenSiblings(node ast.Node, tag string, source []byte) {
+	closing := htmlToken{Tag: tag, Closing: true}
+	parent := node.Parent()
+	for c := node.NextSibling(); c != nil; {
+		if raw, ok := c.(*ast.RawHTML); ok && slices.Contains(tokenizeHTML(rawHTMLText(raw, source)), closing) {
+			return
+		}
+		next := c.NextSibling()
+		parent.RemoveChild(parent, c)
+		c = next
+	}
+}
+
+// rawHTMLText returns the source of an inline HTML node.
+func rawHTMLText(n *ast.RawHTML, source []byte) string {
+	var b strings.Builder
+	for i := 0; i < n.Segments.Len(); i++ {
+		segment := n.Segments.At(i)
+		b.Write(segment.Value(source))
+	}
+	return b.String()
+}
+
+// htmlTagMark returns the mark an inline HTML tag translates to.
+func htmlTagMark(tag string) (Mark, bool) {
+	switch tag {
+	case "b", "strong":
+		return NewStrongMark(), true
+	case "i", "em":
+		return NewEmMark(), true
+	case "u", "ins":
+		return NewUnderlineMark(), true
+	case "s", "del", "strike":
+		return NewStrikeMark(), true
+	case "sub", "sup":
+		return NewSubSupMark(tag), true
+	case "code", "kbd", "samp", "tt":
+		return NewCodeMark(), true
+	case "mark":
+		return NewBackgroundColorMark(htmlHighlightColor), true
+	}
+	return Mark{}, false
+}
+
+// htmlMark is a mark opened by an inline HTML tag. It applies to the text
+// that follows within the same block until the matching closing tag.
+type htmlMark struct {
+	tag   string
+	mark  Mark
+	block ast.Node
+}
+
+// blockOf returns the nearest block ancestor of an inline node.
+func blockOf(n ast.Node) ast.Node {
+	for n != nil && n.Type() != ast.TypeBlock {
+		n = n.Parent()
+	}
+	return n
+}
+
+// openHTMLMark applies the mark of tag to the following text in block.
+func (s *renderState) openHTMLMark(tag string, mark Mark, block ast.Node) {
+	s.htmlMarks = append(s.htmlMarks, htmlMark{tag: tag, mark: mark, block: block})
+}
+
+// closeHTMLMark removes the innermost mark opened by tag in block.
+func (s *renderState) closeHTMLMark(tag string, block ast.Node) {
+	for i := len(s.htmlMarks) - 1; i >= 0; i-- {
+		if s.htmlMarks[i].tag == tag && s.htmlMarks[i].block == block {
+			s.htmlMarks = append(s.htmlMarks[:i], s.htmlMarks[i+1:]...)
+			return
+		}
+	}
+}
+
+// textMarks returns the marks that apply to the text of node: the active
+// Markdown marks followed by the HTML marks opened in the same block.
+func (s *renderState) textMarks(node ast.Node) []Mark {
+	marks := s.currentMarks()
+	if len(s.htmlMarks) == 0 {
+		return marks
+	}
+	block := blockOf(node)
+	for _, m := range s.htmlMarks {
+		if m.block == block {
+			marks = append(marks, m.mark)
+		}
+	}
+	return marks
+}
+
+func (r *Renderer) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	raw := strings.TrimRight(htmlBlockText(node.(*ast.HTMLBlock), source), "\n")
+	if strings.TrimSpace(raw) == "" {
+		return ast.WalkSkipChildren, nil
+	}
+
+	switch r.config.HTMLPolicy {
+	case HTMLDrop:
+		r.reportDroppedHTML(st, node, raw)
+	case HTMLText:
+		para := NewParagraph()
+		for i, line := range strings.Split(raw, "\n") {
+			if i > 0 {
+				para.AppendChild(*NewHardBreak())
+			}
+			if line != "" {
+				para.AppendChild(*NewText(line))
+			}
+		}
+		st.appendToCurrentOrDocument(*para)
+	case HTMLCodeBlock:
+		code := NewCodeBlock("html")
+		code.AppendChild(*NewText(raw))
+		st.appendToCurrentOrDocument(*code)
+	case HTMLTranslate:
+		blocks, err := r.translateHTMLBlock(st, node, raw)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		parentType := st.parentType()
+		for _, b := range blocks {
+			for _, n := range r.fitHTMLBlock(st, node, parentType, b) {
+				st.appendToCurrentOrDocument(n)
+			}
+		}
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *Renderer) renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	raw := rawHTMLText(node.(*ast.RawHTML), source)
+
+	switch r.config.HTMLPolicy {
+	case HTMLDrop:
+		r.reportDroppedHTML(st, node, raw)
+	case HTMLText, HTMLCodeBlock:
+		if r.config.HTMLPolicy == HTMLCodeBlock {
+			st.pushMark(NewCodeMark())
+			defer st.popMark()
+		}
+		textNode, err := r.newMarkedText(st, node, raw)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		st.appendToCurrentOrDocument(*textNode)
+	case HTMLTranslate:
+		block := blockOf(node)
+		tokens, hidden := dropHiddenContent(tokenizeHTML(raw))
+		if hidden != "" {
+			dropHiddenSiblings(node, hidden, source)
+		}
+		for _, tok := range tokens {
+			switch {
+			case tok.Tag == "":
+				if tok.Text == "" {
+					continue
+				}
+				textNode, err := r.newMarkedText(st, node, tok.Text)
+				if err != nil {
+					return ast.WalkStop, err
+				}
+				st.appendToCurrentOrDocument(*textNode)
+			case tok.Tag == "br":
+				st.appendToCurrentOrDocument(*NewHardBreak())
+			default:
+				if mark, ok := htmlTagMark(tok.Tag); ok {
+					if tok.Closing {
+						st.closeHTMLMark(tok.Tag, block)
+					} else {
+						st.openHTMLMark(tok.Tag, mark, block)
+					}
+				} else if !tok.Closing {
+					r.reportRemovedTag(st, node, tok.Tag)
+				}
+			}
+		}
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// reportDroppedHTML reports raw HTML discarded under HTMLDrop. Comments are
+// dropped silently.
+func (r *Renderer) reportDroppedHTML(st *renderState, node ast.Node, raw string) {
+	if strings.HasPrefix(strings.TrimSpace(raw), "<!--") {
+		return
+	}
+	r.report(st, node, SeverityWarning, DiagnosticHTMLDropped, "raw HTML %q dropped", truncate(raw, 40))
+}
+
+// reportRemovedTag reports an HTML tag with no ADF equivalent.
+func (r *Renderer) reportRemovedTag(st *renderState, node ast.Node, tag string) {
+	r.report(st, node, SeverityWarning, DiagnosticHTMLTagRemoved, "HTML tag <%s> has no ADF equivalent; removed", tag)
+}
+
+// truncate shortens s to at most n characters on its first line.
+func truncate(s string, n int) string {
+	s, _, cut := strings.Cut(strings.TrimSpace(s), "\n")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	} else if cut {
+		return s + "…"
+	}
+	return s
+}
+
+// fitHTMLBlock returns the nodes to render a translated HTML block as in a
+// node of parentType. Where the block is not allowed, a heading becomes a bold
+// paragraph, a table becomes the paragraphs of its cells and a rule is
+// dropped.
+func (r *Renderer) fitHTMLBlock(st *renderState, node ast.Node, parentType string, b Node) []Node {
+	if canContain(parentType, b.Type) {
+		return []Node{b}
+	}
+	switch b.Type {
+	case "heading":
+		r.report(st, node, SeverityWarning, DiagnosticHTMLBlockFlattened, "%s cannot contain heading; rendered as a bold paragraph", parentType)
+		para := NewParagraph()
+		para.Content = b.Content
+		return []Node{withStrongText(*para)}
+	case "table":
+		r.report(st, node, SeverityWarning, DiagnosticHTMLBlockFlattened, "%s cannot contain table; rendered as paragraphs", parentType)
+		var nodes []Node
+		for _, row := range b.Content {
+			for _, cell := range row.Content {
+				for _, c := range cell.Content {
+					if cell.Type == "tableHeader" && c.Type == "paragraph" {
+						c = withStrongText(c)
+					}
+					nodes = append(nodes, r.fitHTMLBlock(st, node, parentType, c)...)
+				}
+			}
+		}
+		return nodes
+	}
+	r.report(st, node, SeverityWarning, DiagnosticHTMLBlockFlattened, "%s cannot contain %s; dropped", parentType, b.Type)
+	return nil
+}
+
+// htmlBlockBuilder translates an HTML block into ADF block nodes.
+type htmlBlockBuilder struct {
+	r      *Renderer
+	st     *renderState
+	node   ast.Node
+	blocks []Node
+
+	// inline is the paragraph, heading or table cell paragraph receiving text
+	inline *Node
+	marks  []htmlMark
+
+	table *Node
+	row   *Node
+	cell  *Node
+}
+
+// translateHTMLBlock translates the HTMLTranslate subset of an HTML block
+// into ADF block nodes.
+func (r *Renderer) translateHTMLBlock(st *renderState, node ast.Node, raw string) ([]Node, error) {
+	b := &htmlBlockBuilder{r: r, st: st, node: node}
+	tokens, _ := dropHiddenContent(tokenizeHTML(raw))
+	for _, tok := range tokens {
+		if err := b.token(tok); err != nil {
+			return nil, err
+		}
+	}
+	b.endTable()
+	b.flush()
+	return b.blocks, nil
+}
+
+// token adds a single token to the output.
+func (b *htmlBlockBuilder) token(tok htmlToken) error {
+	if tok.Tag == "" {
+		return b.text(tok.Text)
+	}
+	if mark, ok := htmlTagMark(tok.Tag); ok {
+		if tok.Closing {
+			for i := len(b.marks) - 1; i >= 0; i-- {
+				if b.marks[i].tag == tok.Tag {
+					b.marks = append(b.marks[:i], b.marks[i+1:]...)
+					break
+				}
+			}
+		} else {
+			b.marks = append(b.marks, htmlMark{tag: tok.Tag, mark: mark})
+		}
+		return nil
+	}
+
+	switch tok.Tag {
+	case "br":
+		if b.inline != nil {
+			b.inline.AppendChild(*NewHardBreak())
+		}
+	case "hr":
+		b.flush()
+		b.add(*NewRule())
+	case "p", "div":
+		b.flush()
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		b.flush()
+		if !tok.Closing && b.table == nil {
+			b.inline = NewHeading(htmlHeadingLevel[tok.Tag])
+		}
+	case "table":
+		if tok.Closing {
+			b.endTable()
+		} else if b.table == nil {
+			b.flush()
+			b.table = b.r.newTable()
+		}
+	case "tr":
+		if b.table != nil {
+			b.endRow()
+			if !tok.Closing {
+				b.row = NewTableRow()
+			}
+		}
+	case "th", "td":
+		if b.table != nil {
+			b.endCell()
+			if !tok.Closing {
+				if b.row == nil {
+					b.row = NewTableRow()
+				}
+				if tok.Tag == "th" {
+					b.cell = NewTableHeader()
+				} else {
+					b.cell = NewTableCell()
+				}
+			}
+		}
+	case "thead", "tbody", "tfoot":
+		// Rows are added to the table directly
+	default:
+		if !tok.Closing {
+			b.r.reportRemovedTag(b.st, b.node, tok.Tag)
+		}
+	}
+	return nil
+}
+
+// text appends text, with whitespace collapsed, to the current inline node.
+func (b *htmlBlockBuilder) text(s string) error {
+	s = htmlSpaceRegexp.ReplaceAllString(s, " ")
+	if b.inline == nil || len(b.inline.Content) == 0 {
+		s = strings.TrimLeft(s, " ")
+	}
+	if s == "" || b.table != nil && b.cell == nil {
+		return nil
+	}
+	if b.inline == nil {
+		b.inline = NewParagraph()
+	}
+
+	var active []Mark
+	for _, m := range b.marks {
+		active = append(active, m.mark)
+	}
+	textNode, err := b.r.newResolvedText(b.st, b.node, s, active)
+	if err != nil {
+		return err
+	}
+	b.inline.AppendChild(*textNode)
+	return nil
+}
+
+// flush ends the current paragraph or heading, dropping trailing whitespace.
+func (b *htmlBlockBuilder) flush() {
+	n := b.inline
+	b.inline = nil
+	if n == nil {
+		return
+	}
+	if last := len(n.Content) - 1; last >= 0 && n.Content[last].Type == "text" {
+		n.Content[last].Text = strings.TrimRight(n.Content[last].Text, " ")
+		if n.Content[last].Text == "" {
+			n.Content = n.Content[:last]
+		}
+	}
+	if len(n.Content) == 0 && n.Type == "paragraph" {
+		return
+	}
+	b.add(*n)
+}
+
+// add appends a finished block to the output, or to the current table cell.
+func (b *htmlBlockBuilder) add(n Node) {
+	if b.cell != nil {
+		b.cell.AppendChild(n)
+		return
+	}
+	b.blocks = append(b.blocks, n)
+}
+
+func (b *htmlBlockBuilder) endCell() {
+	b.flush()
+	if b.cell == nil {
+		return
+	}
+	// Table cells require at least one block
+	if len(b.cell.Content) == 0 {
+		b.cell.AppendChild(*NewParagraph())
+	}
+	b.row.AppendChild(*b.cell)
+	b.cell = nil
+}
+
+func (b *htmlBlockBuilder) endRow() {
+	b.endCell()
+	if b.row != nil && len(b.row.Content) > 0 {
+		b.table.AppendChild(*b.row)
+	}
+	b.row = nil
+}
+
+func (b *htmlBlockBuilder) endTable() {
+	if b.table == nil {
+		return
+	}
+	b.endRow()
+	table := b.table
+	b.table = nil
+	if len(table.Content) > 0 {
+		b.add(*table)
+	}
+}
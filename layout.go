@@ -0,0 +1,88 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// layoutSectionContainer renders :::layoutSection (or :::columns) as a
+// layoutSection. Its content must be two or three layoutColumn containers.
+func layoutSectionContainer(c ContainerContext) (*Node, error) {
+	attrs, err := stringAttrs(c.Attrs, "localId")
+	if err != nil {
+		return nil, err
+	}
+	n := NewLayoutSection()
+	if len(attrs) > 0 {
+		n.Attrs = attrs
+	}
+	return n, nil
+}
+
+// layoutColumnContainer renders :::layoutColumn {width=50} (or :::column) as
+// a layoutColumn. The width is a percentage, optionally followed by '%';
+// columns without one share what the others leave of 100 equally.
+func layoutColumnContainer(c ContainerContext) (*Node, error) {
+	attrs := map[string]any{}
+	for name, value := range c.Attrs {
+		if name == "width" {
+			continue
+		}
+		attrs[name] = value
+	}
+	attrs, err := stringAttrs(attrs, "width", "localId")
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := c.Attrs["width"]; ok {
+		s := strings.TrimSuffix(fmt.Sprint(value), "%")
+		width, err := strconv.ParseFloat(s, 64)
+		if err != nil || width <= 0 || width > 100 {
+			return nil, fmt.Errorf("column width %q is not a number between 0 and 100", fmt.Sprint(value))
+		}
+		attrs["width"] = width
+	}
+	n := NewLayoutColumn(0)
+	n.Attrs = attrs
+	return n, nil
+}
+
+// layoutWidthTolerance is how far column widths may add up to from 100, so
+// that three columns of 33.33 are accepted.
+const layoutWidthTolerance = 0.1
+
+// resolveColumnWidths gives the columns of a layoutSection without a width an
+// equal share of what the others leave of 100, and checks that the widths add
+// up to 100.
+func resolveColumnWidths(n *Node) error {
+	var total float64
+	unset := 0
+	for _, col := range n.Content {
+		if width, ok := col.Attrs["width"].(float64); ok {
+			total += width
+		} else {
+			unset++
+		}
+	}
+
+	if unset > 0 {
+		share := math.Round((100-total)/float64(unset)*100) / 100
+		if share <= 0 {
+			return fmt.Errorf("column widths add up to %g, leaving no room for the columns without a width", total)
+		}
+		for i := range n.Content {
+			if _, ok := n.Content[i].Attrs["width"].(float64); !ok {
+				n.Content[i].Attrs["width"] = share
+				total += share
+			}
+		}
+	}
+	if math.Abs(total-100) > layoutWidthTolerance {
+		return fmt.Errorf("column widths add up to %g, not 100", total)
+	}
+	return nil
+}
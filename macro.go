@@ -0,0 +1,578 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// DefaultMacroExtensionType is the extensionType of Confluence's built-in
+// macros, used for registered macros that do not give one.
+const DefaultMacroExtensionType = "com.atlassian.confluence.macro.core"
+
+// MacroKind selects the ADF node a Confluence macro renders as.
+type MacroKind int
+
+const (
+	// MacroExtension renders the macro as an extension block.
+	MacroExtension MacroKind = iota
+
+	// MacroBodiedExtension renders the macro as a bodiedExtension, whose
+	// content is the Markdown following the parameters and a "---" line.
+	MacroBodiedExtension
+
+	// MacroInlineExtension renders the macro as an inlineExtension in a
+	// paragraph of its own.
+	MacroInlineExtension
+)
+
+// Macro describes how a Confluence macro renders as an ADF extension node.
+type Macro struct {
+	// Kind selects the extension node. Defaults to MacroExtension.
+	Kind MacroKind
+
+	// ExtensionType is the extensionType attribute. Defaults to
+	// DefaultMacroExtensionType.
+	ExtensionType string
+
+	// ExtensionKey is the extensionKey attribute. Defaults to the macro
+	// name.
+	ExtensionKey string
+
+	// Layout is the layout of extension and bodiedExtension nodes unless the
+	// fence gives one: "default", "wide" or "full-width". Defaults to
+	// "default".
+	Layout string
+}
+
+// UnknownMacroPolicy selects how confluence-macro fences naming a macro that
+// is not in [Config.Macros] are rendered.
+type UnknownMacroPolicy int
+
+const (
+	// UnknownMacroCodeBlock renders the fence as the code block it would be
+	// without macro support. This is the default.
+	UnknownMacroCodeBlock UnknownMacroPolicy = iota
+
+	// UnknownMacroError stops the conversion with a [*MacroError].
+	UnknownMacroError
+)
+
+// defaultMacros returns the macros registered by default.
+func defaultMacros() map[string]Macro {
+	return map[string]Macro{
+		"toc":      {Kind: MacroExtension},
+		"children": {Kind: MacroExtension},
+		"jira":     {Kind: MacroExtension},
+		"excerpt":  {Kind: MacroBodiedExtension},
+		"anchor":   {Kind: MacroInlineExtension},
+	}
+}
+
+// KindConfluenceMacro is a NodeKind of the ConfluenceMacro node.
+var KindConfluenceMacro = ast.NewNodeKind("ConfluenceMacro")
+
+// ConfluenceMacro is a block node representing a fenced Confluence macro:
+//
+//	```confluence-macro toc {layout=wide}
+//	maxLevel: 3
+//	```
+//
+// Its lines are the lines of the fence as written: the YAML parameters and,
+// for bodied macros, a "---" line and the body, which is also parsed as its
+// children.
+type ConfluenceMacro struct {
+	ast.BaseBlock
+
+	// Name is the macro name.
+	Name string
+
+	// Attrs holds the name=value attributes following the name, unparsed.
+	Attrs string
+
+	fenceChar   byte
+	fenceLength int
+
+	// fence is the opening fence line, which locates the macro in errors and
+	// diagnostics.
+	fence text.Segment
+
+	// bodyStart is the index of the first body line, or -1 if the fence has
+	// no "---" line.
+	bodyStart int
+
+	// nodeType is the ADF type the macro rendered as.
+	nodeType string
+}
+
+// NewConfluenceMacro creates a new ConfluenceMacro node.
+func NewConfluenceMacro(name, attrs string) *ConfluenceMacro {
+	return &ConfluenceMacro{Name: name, Attrs: attrs, bodyStart: -1}
+}
+
+// Kind implements ast.Node.Kind.
+func (n *ConfluenceMacro) Kind() ast.NodeKind {
+	return KindConfluenceMacro
+}
+
+// IsRaw implements ast.Node.IsRaw. The lines of a macro are not parsed as
+// inline content; its body is parsed as its children.
+func (n *ConfluenceMacro) IsRaw() bool {
+	return true
+}
+
+// Dump implements ast.Node.Dump.
+func (n *ConfluenceMacro) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.Name, "Attrs": n.Attrs}, nil)
+}
+
+// params returns the parameter lines of the macro.
+func (n *ConfluenceMacro) params(source []byte) []string {
+	lines := n.Lines()
+	end := lines.Len()
+	if n.bodyStart >= 0 {
+		end = n.bodyStart - 1
+	}
+	params := make([]string, end)
+	for i := range end {
+		line := lines.At(i)
+		params[i] = string(line.Value(source))
+	}
+	return params
+}
+
+var macroOpenRegexp = regexp.MustCompile("^(`{3,}|~{3,})[ \t]*confluence-macro[ \t]+([A-Za-z][A-Za-z0-9_.-]*)(?:[ \t]+(.*?))?[ \t]*\n?$")
+
+// confluenceMacroParser parses fenced Confluence macros.
+type confluenceMacroParser struct{}
+
+// NewConfluenceMacroParser returns a parser.BlockParser that parses fenced
+// code blocks whose info string is "confluence-macro <name> [attrs]" into
+// [ConfluenceMacro] nodes. It must take precedence over the fenced code block
+// parser.
+func NewConfluenceMacroParser() parser.BlockParser {
+	return &confluenceMacroParser{}
+}
+
+// Trigger implements parser.BlockParser.
+func (p *confluenceMacroParser) Trigger() []byte {
+	return []byte{'`', '~'}
+}
+
+// Open implements parser.BlockParser.
+func (p *confluenceMacroParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+	m := macroOpenRegexp.FindSubmatch(line[pos:])
+	if m == nil || m[1][0] == '`' && bytes.IndexByte(m[3], '`') >= 0 {
+		return nil, parser.NoChildren
+	}
+	attrs := string(m[3])
+	if strings.HasPrefix(attrs, "{") && strings.HasSuffix(attrs, "}") {
+		attrs = strings.TrimSpace(attrs[1 : len(attrs)-1])
+	}
+	node := NewConfluenceMacro(string(m[2]), attrs)
+	node.fenceChar, node.fenceLength = m[1][0], len(m[1])
+	node.fence = text.NewSegment(segment.Start+pos, segment.Stop)
+	reader.Advance(segment.Len() - util.TrimRightSpaceLength(line))
+	return node, parser.NoChildren
+}
+
+// Continue implements parser.BlockParser.
+func (p *confluenceMacroParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*ConfluenceMacro)
+	line, segment := reader.PeekLine()
+	w, pos := util.IndentWidth(line, reader.LineOffset())
+	if w < 4 && isClosingFence(line[pos:], n.fenceChar, n.fenceLength) && isInnermostContainer(node, pc) {
+		reader.Advance(segment.Len() - util.TrimRightSpaceLength(line))
+		return parser.Close
+	}
+	n.Lines().Append(segment)
+	if n.bodyStart >= 0 {
+		return parser.Continue | parser.HasChildren
+	}
+	if string(util.TrimRightSpace(util.TrimLeftSpace(line))) == "---" {
+		n.bodyStart = n.Lines().Len()
+	}
+	return parser.Continue | parser.NoChildren
+}
+
+// isClosingFence reports whether line closes a fence of at least length
+// fence characters.
+func isClosingFence(line []byte, fence byte, length int) bool {
+	i := 0
+	for i < len(line) && line[i] == fence {
+		i++
+	}
+	return i >= length && util.IsBlank(line[i:])
+}
+
+// Close implements parser.BlockParser.
+func (p *confluenceMacroParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	// nothing to do
+}
+
+// CanInterruptParagraph implements parser.BlockParser.
+func (p *confluenceMacroParser) CanInterruptParagraph() bool {
+	return true
+}
+
+// CanAcceptIndentedLine implements parser.BlockParser.
+func (p *confluenceMacroParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// addConfluenceMacroParser adds the Confluence macro parser.
+func addConfluenceMacroParser(md goldmark.Markdown) {
+	md.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(NewConfluenceMacroParser(), 690),
+		),
+	)
+}
+
+// parseMacroParams parses macro parameters written in a subset of YAML: one
+// "name: value" pair per line, where the name and value are plain,
+// single-quoted or double-quoted scalars, or the value is a list given as
+// [a, b] or as "- item" lines below the name. Lists become comma-separated
+// values, as Confluence stores them. Blank lines and comments are ignored.
+func parseMacroParams(lines []string) (map[string]string, error) {
+	params := map[string]string{}
+	var list string
+	var items []string
+	inList := false
+	flush := func() {
+		if inList {
+			params[list] = strings.Join(items, ",")
+		}
+		list, items, inList = "", nil, false
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok || trimmed == "-" {
+			if !inList {
+				return nil, fmt.Errorf("parameter line %d: list item without a name", i+1)
+			}
+			value, err := parseYAMLScalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("parameter line %d: %v", i+1, err)
+			}
+			items = append(items, value)
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("parameter line %d: nested mappings are not supported", i+1)
+		}
+		flush()
+
+		name, value, err := cutMacroParamName(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("parameter line %d: %v", i+1, err)
+		}
+		if _, dup := params[name]; dup {
+			return nil, fmt.Errorf("parameter %q given twice", name)
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case value == "" || strings.HasPrefix(value, "#"):
+			// A list of "- item" lines may follow
+			params[name] = ""
+			list, inList = name, true
+		case value[0] == '[':
+			values, err := parseYAMLFlowList(value)
+			if err != nil {
+				return nil, fmt.Errorf("parameter line %d: %v", i+1, err)
+			}
+			params[name] = strings.Join(values, ",")
+		default:
+			s, err := parseYAMLScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("parameter line %d: %v", i+1, err)
+			}
+			params[name] = s
+		}
+	}
+	flush()
+	return params, nil
+}
+
+// cutMacroParamName parses the name at the start of a "name: value" line and
+// returns it with the value. A quoted name is unquoted like a value, so that
+// it may be empty or contain ": ".
+func cutMacroParamName(line string) (string, string, error) {
+	if strings.HasPrefix(line, `"`) || strings.HasPrefix(line, "'") {
+		name, rest, err := cutYAMLScalar(line, "")
+		if err != nil {
+			return "", "", err
+		}
+		value, ok := strings.CutPrefix(strings.TrimLeft(rest, " \t"), ":")
+		if !ok || value != "" && value[0] != ' ' && value[0] != '\t' {
+			return "", "", fmt.Errorf("expected name: value")
+		}
+		return name, value, nil
+	}
+	name, value, ok := strings.Cut(line, ": ")
+	if !ok {
+		name, ok = strings.CutSuffix(line, ":")
+	}
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("expected name: value")
+	}
+	return name, value, nil
+}
+
+// parseYAMLScalar parses a plain, single-quoted or double-quoted YAML scalar,
+// optionally followed by a comment.
+func parseYAMLScalar(s string) (string, error) {
+	value, rest, err := cutYAMLScalar(s, "")
+	if err != nil {
+		return "", err
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after value", rest)
+	}
+	return value, nil
+}
+
+// parseYAMLFlowList parses a [a, b] list of scalars.
+func parseYAMLFlowList(s string) ([]string, error) {
+	rest := strings.TrimSpace(s[1:])
+	values := []string{}
+	for !strings.HasPrefix(rest, "]") {
+		value, after, err := cutYAMLScalar(rest, ",]")
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		rest = strings.TrimSpace(after)
+		if next, ok := strings.CutPrefix(rest, ","); ok {
+			rest = strings.TrimSpace(next)
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, fmt.Errorf("unterminated list %s", s)
+		}
+	}
+	if rest = strings.TrimSpace(rest[1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, fmt.Errorf("unexpected %q after list", rest)
+	}
+	return values, nil
+}
+
+// cutYAMLScalar parses the scalar at the start of s and returns it with the
+// rest of s. Plain scalars end at a comment or at one of the stop
+// characters.
+func cutYAMLScalar(s, stop string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; c {
+			case '"':
+				return b.String(), s[i+1:], nil
+			case '\\':
+				if i+1 == len(s) {
+					return "", "", fmt.Errorf("unterminated string %s", s)
+				}
+				i++
+				switch s[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case '"', '\\', '/':
+					b.WriteByte(s[i])
+				default:
+					return "", "", fmt.Errorf("unsupported escape \\%c", s[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string %s", s)
+	case strings.HasPrefix(s, "'"):
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+			} else if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+			} else {
+				return b.String(), s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string %s", s)
+	}
+	end := len(s)
+	if i := strings.IndexAny(s, stop); i >= 0 {
+		end = i
+	}
+	if i := strings.Index(s[:end], " #"); i >= 0 {
+		end = i
+	}
+	return strings.TrimSpace(s[:end]), s[end:], nil
+}
+
+// MacroError is returned when a confluence-macro fence cannot be rendered,
+// for instance because its macro is unknown under [UnknownMacroError] or its
+// parameters are invalid.
+type MacroError struct {
+	// Line and Column are the 1-based position of the macro's opening fence
+	// in the Markdown source, or 0 if unknown.
+	Line   int
+	Column int
+
+	// Name is the macro name.
+	Name string
+
+	// Err describes the problem.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *MacroError) Error() string {
+	return fmt.Sprintf("adf: %d:%d: confluence-macro %s: %v", e.Line, e.Column, e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *MacroError) Unwrap() error {
+	return e.Err
+}
+
+// macroError reports a problem with a macro as an error diagnostic and
+// returns it as a [*MacroError].
+func (r *Renderer) macroError(st *renderState, n *ConfluenceMacro, code string, err error) error {
+	r.report(st, n, SeverityError, code, "confluence-macro %s: %v", n.Name, err)
+	merr := &MacroError{Name: n.Name, Err: err}
+	if offset, ok := sourceOffset(n); ok {
+		merr.Line, merr.Column = sourcePosition(st.source, offset)
+	}
+	return merr
+}
+
+// newMacroNode creates the extension node a registered macro renders as,
+// without its body.
+func newMacroNode(n *ConfluenceMacro, macro Macro, source []byte) (*Node, error) {
+	parsed, err := parseContainerAttrs(n.Attrs)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := stringAttrs(parsed, "layout", "localId")
+	if err != nil {
+		return nil, err
+	}
+	if n.bodyStart >= 0 && macro.Kind != MacroBodiedExtension {
+		return nil, fmt.Errorf("macro does not take a body")
+	}
+	params, err := parseMacroParams(n.params(source))
+	if err != nil {
+		return nil, err
+	}
+
+	attrs["extensionType"] = macro.ExtensionType
+	if macro.ExtensionType == "" {
+		attrs["extensionType"] = DefaultMacroExtensionType
+	}
+	attrs["extensionKey"] = macro.ExtensionKey
+	if macro.ExtensionKey == "" {
+		attrs["extensionKey"] = n.Name
+	}
+	macroParams := make(map[string]any, len(params))
+	for name, value := range params {
+		macroParams[name] = map[string]any{"value": value}
+	}
+	attrs["parameters"] = map[string]any{
+		"macroParams":   macroParams,
+		"macroMetadata": map[string]any{"schemaVersion": map[string]any{"value": "1"}},
+	}
+
+	if macro.Kind == MacroInlineExtension {
+		if _, ok := attrs["layout"]; ok {
+			return nil, fmt.Errorf("inline macros have no layout")
+		}
+		return &Node{Type: "inlineExtension", Attrs: attrs}, nil
+	}
+	if attrs["layout"] == nil {
+		attrs["layout"] = cmp.Or(macro.Layout, "default")
+	}
+	if layout := attrs["layout"]; layout != "default" && layout != "wide" && layout != "full-width" {
+		return nil, fmt.Errorf("layout %q is not one of default, wide, full-width", layout)
+	}
+	if macro.Kind == MacroBodiedExtension {
+		return &Node{Type: "bodiedExtension", Attrs: attrs, Content: []Node{}}, nil
+	}
+	return &Node{Type: "extension", Attrs: attrs}, nil
+}
+
+func (r *Renderer) renderConfluenceMacro(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
+	n := node.(*ConfluenceMacro)
+	if !entering {
+		if n.nodeType == "bodiedExtension" {
+			if err := checkContent(st.currentNode()); err != nil {
+				return ast.WalkStop, r.macroError(st, n, DiagnosticMacroInvalid, err)
+			}
+			st.popNode()
+		}
+		return ast.WalkContinue, nil
+	}
+
+	macro, ok := r.config.Macros[n.Name]
+	if !ok {
+		if r.config.UnknownMacros == UnknownMacroError {
+			return ast.WalkStop, r.macroError(st, n, DiagnosticMacroUnknown, fmt.Errorf("unknown macro"))
+		}
+		r.report(st, n, SeverityWarning, DiagnosticMacroUnknown, "unknown macro %q; rendered as a code block", n.Name)
+		codeNode := NewCodeBlock("confluence-macro")
+		var b strings.Builder
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			b.Write(line.Value(source))
+		}
+		if b.Len() > 0 {
+			codeNode.AppendChild(*NewText(b.String()))
+		}
+		st.appendToCurrentOrDocument(*codeNode)
+		return ast.WalkSkipChildren, nil
+	}
+
+	ext, err := newMacroNode(n, macro, source)
+	if err != nil {
+		return ast.WalkStop, r.macroError(st, n, DiagnosticMacroInvalid, err)
+	}
+	n.nodeType = ext.Type
+	if ext.Type == "inlineExtension" {
+		para := NewParagraph()
+		para.AppendChild(*ext)
+		st.appendToCurrentOrDocument(*para)
+		return ast.WalkSkipChildren, nil
+	}
+	parentType := st.parentType()
+	if rule, ok := contentRules[parentType]; ok && !slices.Contains(rule.types, ext.Type) {
+		return ast.WalkStop, r.macroError(st, n, DiagnosticMacroInvalid, fmt.Errorf("%s cannot contain %s", parentType, ext.Type))
+	}
+	if ext.Type == "bodiedExtension" {
+		st.pushNode(ext)
+		return ast.WalkContinue, nil
+	}
+	st.appendToCurrentOrDocument(*ext)
+	return ast.WalkSkipChildren, nil
+}
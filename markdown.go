@@ -0,0 +1,1110 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"html"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ToMarkdown converts ADF JSON to CommonMark Markdown with GFM extensions.
+// It is a convenience wrapper around [Document.Markdown].
+func ToMarkdown(data []byte) ([]byte, error) {
+	doc, err := ParseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Markdown(), nil
+}
+
+// Markdown renders the document as CommonMark Markdown with GFM extensions
+// (tables, strikethrough and task lists), [D]/[d] decision lists, :::columns
+// layouts and confluence-macro fences.
+//
+// Every node and mark produced by [Renderer] is converted so that parsing the
+// result with [NewWithGFM] yields an equivalent document. ADF-only content is
+// converted with the following lossy fallbacks:
+//
+//   - panel: a GitHub-style alert blockquote (info as [!NOTE], success and tip
+//     as [!TIP], note as [!IMPORTANT], warning as [!WARNING], error as
+//     [!CAUTION]); custom panel colours and icons are dropped
+//   - expand, nestedExpand: a <details> block with the title as <summary>
+//   - mention: the display text, e.g. "@Jane Doe"
+//   - emoji: the :shortname: shortcode; emoji whose shortName is not a
+//     shortcode become their unicode text
+//   - status: a {status:Text|color} lozenge; text that cannot be written in
+//     one becomes inline code and the colour is dropped
+//   - date: a {date:YYYY-MM-DD} macro in UTC, with the time if it is not
+//     midnight
+//   - inlineCard, blockCard, embedCard: an autolink to the card URL
+//   - media without a URL (Atlassian media files): the alt text, if any
+//   - layoutSection without two or three layoutColumns: the columns' content
+//     one after another
+//   - extension, bodiedExtension of a Confluence macro (extensionType
+//     com.atlassian.confluence.macro.core): a confluence-macro fence named
+//     after the extensionKey, with the macroParams as YAML parameters
+//   - other bodiedExtensions: a :::bodiedExtension container, or its content
+//     if it has parameters; other extensions and inlineExtension are dropped
+//   - footnotes as rendered by [Renderer] (anchors with superscript links to
+//     a numbered list after a closing rule): [^N] references and
+//     definitions
+//   - underline, subsup marks: <u>, <sub> and <sup> HTML tags
+//   - textColor, backgroundColor and other presentational marks are dropped
+//   - table cell spans and widths are dropped, and tables without a header
+//     row use their first row as the header
+func (d *Document) Markdown() []byte {
+	content := d.Content
+	var footnotes string
+	if n := len(content); n >= 2 && content[n-2].Type == "rule" && isMarkdownFootnoteList(content[n-1]) {
+		footnotes = markdownFootnotes(content[n-1])
+		content = content[:n-2]
+	}
+	s := markdownBlocks(content, false)
+	if footnotes != "" {
+		if s != "" {
+			s += "\n\n"
+		}
+		s += footnotes
+	}
+	if s == "" {
+		return []byte{}
+	}
+	return []byte(s + "\n")
+}
+
+// markdownBlocks renders block nodes separated by blank lines. Inside list
+// items, nested lists directly follow the preceding block to keep lists tight.
+func markdownBlocks(nodes []Node, item bool) string {
+	var b strings.Builder
+	prevList := ""
+	alt := false
+	for _, n := range nodes {
+		// Consecutive lists of the same kind would merge into one list, so
+		// alternate the marker to keep them apart
+		kind := markdownListKind(n)
+		if kind != "" && kind == prevList {
+			alt = !alt
+		} else {
+			alt = false
+		}
+		prevList = kind
+
+		s := markdownBlock(n, alt)
+		if s == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			// Only bullet lists and lists starting at 1 can interrupt a paragraph
+			if item && (kind == "bullet" || kind == "ordered" && attrInt(n.Attrs, "order", 1) == 1) {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// markdownListKind returns "bullet" or "ordered" for nodes rendered as
+// Markdown lists, and "" otherwise.
+func markdownListKind(n Node) string {
+	switch n.Type {
+	case "bulletList", "taskList", "decisionList":
+		return "bullet"
+	case "orderedList":
+		return "ordered"
+	}
+	return ""
+}
+
+// markdownBlock renders a single block node. alt selects the alternative list
+// marker.
+func markdownBlock(n Node, alt bool) string {
+	switch n.Type {
+	case "paragraph":
+		return markdownInline(n.Content, false)
+	case "heading":
+		level := min(max(attrInt(n.Attrs, "level", 1), 1), 6)
+		s := strings.Repeat("#", level)
+		if text := markdownInline(n.Content, false); text != "" {
+			s += " " + strings.ReplaceAll(text, "\\\n", " ")
+		}
+		return s
+	case "blockquote":
+		return prefixLines(markdownBlocks(n.Content, false), "> ")
+	case "codeBlock":
+		return markdownCodeBlock(n)
+	case "rule":
+		return "---"
+	case "bulletList":
+		return markdownList(n.Content, func(int) string { return bulletMarker(alt) })
+	case "decisionList":
+		return markdownDecisionList(n, bulletMarker(alt))
+	case "orderedList":
+		start := attrInt(n.Attrs, "order", 1)
+		delim := "."
+		if alt {
+			delim = ")"
+		}
+		return markdownList(n.Content, func(i int) string { return strconv.Itoa(start+i) + delim })
+	case "taskList":
+		return markdownTaskList(n, bulletMarker(alt))
+	case "table":
+		return markdownTable(n)
+	case "mediaSingle":
+		caption := ""
+		var media []string
+		for _, c := range n.Content {
+			if c.Type == "caption" {
+				caption = plainText(c.Content)
+			}
+		}
+		for _, c := range n.Content {
+			if c.Type == "media" {
+				media = append(media, markdownMedia(c, caption))
+			}
+		}
+		return strings.Join(media, "\n")
+	case "mediaGroup":
+		var media []string
+		for _, c := range n.Content {
+			if s := markdownMedia(c, ""); s != "" {
+				media = append(media, s)
+			}
+		}
+		return strings.Join(media, "\\\n")
+	case "panel":
+		alert, ok := panelAlerts[attrString(n.Attrs, "panelType")]
+		if !ok {
+			alert = "NOTE"
+		}
+		s := "> [!" + alert + "]"
+		if body := markdownBlocks(n.Content, false); body != "" {
+			s += "\n" + prefixLines(body, "> ")
+		}
+		return s
+	case "expand", "nestedExpand":
+		s := "<details>\n"
+		if title := attrString(n.Attrs, "title"); title != "" {
+			s += "<summary>" + html.EscapeString(title) + "</summary>\n"
+		}
+		if body := markdownBlocks(n.Content, false); body != "" {
+			s += "\n" + body + "\n"
+		}
+		return s + "\n</details>"
+	case "blockCard", "embedCard":
+		if url := attrString(n.Attrs, "url"); url != "" {
+			return "<" + url + ">"
+		}
+		return ""
+	case "extension":
+		s, _ := markdownMacro(n)
+		return s
+	case "layoutSection":
+		if s, ok := markdownLayout(n); ok {
+			return s
+		}
+	case "bodiedExtension":
+		if s, ok := markdownMacro(n); ok {
+			return s
+		}
+		if attrs, ok := markdownContainerAttrs(n.Attrs); ok {
+			s := ":::bodiedExtension {" + attrs + "}"
+			if body := markdownBlocks(n.Content, false); body != "" {
+				s += "\n" + body + "\n"
+			}
+			return s + "\n:::"
+		}
+	}
+
+	// Containers such as layoutSection, layoutColumn and bodiedExtension, and
+	// unknown nodes
+	if n.Text != "" {
+		return escapeMarkdown(n.Text, true)
+	}
+	for _, c := range n.Content {
+		if c.Type == "text" {
+			return markdownInline(n.Content, false)
+		}
+	}
+	return markdownBlocks(n.Content, false)
+}
+
+// panelAlerts maps ADF panel types to GitHub alert kinds.
+var panelAlerts = map[string]string{
+	"info":    "NOTE",
+	"note":    "IMPORTANT",
+	"tip":     "TIP",
+	"success": "TIP",
+	"warning": "WARNING",
+	"error":   "CAUTION",
+}
+
+func bulletMarker(alt bool) string {
+	if alt {
+		return "*"
+	}
+	return "-"
+}
+
+// markdownList renders list items, using marker(i) for the i-th item.
+func markdownList(items []Node, marker func(int) string) string {
+	lines := make([]string, 0, len(items))
+	for i, item := range items {
+		m := marker(i)
+		var body string
+		if item.Type == "listItem" {
+			body = markdownBlocks(item.Content, true)
+		} else {
+			// decisionItem and other inline-content items
+			body = markdownInline(item.Content, false)
+		}
+		lines = append(lines, listItemLines(m, body))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownDecisionList renders a decisionList as [D] (decided) and [d]
+// (undecided) list items.
+func markdownDecisionList(n Node, marker string) string {
+	lines := make([]string, 0, len(n.Content))
+	for _, c := range n.Content {
+		box := "[d]"
+		if attrString(c.Attrs, "state") == "DECIDED" {
+			box = "[D]"
+		}
+		body := markdownInline(c.Content, false)
+		lines = append(lines, listItemLines(marker, strings.TrimRight(box+" "+body, " ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownTaskList renders a taskList. Nested task lists are indented under
+// the preceding task item.
+func markdownTaskList(n Node, marker string) string {
+	var lines []string
+	for _, c := range n.Content {
+		switch c.Type {
+		case "taskList":
+			nested := markdownTaskList(c, marker)
+			if len(lines) == 0 {
+				lines = append(lines, nested)
+				continue
+			}
+			lines[len(lines)-1] += "\n" + indentLines(nested, strings.Repeat(" ", len(marker)+1))
+		default:
+			box := "[ ]"
+			if attrString(c.Attrs, "state") == "DONE" {
+				box = "[x]"
+			}
+			var body string
+			if c.Type == "blockTaskItem" {
+				var paras []string
+				for _, p := range c.Content {
+					paras = append(paras, markdownInline(p.Content, false))
+				}
+				body = strings.Join(paras, "\\\n")
+			} else {
+				body = markdownInline(c.Content, false)
+			}
+			lines = append(lines, listItemLines(marker, strings.TrimRight(box+" "+body, " ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// listItemLines prefixes body with a list marker, indenting continuation lines
+// to the item's content column.
+func listItemLines(marker, body string) string {
+	if body == "" {
+		return marker
+	}
+	pad := strings.Repeat(" ", len(marker)+1)
+	lines := strings.Split(body, "\n")
+	lines[0] = marker + " " + lines[0]
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = pad + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownCodeBlock renders a code block as a fenced block with a fence longer
+// than any backtick run in the code.
+func markdownCodeBlock(n Node) string {
+	code := plainText(n.Content)
+	code = strings.TrimSuffix(code, "\n")
+	fence := strings.Repeat("`", max(3, longestRun(code, '`')+1))
+	s := fence + attrString(n.Attrs, "language") + "\n"
+	if code != "" {
+		s += code + "\n"
+	}
+	return s + fence
+}
+
+// markdownTable renders a table as a GFM table. The first row is always used
+// as the header row.
+func markdownTable(n Node) string {
+	var rows [][]string
+	cols := 0
+	for _, row := range n.Content {
+		var cells []string
+		for _, cell := range row.Content {
+			var paras []string
+			for _, block := range cell.Content {
+				if s := markdownTableCell(block); s != "" {
+					paras = append(paras, s)
+				}
+			}
+			cells = append(cells, strings.Join(paras, "<br>"))
+		}
+		cols = max(cols, len(cells))
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 || cols == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for i := 0; i < cols; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+	}
+	writeRow(rows[0])
+	b.WriteString("\n|")
+	for i := 0; i < cols; i++ {
+		// GFM aligns whole columns, so take the alignment from the first row
+		switch tableCellAlignment(n.Content[0], i) {
+		case "center":
+			b.WriteString(" :---: |")
+		case "end":
+			b.WriteString(" ---: |")
+		default:
+			b.WriteString(" --- |")
+		}
+	}
+	for _, row := range rows[1:] {
+		b.WriteString("\n")
+		writeRow(row)
+	}
+	return b.String()
+}
+
+// tableCellAlignment returns the alignment mark value of the first paragraph
+// in a row's cell, or "" if it has none.
+func tableCellAlignment(row Node, i int) string {
+	if i >= len(row.Content) || len(row.Content[i].Content) == 0 {
+		return ""
+	}
+	for _, m := range row.Content[i].Content[0].Marks {
+		if m.Type == "alignment" {
+			return attrString(m.Attrs, "align")
+		}
+	}
+	return ""
+}
+
+// markdownTableCell renders a block inside a table cell on a single line.
+func markdownTableCell(n Node) string {
+	switch n.Type {
+	case "paragraph", "heading":
+		return markdownInline(n.Content, true)
+	case "codeBlock":
+		return codeSpan(strings.ReplaceAll(plainText(n.Content), "\n", " "), true)
+	}
+	var parts []string
+	for _, c := range n.Content {
+		if s := markdownTableCell(c); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "<br>")
+}
+
+// markdownMedia renders a media node as an image. Media without a URL, such as
+// files stored in the Atlassian media service, fall back to their alt text.
+func markdownMedia(n Node, caption string) string {
+	url := attrString(n.Attrs, "url")
+	alt := attrString(n.Attrs, "alt")
+	if url == "" {
+		return escapeMarkdown(alt, true)
+	}
+	return "![" + escapeMarkdown(alt, false) + "](" + linkDestination(url, caption) + ")"
+}
+
+// markdownPiece is a run of inline output sharing the same marks. Plain text
+// pieces are escaped when written, since escaping depends on line position.
+type markdownPiece struct {
+	text  string
+	plain bool
+	marks []Mark
+}
+
+// markdownInline renders inline nodes. Marks are opened and closed as a stack
+// across adjacent nodes so that delimiters never collide, and whitespace is
+// kept outside delimiters that open or close around it.
+func markdownInline(nodes []Node, table bool) string {
+	nodes = mergeTextNodes(nodes)
+	marks := make([][]Mark, len(nodes))
+	for i, n := range nodes {
+		marks[i] = markdownMarks(n.Marks)
+	}
+
+	var pieces []markdownPiece
+	skip := false
+	for i, n := range nodes {
+		if skip {
+			skip = false
+			continue
+		}
+		if index, ok := markdownFootnoteRef(nodes, i); ok {
+			// The anchor and the superscript link become a [^N] reference
+			pieces = append(pieces, markdownPiece{"[^" + index + "]", false, marks[i]})
+			skip = true
+			continue
+		}
+		if n.Type != "text" {
+			pieces = append(pieces, markdownPiece{markdownInlineNode(n, table), false, marks[i]})
+			continue
+		}
+		if hasMark(n.Marks, "code") {
+			pieces = append(pieces, markdownPiece{codeSpan(n.Text, table), false, marks[i]})
+			continue
+		}
+
+		var prev, next []Mark
+		if i > 0 {
+			prev = marks[i-1]
+		}
+		if i < len(nodes)-1 {
+			next = marks[i+1]
+		}
+		core := strings.TrimFunc(n.Text, unicode.IsSpace)
+		if core == "" {
+			pieces = append(pieces, markdownPiece{n.Text, true, commonMarks(commonMarks(marks[i], prev), next)})
+			continue
+		}
+		start := strings.Index(n.Text, core)
+		lead, trail := n.Text[:start], n.Text[start+len(core):]
+		if lead != "" {
+			pieces = append(pieces, markdownPiece{lead, true, commonMarks(marks[i], prev)})
+		}
+		pieces = append(pieces, markdownAutoLink(core, marks[i], prev, next, lead == "" && trail == ""))
+		if trail != "" {
+			pieces = append(pieces, markdownPiece{trail, true, commonMarks(marks[i], next)})
+		}
+	}
+
+	var b strings.Builder
+	var open []Mark
+	lineStart := true
+	for _, p := range pieces {
+		k := len(commonMarks(open, p.marks))
+		for j := len(open) - 1; j >= k; j-- {
+			b.WriteString(markCloser(open[j]))
+		}
+		for _, m := range p.marks[k:] {
+			b.WriteString(markOpener(m))
+			lineStart = false
+		}
+		open = p.marks
+
+		text := p.text
+		if p.plain {
+			text = escapeMarkdown(text, lineStart)
+		}
+		b.WriteString(text)
+		if text != "" {
+			lineStart = strings.HasSuffix(text, "\n")
+		}
+	}
+	for j := len(open) - 1; j >= 0; j-- {
+		b.WriteString(markCloser(open[j]))
+	}
+	return b.String()
+}
+
+// mergeTextNodes joins adjacent text nodes with identical marks, so that
+// escaping sees the full text.
+func mergeTextNodes(nodes []Node) []Node {
+	var out []Node
+	for _, n := range nodes {
+		if last := len(out) - 1; last >= 0 && n.Type == "text" && out[last].Type == "text" && sameMarks(out[last].Marks, n.Marks) {
+			out[last].Text += n.Text
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// sameMarks reports whether two mark lists contain the same marks.
+func sameMarks(a, b []Mark) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if markKey(a[i]) != markKey(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// markdownAutoLink returns the piece for a text node's non-whitespace core. A
+// link whose text is its own URL is rendered as an autolink.
+func markdownAutoLink(text string, marks, prev, next []Mark, whole bool) markdownPiece {
+	if whole && len(marks) > 0 && marks[0].Type == "link" &&
+		attrString(marks[0].Attrs, "href") == text && attrString(marks[0].Attrs, "title") == "" &&
+		len(commonMarks(marks[:1], prev)) == 0 && len(commonMarks(marks[:1], next)) == 0 {
+		return markdownPiece{"<" + text + ">", false, marks[1:]}
+	}
+	return markdownPiece{text, true, marks}
+}
+
+// markdownInlineNode renders a non-text inline node.
+func markdownInlineNode(n Node, table bool) string {
+	switch n.Type {
+	case "hardBreak":
+		if table {
+			return "<br>"
+		}
+		return "\\\n"
+	case "mention":
+		text := attrString(n.Attrs, "text")
+		if text == "" {
+			text = "@" + attrString(n.Attrs, "id")
+		}
+		return escapeMarkdown(text, false)
+	case "emoji":
+		// Shortcodes parse back to the same emoji
+		if shortName := attrString(n.Attrs, "shortName"); shortName != "" && emojiShortcodeRegexp.FindString(shortName) == shortName {
+			return shortName
+		}
+		if text := attrString(n.Attrs, "text"); text != "" {
+			return text
+		}
+		return escapeMarkdown(attrString(n.Attrs, "shortName"), false)
+	case "status":
+		return markdownStatus(n, table)
+	case "date":
+		ms, err := strconv.ParseInt(attrString(n.Attrs, "timestamp"), 10, 64)
+		if err != nil {
+			return ""
+		}
+		t := time.UnixMilli(ms).UTC()
+		if t.Truncate(24 * time.Hour).Equal(t) {
+			return "{date:" + t.Format("2006-01-02") + "}"
+		}
+		return "{date:" + t.Format(time.RFC3339Nano) + "}"
+	case "inlineCard":
+		if url := attrString(n.Attrs, "url"); url != "" {
+			return "<" + url + ">"
+		}
+		return ""
+	case "mediaInline":
+		return escapeMarkdown(attrString(n.Attrs, "alt"), false)
+	case "inlineExtension", "placeholder":
+		return ""
+	}
+	if n.Text != "" {
+		return escapeMarkdown(n.Text, false)
+	}
+	return markdownInline(n.Content, table)
+}
+
+// footnoteRefAnchorRegexp matches the anchor names of footnote references,
+// capturing the footnote index.
+var footnoteRefAnchorRegexp = regexp.MustCompile(`^fnref-([0-9]+)(?:-[0-9]+)?$`)
+
+// anchorName returns the name of a Confluence anchor macro, or "" if n is not
+// one.
+func anchorName(n Node) string {
+	if n.Type != "inlineExtension" || attrString(n.Attrs, "extensionType") != DefaultMacroExtensionType || attrString(n.Attrs, "extensionKey") != "anchor" {
+		return ""
+	}
+	parameters, _ := n.Attrs["parameters"].(map[string]any)
+	params, _ := parameters["macroParams"].(map[string]any)
+	name, _ := params[""].(map[string]any)
+	return attrString(name, "value")
+}
+
+// markdownFootnoteRef reports whether nodes[i] is the anchor of a footnote
+// reference followed by its superscript link to the footnote, and returns the
+// footnote index.
+func markdownFootnoteRef(nodes []Node, i int) (string, bool) {
+	m := footnoteRefAnchorRegexp.FindStringSubmatch(anchorName(nodes[i]))
+	if m == nil || i+1 == len(nodes) {
+		return "", false
+	}
+	link := nodes[i+1]
+	if link.Type != "text" || link.Text != m[1] || !hasMark(link.Marks, "subsup") {
+		return "", false
+	}
+	for _, mark := range link.Marks {
+		if mark.Type == "link" && attrString(mark.Attrs, "href") == "#fn-"+m[1] {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// isMarkdownFootnoteList reports whether n is the list of footnotes rendered
+// by [Renderer]: an ordered list whose items each start with the anchor of
+// the footnote numbered like the item.
+func isMarkdownFootnoteList(n Node) bool {
+	if n.Type != "orderedList" || attrInt(n.Attrs, "order", 1) != 1 || len(n.Content) == 0 {
+		return false
+	}
+	for i, item := range n.Content {
+		if len(item.Content) == 0 || item.Content[0].Type != "paragraph" || len(item.Content[0].Content) == 0 ||
+			anchorName(item.Content[0].Content[0]) != "fn-"+strconv.Itoa(i+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// markdownFootnotes renders a footnote list as [^N]: definitions, without the
+// anchors and the ↩ links back to the references.
+func markdownFootnotes(list Node) string {
+	defs := make([]string, 0, len(list.Content))
+	for i, item := range list.Content {
+		var blocks []Node
+		for j, block := range item.Content {
+			if block.Type == "paragraph" {
+				var content []Node
+				for k, c := range block.Content {
+					if j == 0 && k == 0 || isFootnoteBacklink(c) {
+						continue
+					}
+					content = append(content, c)
+				}
+				// Drop the spaces between the backlinks
+				for last := len(content) - 1; last >= 0 && content[last].Type == "text" && len(content[last].Marks) == 0; last-- {
+					content[last].Text = strings.TrimRight(content[last].Text, " ")
+					if content[last].Text != "" {
+						break
+					}
+					content = content[:last]
+				}
+				if len(content) == 0 {
+					continue
+				}
+				block.Content = content
+			}
+			blocks = append(blocks, block)
+		}
+		// Blocks after the first line are indented into the definition
+		first, rest, more := strings.Cut(markdownBlocks(blocks, false), "\n")
+		def := "[^" + strconv.Itoa(i+1) + "]: " + first
+		if more {
+			def += "\n" + indentLines(rest, "    ")
+		}
+		defs = append(defs, def)
+	}
+	return strings.Join(defs, "\n\n")
+}
+
+// isFootnoteBacklink reports whether n is a ↩ link back to a footnote
+// reference.
+func isFootnoteBacklink(n Node) bool {
+	if n.Type != "text" || n.Text != "↩" {
+		return false
+	}
+	for _, mark := range n.Marks {
+		if mark.Type == "link" && strings.HasPrefix(attrString(mark.Attrs, "href"), "#fnref-") {
+			return true
+		}
+	}
+	return false
+}
+
+// markdownStatus renders a status node as a {status:Text|color} lozenge, or
+// as inline code if its text cannot be written in the lozenge syntax.
+func markdownStatus(n Node, table bool) string {
+	text, color := attrString(n.Attrs, "text"), attrString(n.Attrs, "color")
+	if text == "" || strings.TrimSpace(text) != text || strings.ContainsAny(text, "}\n") ||
+		table && strings.Contains(text, "|") || !statusColors[color] {
+		return codeSpan(text, table)
+	}
+	sep := DefaultStatusSyntax.Separator
+	if table {
+		sep = "\\" + sep
+	}
+	return DefaultStatusSyntax.Open + text + sep + color + DefaultStatusSyntax.Close
+}
+
+// markdownMarkOrder is the nesting order of marks rendered in Markdown, from
+// outermost to innermost. Other marks have no Markdown equivalent and are
+// dropped.
+var markdownMarkOrder = []string{"link", "strong", "em", "strike", "underline", "subsup"}
+
+// markdownMarks returns the marks that can be expressed in Markdown, in
+// nesting order. The code mark is handled separately as a code span.
+func markdownMarks(marks []Mark) []Mark {
+	var out []Mark
+	for _, typ := range markdownMarkOrder {
+		for _, m := range marks {
+			if m.Type == typ {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// commonMarks returns the leading marks of a that are also present in b.
+func commonMarks(a, b []Mark) []Mark {
+	k := 0
+	for k < len(a) && k < len(b) && markKey(a[k]) == markKey(b[k]) {
+		k++
+	}
+	return a[:k]
+}
+
+// markKey returns a string identifying a mark and its attributes.
+func markKey(m Mark) string {
+	switch m.Type {
+	case "link":
+		return "link\x00" + attrString(m.Attrs, "href") + "\x00" + attrString(m.Attrs, "title")
+	case "subsup":
+		return "subsup\x00" + attrString(m.Attrs, "type")
+	}
+	return m.Type
+}
+
+func hasMark(marks []Mark, typ string) bool {
+	for _, m := range marks {
+		if m.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func markOpener(m Mark) string {
+	switch m.Type {
+	case "link":
+		return "["
+	case "strong":
+		return "**"
+	case "em":
+		return "*"
+	case "strike":
+		return "~~"
+	case "underline":
+		return "<u>"
+	case "subsup":
+		if attrString(m.Attrs, "type") == "sub" {
+			return "<sub>"
+		}
+		return "<sup>"
+	}
+	return ""
+}
+
+func markCloser(m Mark) string {
+	switch m.Type {
+	case "link":
+		return "](" + linkDestination(attrString(m.Attrs, "href"), attrString(m.Attrs, "title")) + ")"
+	case "underline":
+		return "</u>"
+	case "subsup":
+		if attrString(m.Attrs, "type") == "sub" {
+			return "</sub>"
+		}
+		return "</sup>"
+	}
+	return markOpener(m)
+}
+
+// linkDestination renders a link or image destination with an optional title.
+func linkDestination(href, title string) string {
+	dest := href
+	if dest == "" || strings.ContainsAny(dest, " ()<>\t\n") {
+		dest = "<" + strings.NewReplacer("<", "\\<", ">", "\\>").Replace(dest) + ">"
+	}
+	if title != "" {
+		dest += ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(title) + `"`
+	}
+	return dest
+}
+
+// codeSpan renders s as an inline code span, choosing a backtick string that
+// does not occur in s.
+func codeSpan(s string, table bool) string {
+	if table {
+		s = strings.ReplaceAll(s, "|", "\\|")
+	}
+	n := 1
+	for hasRun(s, '`', n) {
+		n++
+	}
+	ticks := strings.Repeat("`", n)
+	pad := ""
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") ||
+		(len(s) > 1 && s[0] == ' ' && s[len(s)-1] == ' ' && strings.TrimSpace(s) != "") {
+		pad = " "
+	}
+	return ticks + pad + s + pad + ticks
+}
+
+// hasRun reports whether s contains a run of exactly n consecutive c bytes.
+func hasRun(s string, c byte, n int) bool {
+	run := 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && s[i] == c {
+			run++
+			continue
+		}
+		if run == n {
+			return true
+		}
+		run = 0
+	}
+	return false
+}
+
+// longestRun returns the length of the longest run of consecutive c bytes in s.
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+var (
+	entityRegexp          = regexp.MustCompile(`^&(?:#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+	orderedListLineRegexp = regexp.MustCompile(`^[0-9]+[.)]`)
+	macroNameRegexp       = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+	macroParamNameRegexp  = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+	macroPlainValueRegexp = regexp.MustCompile(`^[A-Za-z0-9_./@+-]+(?: [A-Za-z0-9_./@+-]+)*$`)
+)
+
+// markdownLayout renders a layoutSection as a :::columns block with a
+// :::column per layoutColumn, reporting whether the section is a layout
+// [NewWithGFM] can parse back.
+func markdownLayout(n Node) (string, bool) {
+	if len(n.Content) < 2 || len(n.Content) > 3 {
+		return "", false
+	}
+	s := ":::columns"
+	for _, col := range n.Content {
+		if col.Type != "layoutColumn" {
+			return "", false
+		}
+		s += "\n:::column"
+		if width := attrString(col.Attrs, "width"); width != "" {
+			s += " width=" + width
+		}
+		// The blank line ends HTML blocks such as </details> before the fence
+		if body := markdownBlocks(col.Content, false); body != "" {
+			s += "\n" + body + "\n"
+		}
+		s += "\n:::"
+	}
+	return s + "\n:::", true
+}
+
+// markdownMacro renders an extension or bodiedExtension of a Confluence
+// macro as a confluence-macro fence named after its extensionKey, reporting
+// whether it is a macro whose parameters can be written as one.
+func markdownMacro(n Node) (string, bool) {
+	key := attrString(n.Attrs, "extensionKey")
+	if attrString(n.Attrs, "extensionType") != DefaultMacroExtensionType || !macroNameRegexp.MatchString(key) {
+		return "", false
+	}
+	params, _ := n.Attrs["parameters"].(map[string]any)
+	for name := range params {
+		if name != "macroParams" && name != "macroMetadata" {
+			return "", false
+		}
+	}
+	macroParams, _ := params["macroParams"].(map[string]any)
+	var lines []string
+	for _, name := range slices.Sorted(maps.Keys(macroParams)) {
+		param, _ := macroParams[name].(map[string]any)
+		value, ok := param["value"].(string)
+		if !ok || !macroParamNameRegexp.MatchString(name) {
+			return "", false
+		}
+		if !macroPlainValueRegexp.MatchString(value) {
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(value) + `"`
+		}
+		lines = append(lines, name+": "+value)
+	}
+
+	info := "confluence-macro " + key
+	attrs := map[string]any{}
+	if layout := attrString(n.Attrs, "layout"); layout != "" && layout != "default" {
+		attrs["layout"] = layout
+	}
+	if localID := attrString(n.Attrs, "localId"); localID != "" {
+		attrs["localId"] = localID
+	}
+	if len(attrs) > 0 {
+		s, ok := markdownContainerAttrs(attrs)
+		if !ok || strings.Contains(s, "`") {
+			return "", false
+		}
+		info += " {" + s + "}"
+	}
+	if n.Type == "bodiedExtension" {
+		lines = append(lines, "---", markdownBlocks(n.Content, false))
+	}
+
+	content := strings.Join(lines, "\n")
+	fence := strings.Repeat("`", max(3, longestRun(content, '`')+1))
+	s := fence + info + "\n"
+	if content != "" {
+		s += content + "\n"
+	}
+	return s + fence, true
+}
+
+// markdownContainerAttrs formats attributes for a :::name {attrs} container
+// fence, reporting whether they can all be written as quoted strings.
+func markdownContainerAttrs(attrs map[string]any) (string, bool) {
+	names := slices.Sorted(maps.Keys(attrs))
+	parts := make([]string, len(names))
+	for i, name := range names {
+		value, ok := attrs[name].(string)
+		if !ok || strings.Contains(value, "\n") {
+			return "", false
+		}
+		parts[i] = name + `="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+	}
+	return strings.Join(parts, " "), true
+}
+
+// escapeMarkdown backslash-escapes characters in s that would otherwise be
+// parsed as Markdown syntax. lineStart reports whether s begins a line, where
+// block markers such as "#" and "-" also need escaping.
+func escapeMarkdown(s string, lineStart bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\', '`', '*', '[', ']', '<', '>', '~', '|':
+			b.WriteByte('\\')
+		case '_':
+			// Intraword underscores never form emphasis
+			if i == 0 || i == len(s)-1 || !isAlnum(s[i-1]) || !isAlnum(s[i+1]) {
+				b.WriteByte('\\')
+			}
+		case '&':
+			if entityRegexp.MatchString(s[i:]) {
+				b.WriteByte('\\')
+			}
+		case '{':
+			// Status lozenges and date macros
+			if strings.HasPrefix(s[i:], DefaultStatusSyntax.Open) || dateMacroRegexp.MatchString(s[i:]) {
+				b.WriteByte('\\')
+			}
+		case ':':
+			// Emoji shortcodes and layout fences
+			if (i == 0 || !isAlnum(s[i-1])) && emojiShortcodeRegexp.MatchString(s[i:]) || i == 0 && lineStart && strings.HasPrefix(s, ":::") {
+				b.WriteByte('\\')
+			}
+		case '#', '-', '+':
+			if i == 0 && lineStart {
+				b.WriteByte('\\')
+			}
+		}
+		if i == 0 && lineStart {
+			if m := orderedListLineRegexp.FindString(s); m != "" {
+				b.WriteString(m[:len(m)-1] + "\\" + m[len(m)-1:])
+				i = len(m) - 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// prefixLines prefixes every line of s, trimming trailing spaces on empty lines.
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = strings.TrimRight(prefix, " ")
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentLines indents every non-empty line of s.
+func indentLines(s, indent string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// plainText concatenates the text of inline nodes, ignoring marks.
+func plainText(nodes []Node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(n.Text)
+		b.WriteString(plainText(n.Content))
+	}
+	return b.String()
+}
+
+// attrString returns a string attribute, or "" if it is missing.
+func attrString(attrs map[string]any, key string) string {
+	switch v := attrs[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	}
+	return ""
+}
+
+// attrInt returns an integer attribute, or def if it is missing.
+func attrInt(attrs map[string]any, key string, def int) int {
+	switch v := attrs[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return def
+}
@@ -0,0 +1,492 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"bytes"
+	"encoding/json/v2"
+	"reflect"
+	"testing"
+
+	"github.com/ajbeck/goldmark-adf/adfschema"
+)
+
+// docWith is a test helper that builds a document from block nodes.
+func docWith(blocks ...*Node) *Document {
+	doc := NewDocument()
+	for _, b := range blocks {
+		doc.Content = append(doc.Content, *b)
+	}
+	return doc
+}
+
+// withChildren is a test helper that appends children to a node.
+func withChildren(n *Node, children ...*Node) *Node {
+	for _, c := range children {
+		n.AppendChild(*c)
+	}
+	return n
+}
+
+func TestDocument_Markdown(t *testing.T) {
+	link := NewLinkMark("https://example.com", "")
+	tests := []struct {
+		name string
+		doc  *Document
+		want string
+	}{
+		{
+			name: "heading",
+			doc:  docWith(withChildren(NewHeading(2), NewText("Title"))),
+			want: "## Title\n",
+		},
+		{
+			name: "marks",
+			doc: docWith(withChildren(NewParagraph(),
+				NewText("Plain "),
+				NewTextWithMarks("bold", []Mark{NewStrongMark()}),
+				NewText(" "),
+				NewTextWithMarks("italic", []Mark{NewEmMark()}),
+				NewText(" "),
+				NewTextWithMarks("gone", []Mark{NewStrikeMark()}),
+				NewText(" "),
+				NewTextWithMarks("code", []Mark{NewCodeMark()}),
+			)),
+			want: "Plain **bold** *italic* ~~gone~~ `code`\n",
+		},
+		{
+			name: "adjacent marks share delimiters",
+			doc: docWith(withChildren(NewParagraph(),
+				NewTextWithMarks("Hello ", []Mark{NewStrongMark()}),
+				NewTextWithMarks("world", []Mark{NewStrongMark(), NewEmMark()}),
+				NewText("!"),
+			)),
+			want: "**Hello *world***!\n",
+		},
+		{
+			name: "whitespace outside delimiters",
+			doc: docWith(withChildren(NewParagraph(),
+				NewText("a"),
+				NewTextWithMarks(" b ", []Mark{NewEmMark()}),
+				NewText("c"),
+			)),
+			want: "a *b* c\n",
+		},
+		{
+			name: "link",
+			doc: docWith(withChildren(NewParagraph(),
+				NewTextWithMarks("click ", []Mark{link}),
+				NewTextWithMarks("here", []Mark{link, NewStrongMark()}),
+			)),
+			want: "[click **here**](https://example.com)\n",
+		},
+		{
+			name: "link with title",
+			doc: docWith(withChildren(NewParagraph(),
+				NewTextWithMarks("site", []Mark{NewLinkMark("https://example.com/a b", `The "site"`)}),
+			)),
+			want: "[site](<https://example.com/a b> \"The \\\"site\\\"\")\n",
+		},
+		{
+			name: "autolink",
+			doc: docWith(withChildren(NewParagraph(),
+				NewTextWithMarks("https://example.com", []Mark{link}),
+			)),
+			want: "<https://example.com>\n",
+		},
+		{
+			name: "code span with backticks",
+			doc: docWith(withChildren(NewParagraph(),
+				NewTextWithMarks("a `b` c", []Mark{NewCodeMark()}),
+			)),
+			want: "``a `b` c``\n",
+		},
+		{
+			name: "hard break",
+			doc: docWith(withChildren(NewParagraph(),
+				NewText("one"), NewHardBreak(), NewText("two"),
+			)),
+			want: "one\\\ntwo\n",
+		},
+		{
+			name: "escaping",
+			doc: docWith(withChildren(NewParagraph(),
+				NewText("# not *a* heading_ snake_case [x] 1. &amp; <b>"),
+			)),
+			want: "\\# not \\*a\\* heading\\_ snake_case \\[x\\] 1. \\&amp; \\<b\\>\n",
+		},
+		{
+			name: "ordered list marker escaping",
+			doc:  docWith(withChildren(NewParagraph(), NewText("1. Not a list"))),
+			want: "1\\. Not a list\n",
+		},
+		{
+			name: "code block",
+			doc:  docWith(withChildren(NewCodeBlock("go"), NewText("fmt.Println(\"```\")\n"))),
+			want: "````go\nfmt.Println(\"```\")\n````\n",
+		},
+		{
+			name: "blockquote",
+			doc: docWith(withChildren(NewBlockquote(),
+				withChildren(NewParagraph(), NewText("one")),
+				withChildren(NewParagraph(), NewText("two")),
+			)),
+			want: "> one\n>\n> two\n",
+		},
+		{
+			name: "nested lists",
+			doc: docWith(withChildren(NewBulletList(),
+				withChildren(NewListItem(),
+					withChildren(NewParagraph(), NewText("parent")),
+					withChildren(NewOrderedList(1),
+						withChildren(NewListItem(), withChildren(NewParagraph(), NewText("child"))),
+					),
+				),
+				withChildren(NewListItem(), withChildren(NewParagraph(), NewText("sibling"))),
+			)),
+			want: "- parent\n  1. child\n- sibling\n",
+		},
+		{
+			name: "ordered list start",
+			doc: docWith(withChildren(NewOrderedList(9),
+				withChildren(NewListItem(), withChildren(NewParagraph(), NewText("nine"))),
+				withChildren(NewListItem(), withChildren(NewParagraph(), NewText("ten"))),
+			)),
+			want: "9. nine\n10. ten\n",
+		},
+		{
+			name: "adjacent lists",
+			doc: docWith(
+				withChildren(NewBulletList(), withChildren(NewListItem(), withChildren(NewParagraph(), NewText("a")))),
+				withChildren(NewBulletList(), withChildren(NewListItem(), withChildren(NewParagraph(), NewText("b")))),
+			),
+			want: "- a\n\n* b\n",
+		},
+		{
+			name: "task list",
+			doc: docWith(withChildren(NewTaskList("1"),
+				withChildren(NewTaskItem("2", "DONE"), NewText("done")),
+				withChildren(NewTaskList("3"),
+					withChildren(NewTaskItem("4", "TODO"), NewText("nested")),
+				),
+				NewTaskItem("5", "TODO"),
+			)),
+			want: "- [x] done\n  - [ ] nested\n- [ ]\n",
+		},
+		{
+			name: "decision list",
+			doc: docWith(withChildren(NewDecisionList("1"),
+				withChildren(NewDecisionItem("2", "DECIDED"), NewText("We will")),
+				withChildren(NewDecisionItem("3", "UNDECIDED"), NewText("Open")),
+			)),
+			want: "- [D] We will\n- [d] Open\n",
+		},
+		{
+			name: "bodied extension",
+			doc: docWith(withChildren(
+				&Node{Type: "bodiedExtension", Attrs: map[string]any{"extensionType": "com.example", "extensionKey": `say "hi"`}},
+				withChildren(NewParagraph(), NewText("Body")),
+			)),
+			want: ":::bodiedExtension {extensionKey=\"say \\\"hi\\\"\" extensionType=\"com.example\"}\nBody\n\n:::\n",
+		},
+		{
+			name: "confluence macro",
+			doc: docWith(&Node{Type: "extension", Attrs: map[string]any{
+				"extensionType": DefaultMacroExtensionType,
+				"extensionKey":  "toc",
+				"layout":        "wide",
+				"parameters": map[string]any{"macroParams": map[string]any{
+					"maxLevel": map[string]any{"value": "3"},
+					"style":    map[string]any{"value": `a "b"`},
+				}},
+			}}),
+			want: "```confluence-macro toc {layout=\"wide\"}\nmaxLevel: 3\nstyle: \"a \\\"b\\\"\"\n```\n",
+		},
+		{
+			name: "layout",
+			doc: docWith(withChildren(NewLayoutSection(),
+				withChildren(NewLayoutColumn(33.33), withChildren(NewParagraph(), NewText("Before"))),
+				withChildren(NewLayoutColumn(66.67), withChildren(NewParagraph(), NewText("After"))),
+			)),
+			want: ":::columns\n:::column width=33.33\nBefore\n\n:::\n:::column width=66.67\nAfter\n\n:::\n:::\n",
+		},
+		{
+			name: "table",
+			doc: docWith(withChildren(NewTable(),
+				withChildren(NewTableRow(),
+					withChildren(NewTableHeader(), withChildren(NewParagraph(), NewText("A"))),
+					withChildren(NewTableHeader(), withChildren(NewParagraph(), NewText("B"))),
+				),
+				withChildren(NewTableRow(),
+					withChildren(NewTableCell(), withChildren(NewParagraph(), NewText("a|b"))),
+					withChildren(NewTableCell(), withChildren(NewParagraph(), NewText("x"), NewHardBreak(), NewText("y"))),
+				),
+			)),
+			want: "| A | B |\n| --- | --- |\n| a\\|b | x<br>y |\n",
+		},
+		{
+			name: "table alignment",
+			doc: docWith(withChildren(NewTable(),
+				withChildren(NewTableRow(),
+					withChildren(NewTableHeader(), withChildren(&Node{Type: "paragraph", Marks: []Mark{NewAlignmentMark("center")}}, NewText("A"))),
+					withChildren(NewTableHeader(), withChildren(&Node{Type: "paragraph", Marks: []Mark{NewAlignmentMark("end")}}, NewText("B"))),
+				),
+			)),
+			want: "| A | B |\n| :---: | ---: |\n",
+		},
+		{
+			name: "rule",
+			doc:  docWith(NewRule()),
+			want: "---\n",
+		},
+		{
+			name: "external media",
+			doc: docWith(withChildren(NewMediaSingle("center"),
+				NewExternalMedia("https://example.com/a.png", "Alt"),
+				withChildren(NewCaption(), NewText("Caption")),
+			)),
+			want: "![Alt](https://example.com/a.png \"Caption\")\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := string(tc.doc.Markdown())
+			if got != tc.want {
+				t.Errorf("Markdown mismatch\ngot:  %q\nwant: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDocument_Markdown_LossyFallbacks(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  *Document
+		want string
+	}{
+		{
+			name: "panel",
+			doc:  docWith(withChildren(NewPanel("warning"), withChildren(NewParagraph(), NewText("Careful")))),
+			want: "> [!WARNING]\n> Careful\n",
+		},
+		{
+			name: "custom panel",
+			doc:  docWith(withChildren(NewPanel("custom"), withChildren(NewParagraph(), NewText("Hi")))),
+			want: "> [!NOTE]\n> Hi\n",
+		},
+		{
+			name: "expand",
+			doc:  docWith(withChildren(NewExpand("Logs & more"), withChildren(NewParagraph(), NewText("body")))),
+			want: "<details>\n<summary>Logs &amp; more</summary>\n\nbody\n\n</details>\n",
+		},
+		{
+			name: "inline nodes",
+			doc: docWith(withChildren(NewParagraph(),
+				&Node{Type: "mention", Attrs: map[string]any{"id": "123", "text": "@Jane Doe"}},
+				NewText(" "),
+				&Node{Type: "emoji", Attrs: map[string]any{"shortName": ":rocket:", "text": "🚀"}},
+				NewText(" "),
+				&Node{Type: "status", Attrs: map[string]any{"text": "IN PROGRESS", "color": "blue"}},
+				NewText(" "),
+				&Node{Type: "date", Attrs: map[string]any{"timestamp": "1793491200000"}},
+				NewText(" "),
+				&Node{Type: "inlineCard", Attrs: map[string]any{"url": "https://example.com"}},
+			)),
+			want: "@Jane Doe :rocket: {status:IN PROGRESS|blue} {date:2026-11-01} <https://example.com>\n",
+		},
+		{
+			name: "status outside the lozenge syntax",
+			doc: docWith(withChildren(NewParagraph(),
+				NewStatus("a}b", "red", "1"),
+				NewText(" "),
+				NewStatus("Done", "orange", "2"),
+			)),
+			want: "`a}b` `Done`\n",
+		},
+		{
+			name: "presentational marks",
+			doc: docWith(withChildren(NewParagraph(),
+				NewTextWithMarks("red", []Mark{NewTextColorMark("#ff0000")}),
+				NewText(" "),
+				NewTextWithMarks("under", []Mark{NewUnderlineMark()}),
+				NewText(" H"),
+				NewTextWithMarks("2", []Mark{NewSubSupMark("sub")}),
+				NewText("O"),
+			)),
+			want: "red <u>under</u> H<sub>2</sub>O\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := string(tc.doc.Markdown())
+			if got != tc.want {
+				t.Errorf("Markdown mismatch\ngot:  %q\nwant: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestToMarkdown(t *testing.T) {
+	output, err := ToMarkdown([]byte(`{
+		"version": 1,
+		"type": "doc",
+		"content": [
+			{"type": "heading", "attrs": {"level": 3}, "content": [{"type": "text", "text": "Title"}]},
+			{"type": "orderedList", "attrs": {"order": 2}, "content": [
+				{"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "two"}]}]}
+			]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ToMarkdown failed: %v", err)
+	}
+
+	want := "### Title\n\n2. two\n"
+	if string(output) != want {
+		t.Errorf("Markdown mismatch\ngot:  %q\nwant: %q", output, want)
+	}
+}
+
+func TestToMarkdown_Footnotes(t *testing.T) {
+	input := "Claim[^1] and again[^1].\n\n[^1]: Source with **bold**.\n\n    More."
+	adf, err := ConvertWithGFM([]byte(input))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	output, err := ToMarkdown(adf)
+	if err != nil {
+		t.Fatalf("ToMarkdown failed: %v", err)
+	}
+
+	want := input + "\n"
+	if string(output) != want {
+		t.Errorf("Markdown mismatch\ngot:  %q\nwant: %q", output, want)
+	}
+}
+
+func TestToMarkdown_InvalidJSON(t *testing.T) {
+	if _, err := ToMarkdown([]byte(`{"type": "doc", "content": [`)); err == nil {
+		t.Error("Expected error for malformed JSON")
+	}
+}
+
+// markdownCorpus holds Markdown that should survive a Markdown → ADF →
+// Markdown → ADF round trip unchanged.
+var markdownCorpus = []string{
+	"Hello world",
+	"# Heading 1\n\n## Heading 2\n\n###### Heading 6",
+	"> This is a quote",
+	"> Quote with **bold**\n>\n> - and a list",
+	"```go\nfunc main() {}\n```",
+	"    indented code",
+	"- Item 1\n- Item 2\n- Item 3",
+	"1. First\n2. Second\n3. Third",
+	"7. Seven\n8. Eight",
+	"- Parent\n  - Child\n    1. Grandchild\n- Sibling",
+	"- Loose item\n\n  Second paragraph\n\n- Next item",
+	"Above\n\n---\n\nBelow",
+	"*italic* and **bold**",
+	"***both*** and **bold *nested* text**",
+	"[click here](https://example.com)",
+	"[titled](https://example.com \"Title\") and <https://example.com/auto>",
+	"[**bold link**](https://example.com)",
+	"Use `fmt.Println` for output",
+	"Backtick ``code ` span`` here",
+	"Escaped \\*stars\\* and \\_underscores\\_ and \\[brackets\\] &amp; entities",
+	"Line one\\\nLine two",
+	"| Header 1 | Header 2 |\n| -------- | -------- |\n| Cell 1   | Cell 2   |",
+	"| `code` | **bold** |\n| --- | --- |\n| a \\| b | ~~gone~~ |",
+	"| Left | Center | Right |\n| :--- | :----: | ----: |\n| a | b | c |",
+	"~~deleted~~",
+	"Hello ~~world~~",
+	"![Alt text](https://example.com/image.png)",
+	"- [x] Done item\n- [ ] Todo item",
+	"- [x] Task one\n- Plain item\n- [ ] Task two",
+	"- [ ] Parent\n  - [x] Child one\n  - [ ] Child two\n- [x] Sibling",
+	"- [D] We will use **Postgres**\n- [d] Hosting\n- [ ] Follow up",
+	"> [!NOTE]\n> Useful information.",
+	"> [!CAUTION]\n> Risky **action**.",
+	"<details>\n<summary>Stack trace</summary>\n\nSome **details** here.\n\n```\npanic: oops\n```\n\n</details>",
+	"<details>\n<summary>Outer</summary>\n\nOuter\n\n<details>\n<summary>Inner</summary>\n\nInner\n\n</details>\n\n</details>",
+	"\\# Not a heading\n\n1\\. Not a list\n\n\\- Not a bullet",
+	"Shipped :rocket: and \\:rocket: at 12:30:45",
+	"{status:In Progress|blue} {status:Done} and \\{status:literal|red}",
+	"| Release | State |\n| --- | --- |\n| 1.0 | {status:Shipped\\|green} |",
+	"Due {date:2026-11-01}, call at {date:2026-11-01T09:30:00+02:00} not \\{date:2026-11-01}",
+	":::panel {panelType=warning}\nCareful\n:::\n\n:::expand {title=More}\n- a\n:::",
+	":::bodiedExtension {extensionType=com.example extensionKey=\"box \\\"x\\\"\"}\n**Boxed**\n:::",
+	":::columns\n:::column width=40\n## Before\n\n- one\n:::\n:::column\n<details>\n<summary>After</summary>\n\nHidden\n\n</details>\n\n:::\n:::\n\n\\:::columns is text",
+	"```confluence-macro toc {layout=wide}\nmaxLevel: 3\nstyle: 'disc, square'\n```\n\n- ```confluence-macro jira\n  columns: [key, summary]\n  ```",
+	"````confluence-macro excerpt\nhidden: true\n---\nThe **short** version.\n\n```go\nfmt.Println()\n```\n````",
+	"Claim[^1] and *another*[^2], again[^1].\n\n[^1]: Source with **bold**.\n\n[^2]: First paragraph.\n\n    ```\n    code\n    ```",
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	configs := []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"external media", []Option{WithExternalMedia(true)}},
+	}
+
+	for _, cfg := range configs {
+		for _, input := range markdownCorpus {
+			first, err := convertWithGFMOptions([]byte(input), cfg.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed for %q: %v", input, err)
+			}
+			if err := adfschema.Validate(first); err != nil {
+				t.Errorf("Invalid ADF for %q: %v", input, err)
+			}
+
+			markdown, err := ToMarkdown(first)
+			if err != nil {
+				t.Fatalf("ToMarkdown failed for %q: %v", input, err)
+			}
+
+			second, err := convertWithGFMOptions(markdown, cfg.opts...)
+			if err != nil {
+				t.Fatalf("Convert failed for %q: %v", markdown, err)
+			}
+
+			if !equalDocuments(t, first, second) {
+				t.Errorf("%s: round trip changed document for %q\nMarkdown: %q\nfirst:  %s\nsecond: %s",
+					cfg.name, input, markdown, first, second)
+			}
+
+			// Markdown output is stable once it has been through a round trip
+			again, err := ToMarkdown(second)
+			if err != nil {
+				t.Fatalf("ToMarkdown failed for %q: %v", input, err)
+			}
+			if !bytes.Equal(markdown, again) {
+				t.Errorf("%s: Markdown not stable for %q\nfirst:  %q\nsecond: %q", cfg.name, input, markdown, again)
+			}
+		}
+	}
+}
+
+// equalDocuments reports whether two ADF documents are equal, ignoring how
+// text is split across adjacent text nodes with the same marks.
+func equalDocuments(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var docA, docB Document
+	if err := json.Unmarshal(a, &docA); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	if err := json.Unmarshal(b, &docB); err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	return reflect.DeepEqual(mergeAllText(docA.Content), mergeAllText(docB.Content))
+}
+
+func mergeAllText(nodes []Node) []Node {
+	nodes = mergeTextNodes(nodes)
+	for i := range nodes {
+		nodes[i].Content = mergeAllText(nodes[i].Content)
+	}
+	return nodes
+}
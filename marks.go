@@ -0,0 +1,134 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MarkConflictPolicy selects how the renderer resolves text marks that ADF
+// does not allow together. ADF only allows the code mark to be combined with
+// link (and annotation) marks, so Markdown such as **`bold code`** or
+// ~~`x`~~ produces a conflict.
+type MarkConflictPolicy int
+
+const (
+	// MarkConflictKeepCode keeps the code mark and drops the marks it cannot
+	// be combined with. This is the default.
+	MarkConflictKeepCode MarkConflictPolicy = iota
+
+	// MarkConflictDropCode drops the code mark and keeps the other marks.
+	MarkConflictDropCode
+
+	// MarkConflictFail stops the conversion with a [*MarkConflictError].
+	MarkConflictFail
+)
+
+// MarkConflictError is returned by a conversion using [MarkConflictFail] when
+// text carries marks that ADF does not allow together.
+type MarkConflictError struct {
+	// Text is the text the marks were applied to.
+	Text string
+
+	// Marks lists the types of the conflicting marks, in ADF order.
+	Marks []string
+}
+
+// Error implements the error interface.
+func (e *MarkConflictError) Error() string {
+	return fmt.Sprintf("adf: marks %s cannot be combined on text %q", strings.Join(e.Marks, ", "), e.Text)
+}
+
+// markOrder is the canonical order of marks on a text node, matching the
+// order in which the ADF schema declares them.
+var markOrder = map[string]int{
+	"link":            0,
+	"em":              1,
+	"strong":          2,
+	"textColor":       3,
+	"backgroundColor": 4,
+	"strike":          5,
+	"subsup":          6,
+	"underline":       7,
+	"code":            8,
+	"annotation":      9,
+}
+
+// codeCompatibleMarks lists the marks that may be combined with code.
+var codeCompatibleMarks = map[string]bool{
+	"code":       true,
+	"link":       true,
+	"annotation": true,
+}
+
+// resolveMarks returns the marks to apply to text so that they form a legal
+// ADF combination: duplicates are removed (the innermost mark wins), code
+// conflicts are resolved according to policy, and the result is sorted into
+// ADF order. Marks unknown to ADF are kept after the known ones. A code
+// conflict is also returned, in which case the marks are nil under
+// MarkConflictFail.
+func resolveMarks(text string, marks []Mark, policy MarkConflictPolicy) ([]Mark, *MarkConflictError) {
+	if len(marks) == 0 {
+		return nil, nil
+	}
+
+	// Keep the innermost (last pushed) mark of each type
+	resolved := make([]Mark, 0, len(marks))
+	for i, m := range marks {
+		if !slices.ContainsFunc(marks[i+1:], func(o Mark) bool { return o.Type == m.Type }) {
+			resolved = append(resolved, m)
+		}
+	}
+
+	var conflict *MarkConflictError
+	if hasMark(resolved, "code") {
+		var conflicts []Mark
+		for _, m := range resolved {
+			if !codeCompatibleMarks[m.Type] {
+				conflicts = append(conflicts, m)
+			}
+		}
+		if len(conflicts) > 0 {
+			types := []string{"code"}
+			for _, m := range conflicts {
+				types = append(types, m.Type)
+			}
+			slices.SortStableFunc(types, func(a, b string) int { return markRank(a) - markRank(b) })
+			conflict = &MarkConflictError{Text: text, Marks: types}
+
+			switch policy {
+			case MarkConflictDropCode:
+				resolved = slices.DeleteFunc(resolved, func(m Mark) bool { return m.Type == "code" })
+			case MarkConflictFail:
+				return nil, conflict
+			default:
+				resolved = slices.DeleteFunc(resolved, func(m Mark) bool { return !codeCompatibleMarks[m.Type] })
+			}
+		}
+	}
+
+	slices.SortStableFunc(resolved, func(a, b Mark) int { return markRank(a.Type) - markRank(b.Type) })
+	return resolved, conflict
+}
+
+// markRank returns the position of a mark type in the canonical ADF order.
+func markRank(typ string) int {
+	if rank, ok := markOrder[typ]; ok {
+		return rank
+	}
+	return len(markOrder)
+}
+
+// withStrongText returns a copy of n with the text it holds made strong. Code
+// is left as it is, since ADF does not allow it to be strong.
+func withStrongText(n Node) Node {
+	n.Content = slices.Clone(n.Content)
+	for i, c := range n.Content {
+		if c.Type == "text" && !hasMark(c.Marks, "code") {
+			n.Content[i].Marks, _ = resolveMarks(c.Text, append(slices.Clone(c.Marks), NewStrongMark()), MarkConflictKeepCode)
+		}
+	}
+	return n
+}
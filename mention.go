@@ -0,0 +1,198 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindUserMention is a NodeKind of the UserMention node.
+var KindUserMention = ast.NewNodeKind("UserMention")
+
+// UserMention is an inline node referencing a user, written either as
+// @[Display Name](accountId:ID) or, when a [MentionResolver] is configured,
+// as @username. Its single child is the text of the mention as written.
+type UserMention struct {
+	ast.BaseInline
+
+	// AccountID is the account ID given in the source. It is empty for
+	// @username mentions, which are resolved when rendering.
+	AccountID string
+
+	// Name is the display name given in the source, or the username.
+	Name string
+}
+
+// NewUserMention creates a new UserMention node for an account ID and
+// display name.
+func NewUserMention(accountID, name string) *UserMention {
+	return &UserMention{AccountID: accountID, Name: name}
+}
+
+// Kind implements ast.Node.Kind.
+func (n *UserMention) Kind() ast.NodeKind {
+	return KindUserMention
+}
+
+// Dump implements ast.Node.Dump.
+func (n *UserMention) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"AccountID": n.AccountID, "Name": n.Name}, nil)
+}
+
+// MentionUser describes a user resolved by a [MentionResolver].
+type MentionUser struct {
+	// AccountID is the Atlassian account ID of the user. Required.
+	AccountID string `json:"accountId"`
+
+	// DisplayName is shown in the mention. Defaults to the username.
+	DisplayName string `json:"displayName,omitempty"`
+
+	// AccessLevel is the mention accessLevel, e.g. "CONTAINER". Optional.
+	AccessLevel string `json:"accessLevel,omitempty"`
+
+	// UserType is the mention userType: "DEFAULT", "SPECIAL" or "APP".
+	// Optional.
+	UserType string `json:"userType,omitempty"`
+}
+
+// MentionResolver resolves the usernames of @username mentions.
+type MentionResolver interface {
+	// ResolveMention returns the user with the given username, or nil if
+	// there is none. A non-nil error stops the conversion.
+	ResolveMention(username string) (*MentionUser, error)
+}
+
+// MentionResolverFunc adapts a function to a [MentionResolver].
+type MentionResolverFunc func(username string) (*MentionUser, error)
+
+// ResolveMention implements MentionResolver.
+func (f MentionResolverFunc) ResolveMention(username string) (*MentionUser, error) {
+	return f(username)
+}
+
+// MentionDirectory is a [MentionResolver] backed by a map from username to
+// user. It can be loaded from a JSON file of the same shape.
+type MentionDirectory map[string]MentionUser
+
+// ResolveMention implements MentionResolver.
+func (d MentionDirectory) ResolveMention(username string) (*MentionUser, error) {
+	if user, ok := d[username]; ok {
+		return &user, nil
+	}
+	return nil, nil
+}
+
+var (
+	mentionAccountRegexp  = regexp.MustCompile(`^@\[([^\]\n]+)\]\(accountId:([A-Za-z0-9:_-]+)\)`)
+	mentionUsernameRegexp = regexp.MustCompile(`^@([A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9_-])?)`)
+)
+
+// mentionParser parses mentions starting with '@'.
+type mentionParser struct {
+	usernames bool
+}
+
+// NewMentionParser returns a parser.InlineParser that parses
+// @[Display Name](accountId:ID) into [UserMention] nodes. If usernames is true,
+// @username is parsed too; such mentions are resolved by the renderer's
+// [MentionResolver]. An '@' preceded by a letter or digit, as in an email
+// address, never starts a mention.
+func NewMentionParser(usernames bool) parser.InlineParser {
+	return &mentionParser{usernames: usernames}
+}
+
+// Trigger implements parser.InlineParser.
+func (p *mentionParser) Trigger() []byte {
+	return []byte{'@'}
+}
+
+// Parse implements parser.InlineParser.
+func (p *mentionParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if before := block.PrecendingCharacter(); unicode.IsLetter(before) || unicode.IsDigit(before) {
+		return nil
+	}
+	line, segment := block.PeekLine()
+
+	var mention *UserMention
+	var length int
+	if m := mentionAccountRegexp.FindSubmatch(line); m != nil {
+		name := strings.TrimSpace(string(m[1]))
+		if name == "" {
+			return nil
+		}
+		mention, length = NewUserMention(string(m[2]), name), len(m[0])
+	} else if m := mentionUsernameRegexp.FindSubmatch(line); m != nil && p.usernames {
+		mention, length = NewUserMention("", string(m[1])), len(m[0])
+	} else {
+		return nil
+	}
+
+	mention.AppendChild(mention, ast.NewTextSegment(segment.WithStop(segment.Start+length)))
+	block.Advance(length)
+	return mention
+}
+
+// addMentionParser adds the mention parser, recognising @username only if
+// a resolver is configured.
+func addMentionParser(md goldmark.Markdown, resolver MentionResolver) {
+	md.Parser().AddOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(NewMentionParser(resolver != nil), 150),
+		),
+	)
+}
+
+func (r *Renderer) renderMention(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	st := r.state(node)
+	n := node.(*UserMention)
+
+	if n.AccountID != "" {
+		st.appendToCurrentOrDocument(*NewMention(n.AccountID, "@"+n.Name))
+		return ast.WalkSkipChildren, nil
+	}
+
+	var user *MentionUser
+	if r.config.MentionResolver != nil {
+		var err error
+		user, err = r.config.MentionResolver.ResolveMention(n.Name)
+		if err != nil {
+			return ast.WalkStop, fmt.Errorf("adf: resolving mention @%s: %w", n.Name, err)
+		}
+	}
+	if user == nil || user.AccountID == "" {
+		// Unknown users are kept as they were written
+		r.report(st, node, SeverityWarning, DiagnosticMentionUnresolved, "mention @%s could not be resolved; rendered as text", n.Name)
+		textNode, err := r.newMarkedText(st, node, "@"+n.Name)
+		if err != nil {
+			return ast.WalkStop, err
+		}
+		st.appendToCurrentOrDocument(*textNode)
+		return ast.WalkSkipChildren, nil
+	}
+
+	name := user.DisplayName
+	if name == "" {
+		name = n.Name
+	}
+	mention := NewMention(user.AccountID, "@"+name)
+	if user.AccessLevel != "" {
+		mention.Attrs["accessLevel"] = user.AccessLevel
+	}
+	if user.UserType != "" {
+		mention.Attrs["userType"] = user.UserType
+	}
+	st.appendToCurrentOrDocument(*mention)
+	return ast.WalkSkipChildren, nil
+}
@@ -3,8 +3,13 @@
 package adf
 
 import (
+	"bytes"
 	"encoding/json/jsontext"
 	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 )
 
 // Document represents the root ADF document node.
@@ -16,6 +21,10 @@ type Document struct {
 	Version int    `json:"version"`
 	Type    string `json:"type"`
 	Content []Node `json:"content"`
+
+	// Extra holds any members not listed above, so that documents decoded
+	// with [ParseDocument] re-encode without losing data.
+	Extra jsontext.Value `json:",embed"`
 }
 
 // NewDocument creates a new empty ADF document.
@@ -43,6 +52,10 @@ type Node struct {
 	Content []Node         `json:"content,omitempty"`
 	Marks   []Mark         `json:"marks,omitempty"`
 	Text    string         `json:"text,omitempty"`
+
+	// Extra holds any members not listed above, so that nodes decoded with
+	// [ParseDocument] re-encode without losing data.
+	Extra jsontext.Value `json:",embed"`
 }
 
 // Mark represents a mark applied to a text node.
@@ -53,6 +66,10 @@ type Node struct {
 type Mark struct {
 	Type  string         `json:"type"`
 	Attrs map[string]any `json:"attrs,omitempty"`
+
+	// Extra holds any members not listed above, so that marks decoded with
+	// [ParseDocument] re-encode without losing data.
+	Extra jsontext.Value `json:",embed"`
 }
 
 // NewParagraph creates a new paragraph node.
@@ -80,6 +97,35 @@ func NewBlockquote() *Node {
 	}
 }
 
+// NewPanel creates a new panel node with the specified panel type.
+// Valid types: "info", "note", "tip", "warning", "error", "success"
+func NewPanel(panelType string) *Node {
+	return &Node{
+		Type:    "panel",
+		Attrs:   map[string]any{"panelType": panelType},
+		Content: []Node{},
+	}
+}
+
+// NewExpand creates a new top-level expand node with the given title.
+func NewExpand(title string) *Node {
+	return &Node{
+		Type:    "expand",
+		Attrs:   map[string]any{"title": title},
+		Content: []Node{},
+	}
+}
+
+// NewNestedExpand creates a new nestedExpand node with the given title.
+// Unlike expand, it may be placed inside an expand or a table cell.
+func NewNestedExpand(title string) *Node {
+	return &Node{
+		Type:    "nestedExpand",
+		Attrs:   map[string]any{"title": title},
+		Content: []Node{},
+	}
+}
+
 // NewCodeBlock creates a new code block node with an optional language.
 func NewCodeBlock(language string) *Node {
 	n := &Node{
@@ -125,6 +171,69 @@ func NewListItem() *Node {
 	}
 }
 
+// NewTaskList creates a new task list node with the given local ID.
+func NewTaskList(localID string) *Node {
+	return &Node{
+		Type:    "taskList",
+		Attrs:   map[string]any{"localId": localID},
+		Content: []Node{},
+	}
+}
+
+// NewTaskItem creates a new task item node with the given local ID and state.
+// state should be "TODO" or "DONE".
+func NewTaskItem(localID, state string) *Node {
+	return &Node{
+		Type: "taskItem",
+		Attrs: map[string]any{
+			"localId": localID,
+			"state":   state,
+		},
+		Content: []Node{},
+	}
+}
+
+// NewDecisionList creates a new decision list node with the given local ID.
+func NewDecisionList(localID string) *Node {
+	return &Node{
+		Type:    "decisionList",
+		Attrs:   map[string]any{"localId": localID},
+		Content: []Node{},
+	}
+}
+
+// NewDecisionItem creates a new decision item node with the given local ID
+// and state. state should be "DECIDED" or "UNDECIDED".
+func NewDecisionItem(localID, state string) *Node {
+	return &Node{
+		Type: "decisionItem",
+		Attrs: map[string]any{
+			"localId": localID,
+			"state":   state,
+		},
+		Content: []Node{},
+	}
+}
+
+// NewLayoutSection creates a new layout section node. Its content should be
+// two or three layoutColumn nodes.
+func NewLayoutSection() *Node {
+	return &Node{
+		Type:    "layoutSection",
+		Content: []Node{},
+	}
+}
+
+// NewLayoutColumn creates a new layout column node with the given width, a
+// percentage of the section width.
+func NewLayoutColumn(width float64) *Node {
+	return &Node{
+		Type:    "layoutColumn",
+		Attrs:   map[string]any{"width": width},
+		Content: []Node{},
+	}
+}
+
 // NewTable creates a new table node.
 func NewTable() *Node {
 	return &Node{
@@ -185,6 +294,88 @@ func NewHardBreak() *Node {
 	return &Node{Type: "hardBreak"}
 }
 
+// NewMention creates a new mention node for the given account ID. The text is
+// shown in the mention, conventionally "@" followed by the display name.
+func NewMention(id, text string) *Node {
+	attrs := map[string]any{"id": id}
+	if text != "" {
+		attrs["text"] = text
+	}
+	return &Node{
+		Type:  "mention",
+		Attrs: attrs,
+	}
+}
+
+// NewEmoji creates a new emoji node. shortName includes the colons, e.g.
+// ":rocket:"; text is the Unicode fallback and may be empty.
+func NewEmoji(shortName, id, text string) *Node {
+	attrs := map[string]any{"shortName": shortName}
+	if id != "" {
+		attrs["id"] = id
+	}
+	if text != "" {
+		attrs["text"] = text
+	}
+	return &Node{
+		Type:  "emoji",
+		Attrs: attrs,
+	}
+}
+
+// NewStatus creates a new status lozenge node. Valid colors: "neutral",
+// "purple", "blue", "red", "yellow", "green".
+func NewStatus(text, color, localID string) *Node {
+	attrs := map[string]any{
+		"text":  text,
+		"color": color,
+	}
+	if localID != "" {
+		attrs["localId"] = localID
+	}
+	return &Node{
+		Type:  "status",
+		Attrs: attrs,
+	}
+}
+
+// NewDate creates a new date node for the given time, stored as a timestamp in
+// milliseconds since the Unix epoch.
+func NewDate(t time.Time) *Node {
+	return &Node{
+		Type:  "date",
+		Attrs: map[string]any{"timestamp": strconv.FormatInt(t.UnixMilli(), 10)},
+	}
+}
+
+// NewInlineCard creates a new inline smart link node for the given URL.
+func NewInlineCard(url string) *Node {
+	return &Node{
+		Type:  "inlineCard",
+		Attrs: map[string]any{"url": url},
+	}
+}
+
+// NewBlockCard creates a new block smart link node for the given URL.
+func NewBlockCard(url string) *Node {
+	return &Node{
+		Type:  "blockCard",
+		Attrs: map[string]any{"url": url},
+	}
+}
+
+// NewEmbedCard creates a new embedded smart link node for the given URL.
+// Valid layouts: "center", "wide", "full-width", "wrap-left", "wrap-right", "align-start", "align-end"
+func NewEmbedCard(url, layout string) *Node {
+	return &Node{
+		Type: "embedCard",
+		Attrs: map[string]any{
+			"url":    url,
+			"layout": layout,
+		},
+	}
+}
+
 // NewMediaSingle creates a new mediaSingle container node with the specified layout.
 // Valid layouts: "center", "wide", "full-width", "wrap-left", "wrap-right", "align-start", "align-end"
 func NewMediaSingle(layout string) *Node {
@@ -274,6 +465,33 @@ func NewTextColorMark(color string) Mark {
 	}
 }
 
+// NewBackgroundColorMark creates a background (highlight) color mark with a
+// hex color code.
+func NewBackgroundColorMark(color string) Mark {
+	return Mark{
+		Type:  "backgroundColor",
+		Attrs: map[string]any{"color": color},
+	}
+}
+
+// NewAlignmentMark creates an alignment mark for a paragraph or heading.
+// Valid values: "center", "end".
+func NewAlignmentMark(align string) Mark {
+	return Mark{
+		Type:  "alignment",
+		Attrs: map[string]any{"align": align},
+	}
+}
+
+// NewIndentationMark creates an indentation mark for a paragraph or heading.
+// Valid levels: 1 to 6.
+func NewIndentationMark(level int) Mark {
+	return Mark{
+		Type:  "indentation",
+		Attrs: map[string]any{"level": level},
+	}
+}
+
 // AppendChild appends a child node to this node's content.
 func (n *Node) AppendChild(child Node) {
 	n.Content = append(n.Content, child)
@@ -284,12 +502,111 @@ func (n *Node) AppendMark(mark Mark) {
 	n.Marks = append(n.Marks, mark)
 }
 
-// Marshal serializes the document to JSON.
+// Marshal serializes the document to JSON. Attribute keys are sorted so the
+// output is deterministic.
 func (d *Document) Marshal() ([]byte, error) {
-	return json.Marshal(d)
+	return json.Marshal(d, json.Deterministic(true))
 }
 
-// MarshalIndent serializes the document to indented JSON.
+// MarshalIndent serializes the document to indented JSON. Attribute keys are
+// sorted so the output is deterministic.
 func (d *Document) MarshalIndent(indent string) ([]byte, error) {
-	return json.Marshal(d, jsontext.WithIndent(indent))
+	return json.Marshal(d, jsontext.WithIndent(indent), json.Deterministic(true))
+}
+
+// ParseError describes malformed ADF input passed to [ParseDocument].
+type ParseError struct {
+	// Line and Column are the 1-based position of the error in the input.
+	// Column counts bytes.
+	Line, Column int
+
+	// Offset is the byte offset of the error in the input.
+	Offset int64
+
+	// Path is the JSON Pointer (RFC 6901) of the value containing the error,
+	// e.g. "/content/0/attrs/level".
+	Path string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("adf: line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("adf: line %d, column %d (at %s): %v", e.Line, e.Column, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseDocument decodes ADF JSON into a Document.
+//
+// Any ADF is accepted: node and mark types not produced by this package,
+// unknown attributes and unknown members are kept, so that marshaling the
+// result yields an equivalent document. Integer attribute values are decoded
+// as int (e.g. a heading level is 2, not 2.0) and other numbers as float64.
+//
+// Malformed input is reported as a [*ParseError] giving the position of the
+// problem.
+func ParseDocument(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc, json.WithUnmarshalers(numberUnmarshaler)); err != nil {
+		return nil, newParseError(data, err)
+	}
+	if doc.Type != "doc" {
+		return nil, &ParseError{
+			Line:   1,
+			Column: 1,
+			Path:   "/type",
+			Err:    fmt.Errorf("document type is %q, expected \"doc\"", doc.Type),
+		}
+	}
+	return &doc, nil
+}
+
+// numberUnmarshaler decodes JSON numbers held in untyped values (attribute
+// maps) as int where they are integers and as float64 otherwise.
+var numberUnmarshaler = json.UnmarshalFromFunc(func(dec *jsontext.Decoder, v *any) error {
+	if dec.PeekKind() != '0' {
+		return errors.ErrUnsupported
+	}
+	val, err := dec.ReadValue()
+	if err != nil {
+		return err
+	}
+	if i, err := strconv.ParseInt(string(val), 10, 0); err == nil {
+		*v = int(i)
+		return nil
+	}
+	f, err := strconv.ParseFloat(string(val), 64)
+	if err != nil {
+		return err
+	}
+	*v = f
+	return nil
+})
+
+// newParseError converts a JSON decoding error into a ParseError.
+func newParseError(data []byte, err error) *ParseError {
+	pe := &ParseError{Err: err}
+	var syntaxErr *jsontext.SyntacticError
+	var semanticErr *json.SemanticError
+	switch {
+	case errors.As(err, &syntaxErr):
+		pe.Offset = syntaxErr.ByteOffset
+		pe.Path = string(syntaxErr.JSONPointer)
+	case errors.As(err, &semanticErr):
+		pe.Offset = semanticErr.ByteOffset
+		pe.Path = string(semanticErr.JSONPointer)
+	}
+	pe.Offset = min(pe.Offset, int64(len(data)))
+	before := data[:pe.Offset]
+	pe.Line = bytes.Count(before, []byte("\n")) + 1
+	pe.Column = len(before) - bytes.LastIndexByte(before, '\n')
+	return pe
 }
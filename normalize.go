@@ -0,0 +1,52 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Normalize coalesces adjacent text nodes carrying the same marks and removes
+// empty text nodes throughout the document. The renderer emits a text node
+// per Markdown text segment, so normalizing merges e.g. the lines of a
+// soft-wrapped paragraph into one node and considerably shrinks the output.
+// Documents produced by [New] and [NewWithGFM] are already normalized.
+func (d *Document) Normalize() {
+	d.Content = normalizeNodes(d.Content)
+}
+
+// normalizeNodes normalizes a content array and the content of its nodes.
+func normalizeNodes(nodes []Node) []Node {
+	if nodes == nil {
+		return nil
+	}
+	out := nodes[:0]
+	for _, n := range nodes {
+		if n.Type == "text" && n.Text == "" && len(n.Extra) == 0 {
+			continue
+		}
+		n.Content = normalizeNodes(n.Content)
+		if last := len(out) - 1; last >= 0 && mergeableText(out[last], n) {
+			out[last].Text += n.Text
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// mergeableText reports whether b can be appended to text node a. Nodes with
+// unknown members are never merged so that they re-encode unchanged.
+func mergeableText(a, b Node) bool {
+	return a.Type == "text" && b.Type == "text" &&
+		len(a.Attrs) == 0 && len(b.Attrs) == 0 &&
+		len(a.Extra) == 0 && len(b.Extra) == 0 &&
+		slices.EqualFunc(a.Marks, b.Marks, equalMarks)
+}
+
+// equalMarks reports whether two marks have the same type and attributes.
+func equalMarks(a, b Mark) bool {
+	return a.Type == b.Type && len(a.Extra) == 0 && len(b.Extra) == 0 &&
+		(len(a.Attrs) == 0 && len(b.Attrs) == 0 || reflect.DeepEqual(a.Attrs, b.Attrs))
+}
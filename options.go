@@ -3,19 +3,36 @@
 package adf
 
 import (
+	"strings"
+	"time"
+
 	"github.com/yuin/goldmark/renderer"
 )
 
 // Config holds configuration options for the ADF renderer.
 type Config struct {
-	// ImageHandler specifies how to handle image nodes.
-	// By default, images are converted to links.
+	// ImageHandler is called for every image. If it returns nil, or is not
+	// set, images are converted to links (or external media, see
+	// ExternalMedia).
 	ImageHandler ImageHandler
 
 	// TableLayout specifies the default table layout.
 	// Valid values: "default", "center", "wide", "full-width"
 	TableLayout string
 
+	// TableNumberColumn enables the numbered first column of tables
+	// (isNumberColumnEnabled).
+	TableNumberColumn bool
+
+	// TableColumnWidths holds the width in pixels of each table column, set
+	// as the colwidth of its cells. Columns without a positive width are left
+	// to the editor.
+	TableColumnWidths []int
+
+	// TableDisplayMode specifies the table displayMode.
+	// Valid values: "default", "fixed". Empty leaves it unset.
+	TableDisplayMode string
+
 	// ExternalMedia enables external media image handling.
 	// When true, images are rendered as mediaSingle nodes with external media.
 	// When false (default), images are converted to text with link marks.
@@ -25,16 +42,113 @@ type Config struct {
 	// Valid values: "center", "wide", "full-width", "wrap-left", "wrap-right", "align-start", "align-end"
 	// Defaults to "center" if not specified.
 	ImageLayout string
+
+	// AlertPanels maps GitHub alert kinds (e.g. "NOTE", "WARNING") to ADF
+	// panel types. Alerts whose kind has no panel type are left as blockquotes.
+	AlertPanels map[string]string
+
+	// HardWraps renders soft line breaks as hardBreak nodes instead of
+	// spaces.
+	HardWraps bool
+
+	// HTMLPolicy selects how raw HTML is rendered. Defaults to HTMLDrop.
+	HTMLPolicy HTMLPolicy
+
+	// DiagnosticHandler, if set, is called for every diagnostic reported
+	// during a conversion.
+	DiagnosticHandler func(Diagnostic)
+
+	// MarkConflicts selects how marks that ADF does not allow together (such
+	// as code and strong) are resolved. Defaults to MarkConflictKeepCode.
+	MarkConflicts MarkConflictPolicy
+
+	// MentionResolver, if set, resolves @username mentions to users. Without
+	// a resolver only @[Display Name](accountId:ID) mentions are recognised.
+	MentionResolver MentionResolver
+
+	// CustomEmoji maps short names, without colons, to emoji recognised in
+	// :shortname: shortcodes in addition to the standard ones.
+	CustomEmoji map[string]Emoji
+
+	// StatusSyntax is the syntax of status lozenges. Defaults to
+	// DefaultStatusSyntax; an empty Open or Close disables them.
+	StatusSyntax StatusSyntax
+
+	// DateLocation is the time zone of {date:...} macros that do not give
+	// one. Defaults to UTC.
+	DateLocation *time.Location
+
+	// SmartLinks lists the rules rendering autolinks as smart links (cards).
+	// The first rule matching a URL's host applies.
+	SmartLinks []SmartLinkRule
+
+	// Containers maps :::name container names to the handlers rendering
+	// them. Defaults to panel, expand, layoutSection (alias columns),
+	// layoutColumn (alias column) and bodiedExtension.
+	Containers map[string]ContainerHandler
+
+	// Macros maps the names of Confluence macros written as confluence-macro
+	// fences to the extension nodes they render as. Defaults to toc,
+	// children, jira, excerpt and anchor.
+	Macros map[string]Macro
+
+	// UnknownMacros selects how confluence-macro fences naming a macro not in
+	// Macros are rendered. Defaults to UnknownMacroCodeBlock.
+	UnknownMacros UnknownMacroPolicy
+
+	// InlineFootnotes renders the text of footnotes in parentheses in place
+	// of their references, instead of as superscript numbers linked to a
+	// list of footnotes at the end of the document.
+	InlineFootnotes bool
+
+	// DefinitionLists selects how definition lists are rendered. Defaults
+	// to DefinitionListBlockquote.
+	DefinitionLists DefinitionListStyle
 }
 
 // ImageHandler is a function that handles image rendering.
-type ImageHandler func(dest, alt, title string) *Node
+//
+// It may return an inline node (such as a text node with a link mark, or
+// mediaInline), which is added in place of the image, or a block node (such as
+// mediaSingle), which splits the surrounding paragraph so that the block sits
+// between the text before and after the image. Returning nil falls back to the
+// default rendering.
+type ImageHandler func(img ImageContext) *Node
+
+// ImageContext describes an image passed to an [ImageHandler].
+type ImageContext struct {
+	// Destination is the image URL.
+	Destination string
+
+	// Alt is the alt text, or "" if there is none.
+	Alt string
+
+	// Title is the image title, or "" if there is none.
+	Title string
+
+	// Block reports whether the image is the only content of its paragraph.
+	// Otherwise the image is inline with surrounding text.
+	Block bool
+
+	// ParentType is the ADF type of the node containing the image's
+	// paragraph or heading, e.g. "doc", "listItem", "blockquote" or
+	// "tableCell".
+	ParentType string
+}
 
 // NewConfig creates a new Config with default values.
 func NewConfig() Config {
+	panels := make(map[string]string, len(defaultAlertPanels))
+	for kind, panelType := range defaultAlertPanels {
+		panels[kind] = panelType
+	}
 	return Config{
-		TableLayout: "default",
-		ImageLayout: "center",
+		TableLayout:  "default",
+		ImageLayout:  "center",
+		AlertPanels:  panels,
+		StatusSyntax: DefaultStatusSyntax,
+		Containers:   defaultContainers(),
+		Macros:       defaultMacros(),
 	}
 }
 
@@ -56,7 +170,7 @@ func (o *withImageHandler) SetConfig(c *renderer.Config) {
 	// No-op for renderer.Config
 }
 
-// WithImageHandler sets a custom image handler.
+// WithImageHandler sets a custom image handler, called for every image.
 func WithImageHandler(handler ImageHandler) Option {
 	return &withImageHandler{handler: handler}
 }
@@ -80,6 +194,64 @@ func WithTableLayout(layout string) Option {
 	return &withTableLayout{layout: layout}
 }
 
+// withTableNumberColumn implements Option.
+type withTableNumberColumn struct {
+	enabled bool
+}
+
+func (o *withTableNumberColumn) SetADFOption(c *Config) {
+	c.TableNumberColumn = o.enabled
+}
+
+func (o *withTableNumberColumn) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithTableNumberColumn enables or disables the numbered first column of
+// tables (isNumberColumnEnabled). Disabled by default.
+func WithTableNumberColumn(enabled bool) Option {
+	return &withTableNumberColumn{enabled: enabled}
+}
+
+// withTableColumnWidths implements Option.
+type withTableColumnWidths struct {
+	widths []int
+}
+
+func (o *withTableColumnWidths) SetADFOption(c *Config) {
+	c.TableColumnWidths = o.widths
+}
+
+func (o *withTableColumnWidths) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithTableColumnWidths sets the width in pixels of each table column, in
+// order. The width is applied to every cell in the column as its colwidth.
+// Columns beyond the given widths, or with a width of 0, are left unset.
+func WithTableColumnWidths(widths ...int) Option {
+	return &withTableColumnWidths{widths: widths}
+}
+
+// withTableDisplayMode implements Option.
+type withTableDisplayMode struct {
+	mode string
+}
+
+func (o *withTableDisplayMode) SetADFOption(c *Config) {
+	c.TableDisplayMode = o.mode
+}
+
+func (o *withTableDisplayMode) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithTableDisplayMode sets the table displayMode.
+// Valid values: "default", "fixed" (column widths are not scaled to fit)
+func WithTableDisplayMode(mode string) Option {
+	return &withTableDisplayMode{mode: mode}
+}
+
 // withExternalMedia implements Option.
 type withExternalMedia struct {
 	enabled bool
@@ -119,3 +291,337 @@ func (o *withImageLayout) SetConfig(c *renderer.Config) {
 func WithImageLayout(layout string) Option {
 	return &withImageLayout{layout: layout}
 }
+
+// withAlertPanels implements Option.
+type withAlertPanels struct {
+	panels map[string]string
+}
+
+func (o *withAlertPanels) SetADFOption(c *Config) {
+	for kind, panelType := range o.panels {
+		c.AlertPanels[strings.ToUpper(kind)] = panelType
+	}
+}
+
+func (o *withAlertPanels) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithAlertPanels customises how GitHub alerts (> [!NOTE]) map to ADF panels.
+// Entries are merged into the defaults (NOTE: "info", TIP: "success",
+// IMPORTANT: "note", WARNING: "warning", CAUTION: "error"). Valid panel types:
+// "info", "note", "tip", "warning", "error", "success". Map a kind to "" to
+// leave it as a plain blockquote. Alerts are parsed by [NewWithGFM].
+func WithAlertPanels(panels map[string]string) Option {
+	return &withAlertPanels{panels: panels}
+}
+
+// withMarkConflicts implements Option.
+type withMarkConflicts struct {
+	policy MarkConflictPolicy
+}
+
+func (o *withMarkConflicts) SetADFOption(c *Config) {
+	c.MarkConflicts = o.policy
+}
+
+func (o *withMarkConflicts) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithMarkConflicts sets how text marks that ADF does not allow together are
+// resolved. ADF only allows code to be combined with link, so **`x`** either
+// keeps code ([MarkConflictKeepCode], the default), keeps strong
+// ([MarkConflictDropCode]) or fails the conversion ([MarkConflictFail]).
+func WithMarkConflicts(policy MarkConflictPolicy) Option {
+	return &withMarkConflicts{policy: policy}
+}
+
+// withHardWraps implements Option.
+type withHardWraps struct {
+	enabled bool
+}
+
+func (o *withHardWraps) SetADFOption(c *Config) {
+	c.HardWraps = o.enabled
+}
+
+func (o *withHardWraps) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithHardWraps renders soft line breaks (a newline within a paragraph) as
+// hardBreak nodes, keeping the line structure of the source. By default they
+// become spaces, as in HTML.
+func WithHardWraps(enabled bool) Option {
+	return &withHardWraps{enabled: enabled}
+}
+
+// withHTMLPolicy implements Option.
+type withHTMLPolicy struct {
+	policy HTMLPolicy
+}
+
+func (o *withHTMLPolicy) SetADFOption(c *Config) {
+	c.HTMLPolicy = o.policy
+}
+
+func (o *withHTMLPolicy) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithHTMLPolicy sets how raw HTML blocks and inline HTML are rendered:
+// dropped ([HTMLDrop], the default), kept as literal text ([HTMLText]), shown
+// as code ([HTMLCodeBlock]) or translated to ADF where possible
+// ([HTMLTranslate]). <details> sections are always rendered as expands.
+func WithHTMLPolicy(policy HTMLPolicy) Option {
+	return &withHTMLPolicy{policy: policy}
+}
+
+// withDiagnosticHandler implements Option.
+type withDiagnosticHandler struct {
+	handler func(Diagnostic)
+}
+
+func (o *withDiagnosticHandler) SetADFOption(c *Config) {
+	c.DiagnosticHandler = o.handler
+}
+
+func (o *withDiagnosticHandler) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithDiagnosticHandler sets a function called for every [Diagnostic]
+// reported during a conversion, such as dropped HTML or an image rendered as a
+// link. The handler is called from the goroutine running the conversion, so
+// an instance shared between goroutines needs a handler that is safe for
+// concurrent use. See also [ConvertWithReport].
+func WithDiagnosticHandler(handler func(Diagnostic)) Option {
+	return &withDiagnosticHandler{handler: handler}
+}
+
+// withMentionResolver implements Option.
+type withMentionResolver struct {
+	resolver MentionResolver
+}
+
+func (o *withMentionResolver) SetADFOption(c *Config) {
+	c.MentionResolver = o.resolver
+}
+
+func (o *withMentionResolver) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithMentionResolver enables @username mentions, resolved to users by
+// resolver. Usernames the resolver does not know are kept as text. Mentions
+// written as @[Display Name](accountId:ID) need no resolver.
+func WithMentionResolver(resolver MentionResolver) Option {
+	return &withMentionResolver{resolver: resolver}
+}
+
+// withCustomEmoji implements Option.
+type withCustomEmoji struct {
+	emoji map[string]Emoji
+}
+
+func (o *withCustomEmoji) SetADFOption(c *Config) {
+	if c.CustomEmoji == nil {
+		c.CustomEmoji = map[string]Emoji{}
+	}
+	for name, emoji := range o.emoji {
+		c.CustomEmoji[strings.Trim(name, ":")] = emoji
+	}
+}
+
+func (o *withCustomEmoji) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithCustomEmoji registers emoji for :shortname: shortcodes, such as
+// Atlassian custom emoji, keyed by short name. Entries are merged into
+// earlier ones and take precedence over standard emoji of the same name.
+func WithCustomEmoji(emoji map[string]Emoji) Option {
+	return &withCustomEmoji{emoji: emoji}
+}
+
+// withStatusSyntax implements Option.
+type withStatusSyntax struct {
+	syntax StatusSyntax
+}
+
+func (o *withStatusSyntax) SetADFOption(c *Config) {
+	c.StatusSyntax = o.syntax
+}
+
+func (o *withStatusSyntax) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithStatusSyntax sets the delimiters of status lozenges, by default
+// {status:Text|color} ([DefaultStatusSyntax]). For example
+// StatusSyntax{Open: "[[", Separator: ":", Close: "]]"} parses
+// [[In Progress:blue]]. A StatusSyntax with an empty Open or Close disables
+// status lozenges.
+func WithStatusSyntax(syntax StatusSyntax) Option {
+	return &withStatusSyntax{syntax: syntax}
+}
+
+// withDateLocation implements Option.
+type withDateLocation struct {
+	loc *time.Location
+}
+
+func (o *withDateLocation) SetADFOption(c *Config) {
+	c.DateLocation = o.loc
+}
+
+func (o *withDateLocation) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithDateLocation sets the time zone of {date:...} macros that do not give
+// one, such as {date:2026-11-01}. The default is UTC.
+func WithDateLocation(loc *time.Location) Option {
+	return &withDateLocation{loc: loc}
+}
+
+// withSmartLinks implements Option.
+type withSmartLinks struct {
+	rules []SmartLinkRule
+}
+
+func (o *withSmartLinks) SetADFOption(c *Config) {
+	c.SmartLinks = append(c.SmartLinks, o.rules...)
+}
+
+func (o *withSmartLinks) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithSmartLinks renders autolinks to the hosts matched by rules as smart
+// links: inlineCard within text, and blockCard or embedCard when alone in a
+// paragraph. Rules are tried in order, after those of earlier WithSmartLinks
+// options. Links written as [text](url) keep their link mark.
+func WithSmartLinks(rules ...SmartLinkRule) Option {
+	return &withSmartLinks{rules: rules}
+}
+
+// withContainers implements Option.
+type withContainers struct {
+	handlers map[string]ContainerHandler
+}
+
+func (o *withContainers) SetADFOption(c *Config) {
+	for name, handler := range o.handlers {
+		if handler == nil {
+			delete(c.Containers, name)
+		} else {
+			c.Containers[name] = handler
+		}
+	}
+}
+
+func (o *withContainers) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithContainers registers handlers for :::name {attrs} containers, keyed by
+// name. Entries are merged into the defaults (see [Config.Containers]); map a
+// name to nil to remove it. The content the ADF schema allows in panels,
+// expands, layouts and extensions is checked whatever the handler.
+func WithContainers(handlers map[string]ContainerHandler) Option {
+	return &withContainers{handlers: handlers}
+}
+
+// withMacros implements Option.
+type withMacros struct {
+	macros map[string]Macro
+}
+
+func (o *withMacros) SetADFOption(c *Config) {
+	for name, macro := range o.macros {
+		c.Macros[name] = macro
+	}
+}
+
+func (o *withMacros) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithMacros registers Confluence macros written as
+//
+//	```confluence-macro name {layout=wide}
+//	param: value
+//	```
+//
+// fences, keyed by name. Entries are merged into the defaults (see
+// [Config.Macros]).
+func WithMacros(macros map[string]Macro) Option {
+	return &withMacros{macros: macros}
+}
+
+// withUnknownMacros implements Option.
+type withUnknownMacros struct {
+	policy UnknownMacroPolicy
+}
+
+func (o *withUnknownMacros) SetADFOption(c *Config) {
+	c.UnknownMacros = o.policy
+}
+
+func (o *withUnknownMacros) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithUnknownMacros sets how confluence-macro fences naming an unregistered
+// macro are rendered: as a code block (UnknownMacroCodeBlock, the default) or
+// as an error (UnknownMacroError).
+func WithUnknownMacros(policy UnknownMacroPolicy) Option {
+	return &withUnknownMacros{policy: policy}
+}
+
+// withInlineFootnotes implements Option.
+type withInlineFootnotes struct {
+	enabled bool
+}
+
+func (o *withInlineFootnotes) SetADFOption(c *Config) {
+	c.InlineFootnotes = o.enabled
+}
+
+func (o *withInlineFootnotes) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithInlineFootnotes renders footnotes ([^label] references, with
+// [NewWithGFM]) as their text in parentheses in place of each reference.
+// Disabled by default: references are superscript numbers linking to a
+// numbered list of the footnotes after a rule at the end of the document.
+func WithInlineFootnotes(enabled bool) Option {
+	return &withInlineFootnotes{enabled: enabled}
+}
+
+// withDefinitionLists implements Option.
+type withDefinitionLists struct {
+	style DefinitionListStyle
+}
+
+func (o *withDefinitionLists) SetADFOption(c *Config) {
+	c.DefinitionLists = o.style
+}
+
+func (o *withDefinitionLists) SetConfig(c *renderer.Config) {
+	// No-op for renderer.Config
+}
+
+// WithDefinitionLists sets how definition lists (with [NewWithGFM]) are
+// rendered: bold terms followed by their descriptions in blockquotes
+// (DefinitionListBlockquote, the default) or as indented paragraphs
+// (DefinitionListIndented), a two-column table (DefinitionListTable), or a
+// bullet list with an item per term (DefinitionListBulletList). Lists placed
+// where ADF does not allow the chosen style are rendered as bold terms
+// followed by their descriptions as plain paragraphs.
+func WithDefinitionLists(style DefinitionListStyle) Option {
+	return &withDefinitionLists{style: style}
+}
@@ -3,8 +3,13 @@
 package adf
 
 import (
+	"bytes"
 	"encoding/json/jsontext"
 	"encoding/json/v2"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
 
 	"github.com/yuin/goldmark/ast"
 	extast "github.com/yuin/goldmark/extension/ast"
@@ -15,30 +20,39 @@ import (
 // Renderer is a goldmark [renderer.NodeRenderer] that outputs Atlassian Document
 // Format (ADF) JSON.
 //
-// The Renderer maintains internal state during the AST walk, using a node stack
+// The Renderer builds the ADF document during the AST walk, using a node stack
 // to track the current position in the ADF document tree and a mark stack to
-// accumulate active text marks (bold, italic, links, etc.). This state is reset
-// for each new document.
+// accumulate active text marks (bold, italic, links, etc.). This state is kept
+// per conversion on the document being rendered, so a single Renderer (and the
+// goldmark instance returned by [New] or [NewWithGFM]) can be shared between
+// goroutines.
 //
 // Use [NewRenderer] to create a Renderer, or use the higher-level [New] and
 // [NewWithGFM] functions which configure a complete goldmark instance.
 type Renderer struct {
 	config Config
 
-	// State for rendering
-	document  *Document
-	nodeStack []*Node
-	markStack []Mark
+	// funcs holds the renderer's own functions, for rendering nodes out of
+	// document order.
+	funcs nodeRendererFuncs
 }
 
 // NewRenderer creates a new ADF renderer with the given options.
 func NewRenderer(opts ...Option) renderer.NodeRenderer {
+	return newRenderer(opts...)
+}
+
+// newRenderer is like NewRenderer but returns the concrete type so callers can
+// inspect the resolved configuration.
+func newRenderer(opts ...Option) *Renderer {
 	r := &Renderer{
 		config: NewConfig(),
 	}
 	for _, opt := range opts {
 		opt.SetADFOption(&r.config)
 	}
+	r.funcs = nodeRendererFuncs{}
+	r.RegisterFuncs(r.funcs)
 	return r
 }
 
@@ -74,80 +88,134 @@ func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
 	reg.Register(extast.KindTableCell, r.renderTableCell)
 	reg.Register(extast.KindStrikethrough, r.renderStrikethrough)
 	reg.Register(extast.KindTaskCheckBox, r.renderTaskCheckBox)
+	reg.Register(extast.KindFootnoteLink, r.renderFootnoteLink)
+	reg.Register(extast.KindFootnoteBacklink, r.renderFootnoteBacklink)
+	reg.Register(extast.KindFootnote, r.renderFootnote)
+	reg.Register(extast.KindFootnoteList, r.renderFootnoteList)
+	reg.Register(extast.KindDefinitionList, r.renderDefinitionList)
+	reg.Register(extast.KindDefinitionTerm, r.renderDefinitionTerm)
+	reg.Register(extast.KindDefinitionDescription, r.renderDefinitionDescription)
+
+	// Extension nodes
+	reg.Register(KindAlert, r.renderAlert)
+	reg.Register(KindDetails, r.renderDetails)
+	reg.Register(KindUserMention, r.renderMention)
+	reg.Register(KindEmojiShortcode, r.renderEmojiShortcode)
+	reg.Register(KindStatusLozenge, r.renderStatusLozenge)
+	reg.Register(KindDateMacro, r.renderDateMacro)
+	reg.Register(KindDecisionMarker, r.renderDecisionMarker)
+	reg.Register(KindContainer, r.renderContainer)
+	reg.Register(KindConfluenceMacro, r.renderConfluenceMacro)
+}
+
+// renderState is the state of a single conversion.
+type renderState struct {
+	document  *Document
+	nodeStack []*Node
+	source    []byte
+	markStack []Mark
+	htmlMarks []htmlMark
+	localIDs  int
+
+	// listParents holds the ADF type of the node each list is rendered in.
+	listParents map[*ast.List]string
+
+	// footnotes holds the indexes of the footnotes being rendered inline.
+	footnotes []int
+}
+
+// renderStateAttribute is the name of the document attribute holding the
+// renderState of the conversion in progress.
+var renderStateAttribute = []byte("adf-render-state")
+
+// newRenderState creates the state for a new document.
+func newRenderState() *renderState {
+	return &renderState{
+		document:    NewDocument(),
+		nodeStack:   []*Node{},
+		markStack:   []Mark{},
+		listParents: map[*ast.List]string{},
+	}
 }
 
-// reset prepares the renderer for a new document.
-func (r *Renderer) reset() {
-	r.document = NewDocument()
-	r.nodeStack = []*Node{}
-	r.markStack = []Mark{}
+// state returns the render state of the document that node belongs to.
+func (r *Renderer) state(node ast.Node) *renderState {
+	v, _ := node.OwnerDocument().Attribute(renderStateAttribute)
+	return v.(*renderState)
+}
+
+// nextLocalID returns a localId that is unique within the current document.
+// IDs are derived from a per-document counter so that output is deterministic.
+func (s *renderState) nextLocalID() string {
+	s.localIDs++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012x", s.localIDs)
 }
 
 // currentNode returns the current node being built, or nil if at document level.
-func (r *Renderer) currentNode() *Node {
-	if len(r.nodeStack) == 0 {
+func (s *renderState) currentNode() *Node {
+	if len(s.nodeStack) == 0 {
 		return nil
 	}
-	return r.nodeStack[len(r.nodeStack)-1]
+	return s.nodeStack[len(s.nodeStack)-1]
 }
 
 // pushNode pushes a new node onto the stack.
-func (r *Renderer) pushNode(n *Node) {
-	r.nodeStack = append(r.nodeStack, n)
+func (s *renderState) pushNode(n *Node) {
+	s.nodeStack = append(s.nodeStack, n)
 }
 
 // popNode pops the current node from the stack and appends it to its parent.
-func (r *Renderer) popNode() {
-	if len(r.nodeStack) == 0 {
+func (s *renderState) popNode() {
+	if len(s.nodeStack) == 0 {
 		return
 	}
-	n := r.nodeStack[len(r.nodeStack)-1]
-	r.nodeStack = r.nodeStack[:len(r.nodeStack)-1]
+	n := s.nodeStack[len(s.nodeStack)-1]
+	s.nodeStack = s.nodeStack[:len(s.nodeStack)-1]
 
-	if len(r.nodeStack) > 0 {
-		parent := r.nodeStack[len(r.nodeStack)-1]
+	if len(s.nodeStack) > 0 {
+		parent := s.nodeStack[len(s.nodeStack)-1]
 		parent.AppendChild(*n)
 	} else {
-		r.document.Content = append(r.document.Content, *n)
+		s.document.Content = append(s.document.Content, *n)
 	}
 }
 
 // discardCurrentNode removes the current node from the stack without appending it.
 // Used to discard empty paragraphs during image handling.
-func (r *Renderer) discardCurrentNode() {
-	if len(r.nodeStack) > 0 {
-		r.nodeStack = r.nodeStack[:len(r.nodeStack)-1]
+func (s *renderState) discardCurrentNode() {
+	if len(s.nodeStack) > 0 {
+		s.nodeStack = s.nodeStack[:len(s.nodeStack)-1]
 	}
 }
 
 // appendToCurrentOrDocument appends a node to the current node or document.
-func (r *Renderer) appendToCurrentOrDocument(n Node) {
-	if len(r.nodeStack) > 0 {
-		r.nodeStack[len(r.nodeStack)-1].AppendChild(n)
+func (s *renderState) appendToCurrentOrDocument(n Node) {
+	if len(s.nodeStack) > 0 {
+		s.nodeStack[len(s.nodeStack)-1].AppendChild(n)
 	} else {
-		r.document.Content = append(r.document.Content, n)
+		s.document.Content = append(s.document.Content, n)
 	}
 }
 
 // pushMark adds a mark to the current mark stack.
-func (r *Renderer) pushMark(m Mark) {
-	r.markStack = append(r.markStack, m)
+func (s *renderState) pushMark(m Mark) {
+	s.markStack = append(s.markStack, m)
 }
 
 // popMark removes the last mark from the stack.
-func (r *Renderer) popMark() {
-	if len(r.markStack) > 0 {
-		r.markStack = r.markStack[:len(r.markStack)-1]
+func (s *renderState) popMark() {
+	if len(s.markStack) > 0 {
+		s.markStack = s.markStack[:len(s.markStack)-1]
 	}
 }
 
 // currentMarks returns a copy of the current marks.
-func (r *Renderer) currentMarks() []Mark {
-	if len(r.markStack) == 0 {
+func (s *renderState) currentMarks() []Mark {
+	if len(s.markStack) == 0 {
 		return nil
 	}
-	marks := make([]Mark, len(r.markStack))
-	copy(marks, r.markStack)
+	marks := make([]Mark, len(s.markStack))
+	copy(marks, s.markStack)
 	return marks
 }
 
@@ -155,10 +223,16 @@ func (r *Renderer) currentMarks() []Mark {
 
 func (r *Renderer) renderDocument(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	if entering {
-		r.reset()
+		st := newRenderState()
+		st.source = source
+		node.SetAttribute(renderStateAttribute, st)
 	} else {
+		st := r.state(node)
+		node.SetAttribute(renderStateAttribute, nil)
+		st.document.Normalize()
+
 		// Write the final JSON output
-		data, err := json.Marshal(r.document, jsontext.WithIndent("  "))
+		data, err := json.Marshal(st.document, jsontext.WithIndent("  "), json.Deterministic(true))
 		if err != nil {
 			return ast.WalkStop, err
 		}
@@ -171,25 +245,28 @@ func (r *Renderer) renderDocument(w util.BufWriter, source []byte, node ast.Node
 }
 
 func (r *Renderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
 		n := node.(*ast.Heading)
-		r.pushNode(NewHeading(n.Level))
+		st.pushNode(NewHeading(n.Level))
 	} else {
-		r.popNode()
+		st.popNode()
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderBlockquote(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
-		r.pushNode(NewBlockquote())
+		st.pushNode(NewBlockquote())
 	} else {
-		r.popNode()
+		st.popNode()
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
 		n := NewCodeBlock("")
 		// Collect all lines as text content
@@ -202,13 +279,14 @@ func (r *Renderer) renderCodeBlock(w util.BufWriter, source []byte, node ast.Nod
 		if text != "" {
 			n.AppendChild(*NewText(text))
 		}
-		r.appendToCurrentOrDocument(*n)
+		st.appendToCurrentOrDocument(*n)
 		return ast.WalkSkipChildren, nil
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
 		n := node.(*ast.FencedCodeBlock)
 		lang := ""
@@ -226,70 +304,225 @@ func (r *Renderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node a
 		if text != "" {
 			codeNode.AppendChild(*NewText(text))
 		}
-		r.appendToCurrentOrDocument(*codeNode)
+		st.appendToCurrentOrDocument(*codeNode)
 		return ast.WalkSkipChildren, nil
 	}
 	return ast.WalkContinue, nil
 }
 
-func (r *Renderer) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
-	// HTML blocks are not supported in ADF, skip them
-	return ast.WalkSkipChildren, nil
-}
-
 func (r *Renderer) renderList(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
 		n := node.(*ast.List)
-		if n.IsOrdered() {
-			r.pushNode(NewOrderedList(n.Start))
-		} else {
-			r.pushNode(NewBulletList())
+		st.listParents[n] = st.parentType()
+		var run string
+		if n.FirstChild() != nil {
+			run = r.listItemRun(st, n.FirstChild())
 		}
+		st.pushNode(r.newListRun(st, n, 0, run))
 	} else {
-		r.popNode()
+		st.popNode()
 	}
 	return ast.WalkContinue, nil
 }
 
+// listItemRun returns the type of list node a list item is rendered in:
+// "taskList", "decisionList", or "" for a bulletList or orderedList. Task and
+// decision items are only rendered in a taskList or decisionList where the
+// node the list is rendered in may hold one.
+func (r *Renderer) listItemRun(st *renderState, item ast.Node) string {
+	parentType := st.listParents[item.Parent().(*ast.List)]
+	switch {
+	case r.isTaskItem(item) && canContain(parentType, "taskList"):
+		return "taskList"
+	case r.isDecisionItem(item) && canContain(parentType, "decisionList"):
+		return "decisionList"
+	}
+	return ""
+}
+
+// newListRun creates the ADF list node for a run of items starting at index.
+// Lists mixing task, decision and regular items are split into consecutive
+// runs of taskList, decisionList and bulletList/orderedList nodes.
+func (r *Renderer) newListRun(st *renderState, list *ast.List, index int, run string) *Node {
+	switch run {
+	case "taskList":
+		return NewTaskList(st.nextLocalID())
+	case "decisionList":
+		return NewDecisionList(st.nextLocalID())
+	}
+	if list.IsOrdered() {
+		return NewOrderedList(list.Start + index)
+	}
+	return NewBulletList()
+}
+
 func (r *Renderer) renderListItem(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
-		r.pushNode(NewListItem())
+		run := r.listItemRun(st, node)
+
+		// Start a new run if this item does not belong in the current list node
+		if current := st.currentNode(); current != nil && listRunOf(current.Type) != run {
+			index := 0
+			for sib := node.PreviousSibling(); sib != nil; sib = sib.PreviousSibling() {
+				index++
+			}
+			st.popNode()
+			st.pushNode(r.newListRun(st, node.Parent().(*ast.List), index, run))
+		}
+
+		switch run {
+		case "taskList":
+			state := "TODO"
+			if taskCheckBox(node).IsChecked {
+				state = "DONE"
+			}
+			st.pushNode(NewTaskItem(st.nextLocalID(), state))
+		case "decisionList":
+			state := "UNDECIDED"
+			if decisionMarker(node).IsDecided {
+				state = "DECIDED"
+			}
+			st.pushNode(NewDecisionItem(st.nextLocalID(), state))
+		default:
+			st.pushNode(NewListItem())
+		}
 	} else {
-		r.popNode()
+		current := st.currentNode()
+		if current != nil && (current.Type == "taskItem" || current.Type == "decisionItem") {
+			st.popTaskItem()
+		} else {
+			st.popNode()
+		}
 	}
 	return ast.WalkContinue, nil
 }
 
+// listRunOf returns the run an ADF list node type belongs to, as returned by
+// listItemRun.
+func listRunOf(listType string) string {
+	if listType == "taskList" || listType == "decisionList" {
+		return listType
+	}
+	return ""
+}
+
+// taskCheckBox returns the task checkbox that starts a list item, or nil if
+// the item is not a task item.
+func taskCheckBox(item ast.Node) *extast.TaskCheckBox {
+	block := item.FirstChild()
+	if block == nil {
+		return nil
+	}
+	checkBox, _ := block.FirstChild().(*extast.TaskCheckBox)
+	return checkBox
+}
+
+// isTaskItem reports whether a list item can be rendered as an ADF taskItem.
+// The item must start with a task checkbox and may only contain paragraphs and
+// nested lists made up entirely of task items, since taskItem only accepts
+// inline content and taskList only nests other taskLists. Items that do not
+// qualify fall back to a listItem with a text checkbox prefix.
+func (r *Renderer) isTaskItem(item ast.Node) bool {
+	if taskCheckBox(item) == nil {
+		return false
+	}
+	for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c.Kind() {
+		case ast.KindParagraph, ast.KindTextBlock:
+			// External media or a custom image handler could split the
+			// paragraph into block nodes
+			if (r.config.ExternalMedia || r.config.ImageHandler != nil) && containsImage(c) {
+				return false
+			}
+		case ast.KindList:
+			for li := c.FirstChild(); li != nil; li = li.NextSibling() {
+				if !r.isTaskItem(li) {
+					return false
+				}
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// containsImage reports whether node has an image among its descendants.
+func containsImage(node ast.Node) bool {
+	found := false
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && n.Kind() == ast.KindImage {
+			found = true
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+// popTaskItem pops the current taskItem or decisionItem and appends it to the
+// enclosing list. Paragraphs rendered inside the item are flattened into inline
+// content separated by hard breaks, and nested task lists are moved after the
+// item because ADF nests task lists as siblings of their parent item.
+func (s *renderState) popTaskItem() {
+	item := s.currentNode()
+	s.discardCurrentNode()
+
+	content := []Node{}
+	var nested []Node
+	for _, child := range item.Content {
+		if child.Type != "paragraph" {
+			nested = append(nested, child)
+			continue
+		}
+		if len(content) > 0 && len(child.Content) > 0 {
+			content = append(content, *NewHardBreak())
+		}
+		content = append(content, child.Content...)
+	}
+	item.Content = content
+
+	s.appendToCurrentOrDocument(*item)
+	for _, n := range nested {
+		s.appendToCurrentOrDocument(n)
+	}
+}
+
 func (r *Renderer) renderParagraph(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
-		r.pushNode(NewParagraph())
+		st.pushNode(NewParagraph())
 	} else {
 		// Check if the paragraph is empty and discard it if so
 		// This handles cases where images split paragraphs and leave empty ones
-		current := r.currentNode()
+		current := st.currentNode()
 		if current != nil && current.Type == "paragraph" && len(current.Content) == 0 {
-			r.discardCurrentNode()
+			st.discardCurrentNode()
 		} else {
-			r.popNode()
+			st.popNode()
 		}
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderTextBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	// TextBlock is a lightweight paragraph used in tight lists
 	// In ADF, we still need to wrap content in a paragraph
 	if entering {
-		r.pushNode(NewParagraph())
+		st.pushNode(NewParagraph())
 	} else {
-		r.popNode()
+		st.popNode()
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderThematicBreak(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
-		r.appendToCurrentOrDocument(*NewRule())
+		st.appendToCurrentOrDocument(*NewRule())
 	}
 	return ast.WalkContinue, nil
 }
@@ -297,42 +530,49 @@ func (r *Renderer) renderThematicBreak(w util.BufWriter, source []byte, node ast
 // Inline node renderers
 
 func (r *Renderer) renderAutoLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
 		n := node.(*ast.AutoLink)
 		url := string(n.URL(source))
 		label := string(n.Label(source))
+		if n.AutoLinkType == ast.AutoLinkURL && r.renderSmartLink(st, n, url) {
+			return ast.WalkSkipChildren, nil
+		}
 
 		textNode := NewTextWithMarks(label, []Mark{NewLinkMark(url, "")})
-		r.appendToCurrentOrDocument(*textNode)
+		st.appendToCurrentOrDocument(*textNode)
 		return ast.WalkSkipChildren, nil
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderCodeSpan(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
-		r.pushMark(NewCodeMark())
+		st.pushMark(NewCodeMark())
 	} else {
-		r.popMark()
+		st.popMark()
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderEmphasis(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	n := node.(*ast.Emphasis)
 	if entering {
 		if n.Level == 2 {
-			r.pushMark(NewStrongMark())
+			st.pushMark(NewStrongMark())
 		} else {
-			r.pushMark(NewEmMark())
+			st.pushMark(NewEmMark())
 		}
 	} else {
-		r.popMark()
+		st.popMark()
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderImage(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if !entering {
 		return ast.WalkContinue, nil
 	}
@@ -343,58 +583,134 @@ func (r *Renderer) renderImage(w util.BufWriter, source []byte, node ast.Node, e
 	alt := ""
 	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
 		if t, ok := c.(*ast.Text); ok {
-			alt += string(t.Segment.Value(source))
+			alt += textValue(t, source)
 		}
 	}
-	if alt == "" {
-		alt = dest
-	}
 
 	title := ""
 	if n.Title != nil {
 		title = string(n.Title)
 	}
 
+	// Give a custom handler the first chance to render the image
+	if r.config.ImageHandler != nil {
+		custom := r.config.ImageHandler(ImageContext{
+			Destination: dest,
+			Alt:         alt,
+			Title:       title,
+			Block:       isBlockImage(n, source),
+			ParentType:  st.paragraphParentType(),
+		})
+		if custom != nil {
+			if inlineNodeTypes[custom.Type] {
+				st.appendToCurrentOrDocument(*custom)
+			} else {
+				st.insertBlock(*custom)
+			}
+			return ast.WalkSkipChildren, nil
+		}
+	}
+
+	if alt == "" {
+		alt = dest
+	}
+
 	// Check if external media is enabled
 	if r.config.ExternalMedia {
 		// Handle external media with paragraph splitting
-		r.renderExternalMedia(dest, alt, title)
+		r.renderExternalMedia(st, dest, alt, title)
 	} else {
 		// Fallback: convert image to a link
+		r.report(st, node, SeverityWarning, DiagnosticImageAsLink, "image %q rendered as a link; enable external media to embed it", dest)
 		textNode := NewTextWithMarks(alt, []Mark{NewLinkMark(dest, title)})
-		r.appendToCurrentOrDocument(*textNode)
+		st.appendToCurrentOrDocument(*textNode)
 	}
 
 	return ast.WalkSkipChildren, nil
 }
 
+// inlineNodeTypes lists the ADF node types that may appear in inline content.
+var inlineNodeTypes = map[string]bool{
+	"text":            true,
+	"hardBreak":       true,
+	"mention":         true,
+	"emoji":           true,
+	"date":            true,
+	"status":          true,
+	"inlineCard":      true,
+	"mediaInline":     true,
+	"inlineExtension": true,
+	"placeholder":     true,
+}
+
+// isBlockImage reports whether an image is the only content of its paragraph
+// (or table cell), ignoring surrounding whitespace.
+func isBlockImage(n *ast.Image, source []byte) bool {
+	switch n.Parent().Kind() {
+	case ast.KindParagraph, ast.KindTextBlock, extast.KindTableCell:
+	default:
+		return false
+	}
+	for c := n.Parent().FirstChild(); c != nil; c = c.NextSibling() {
+		if c == n {
+			continue
+		}
+		t, ok := c.(*ast.Text)
+		if !ok || len(bytes.TrimSpace(t.Segment.Value(source))) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// paragraphParentType returns the ADF type of the node containing the paragraph
+// or heading currently being built, or "doc" at the top level.
+func (s *renderState) paragraphParentType() string {
+	i := len(s.nodeStack) - 1
+	if i >= 0 && (s.nodeStack[i].Type == "paragraph" || s.nodeStack[i].Type == "heading") {
+		i--
+	}
+	if i < 0 {
+		return "doc"
+	}
+	return s.nodeStack[i].Type
+}
+
 // renderExternalMedia renders an image as an external media node.
 // If we're inside a paragraph, it splits the paragraph around the image.
-func (r *Renderer) renderExternalMedia(url, alt, title string) {
+func (r *Renderer) renderExternalMedia(st *renderState, url, alt, title string) {
+	st.insertBlock(*r.newMediaSingle(url, alt, title))
+}
+
+// insertBlock adds a block node at the current position. If we're inside a
+// paragraph, it splits the paragraph around the block.
+func (s *renderState) insertBlock(block Node) {
 	// Check if we're inside a paragraph
-	current := r.currentNode()
+	current := s.currentNode()
 	if current != nil && current.Type == "paragraph" {
 		// If the paragraph has content, pop it (appends to parent)
 		// If the paragraph is empty, just discard it
 		if len(current.Content) > 0 {
-			r.popNode()
+			s.popNode()
 		} else {
-			r.discardCurrentNode()
+			s.discardCurrentNode()
 		}
 
-		// Emit the mediaSingle with media (and caption if title present)
-		r.emitMediaSingle(url, alt, title)
+		s.appendToCurrentOrDocument(block)
 
-		// Push a new empty paragraph for remaining content
-		r.pushNode(NewParagraph())
+		// Push a new empty paragraph for remaining content, keeping any
+		// alignment of the original
+		next := NewParagraph()
+		next.Marks = current.Marks
+		s.pushNode(next)
 	} else {
-		// Not in a paragraph, just emit mediaSingle directly
-		r.emitMediaSingle(url, alt, title)
+		// Not in a paragraph, just add the block directly
+		s.appendToCurrentOrDocument(block)
 	}
 }
 
-// emitMediaSingle creates and appends a mediaSingle node with the given media content.
-func (r *Renderer) emitMediaSingle(url, alt, title string) {
+// newMediaSingle creates a mediaSingle node with the given media content.
+func (r *Renderer) newMediaSingle(url, alt, title string) *Node {
 	layout := r.config.ImageLayout
 	if layout == "" {
 		layout = "center"
@@ -411,56 +727,108 @@ func (r *Renderer) emitMediaSingle(url, alt, title string) {
 		mediaSingle.AppendChild(*caption)
 	}
 
-	r.appendToCurrentOrDocument(*mediaSingle)
+	return mediaSingle
 }
 
 func (r *Renderer) renderLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	n := node.(*ast.Link)
 	if entering {
 		title := ""
 		if n.Title != nil {
 			title = string(n.Title)
 		}
-		r.pushMark(NewLinkMark(string(n.Destination), title))
+		st.pushMark(NewLinkMark(string(n.Destination), title))
 	} else {
-		r.popMark()
+		st.popMark()
 	}
 	return ast.WalkContinue, nil
 }
 
-func (r *Renderer) renderRawHTML(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
-	// Raw HTML is not supported in ADF, skip it
-	return ast.WalkSkipChildren, nil
-}
-
 func (r *Renderer) renderText(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if !entering {
 		return ast.WalkContinue, nil
 	}
 	n := node.(*ast.Text)
-	segment := n.Segment
-	text := string(segment.Value(source))
+	text := textValue(n, source)
+
+	// Line endings inside code spans become spaces
+	if _, ok := n.Parent().(*ast.CodeSpan); ok {
+		text = strings.ReplaceAll(text, "\n", " ")
+	}
+
+	// A soft line break is a space unless hard wraps are enabled
+	softBreak := n.SoftLineBreak() && !n.HardLineBreak()
+	if softBreak && !r.config.HardWraps {
+		text += " "
+	}
 
 	if text != "" {
-		marks := r.currentMarks()
-		var textNode *Node
-		if len(marks) > 0 {
-			textNode = NewTextWithMarks(text, marks)
-		} else {
-			textNode = NewText(text)
+		textNode, err := r.newMarkedText(st, node, text)
+		if err != nil {
+			return ast.WalkStop, err
 		}
-		r.appendToCurrentOrDocument(*textNode)
+		st.appendToCurrentOrDocument(*textNode)
 	}
 
 	// Handle hard line break
-	if n.HardLineBreak() {
-		r.appendToCurrentOrDocument(*NewHardBreak())
+	if n.HardLineBreak() || softBreak && r.config.HardWraps {
+		st.appendToCurrentOrDocument(*NewHardBreak())
 	}
 
 	return ast.WalkContinue, nil
 }
 
+// newMarkedText creates a text node for node carrying the active marks,
+// resolved into a combination ADF allows.
+func (r *Renderer) newMarkedText(st *renderState, node ast.Node, text string) (*Node, error) {
+	return r.newResolvedText(st, node, text, st.textMarks(node))
+}
+
+// newResolvedText creates a text node for node carrying marks, resolved into a
+// combination ADF allows. Conflicts are reported as diagnostics.
+func (r *Renderer) newResolvedText(st *renderState, node ast.Node, text string, marks []Mark) (*Node, error) {
+	marks, conflict := resolveMarks(text, marks, r.config.MarkConflicts)
+	if conflict != nil {
+		msg := fmt.Sprintf("marks %s cannot be combined on %q", strings.Join(conflict.Marks, ", "), text)
+		switch r.config.MarkConflicts {
+		case MarkConflictFail:
+			r.report(st, node, SeverityError, DiagnosticMarkConflict, "%s", msg)
+			return nil, conflict
+		case MarkConflictDropCode:
+			r.report(st, node, SeverityWarning, DiagnosticMarkConflict, "%s; dropped code", msg)
+		default:
+			r.report(st, node, SeverityWarning, DiagnosticMarkConflict, "%s; kept code", msg)
+		}
+	}
+	if len(marks) > 0 {
+		return NewTextWithMarks(text, marks), nil
+	}
+	return NewText(text), nil
+}
+
+// escapeRegexp matches backslash escapes and entity or numeric character
+// references in Markdown text.
+var escapeRegexp = regexp.MustCompile(`\\[!-/:-@\[-` + "`" + `{-~]|&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[A-Za-z][A-Za-z0-9]*);`)
+
+// textValue returns the literal value of a text node, resolving backslash
+// escapes and character references unless the text is raw (e.g. in a code span).
+func textValue(n *ast.Text, source []byte) string {
+	value := n.Segment.Value(source)
+	if n.IsRaw() {
+		return string(value)
+	}
+	return escapeRegexp.ReplaceAllStringFunc(string(value), func(m string) string {
+		if m[0] == '\\' {
+			return m[1:]
+		}
+		return html.UnescapeString(m)
+	})
+}
+
 func (r *Renderer) renderString(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if !entering {
 		return ast.WalkContinue, nil
 	}
@@ -468,14 +836,11 @@ func (r *Renderer) renderString(w util.BufWriter, source []byte, node ast.Node,
 	text := string(n.Value)
 
 	if text != "" {
-		marks := r.currentMarks()
-		var textNode *Node
-		if len(marks) > 0 {
-			textNode = NewTextWithMarks(text, marks)
-		} else {
-			textNode = NewText(text)
+		textNode, err := r.newMarkedText(st, node, text)
+		if err != nil {
+			return ast.WalkStop, err
 		}
-		r.appendToCurrentOrDocument(*textNode)
+		st.appendToCurrentOrDocument(*textNode)
 	}
 
 	return ast.WalkContinue, nil
@@ -484,71 +849,119 @@ func (r *Renderer) renderString(w util.BufWriter, source []byte, node ast.Node,
 // GFM extension renderers
 
 func (r *Renderer) renderTable(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
-		r.pushNode(NewTable())
+		st.pushNode(r.newTable())
 	} else {
-		r.popNode()
+		st.popNode()
 	}
 	return ast.WalkContinue, nil
 }
 
+// newTable creates a table node with the configured table attributes.
+func (r *Renderer) newTable() *Node {
+	table := NewTable()
+	if r.config.TableLayout != "" {
+		table.Attrs["layout"] = r.config.TableLayout
+	}
+	table.Attrs["isNumberColumnEnabled"] = r.config.TableNumberColumn
+	if r.config.TableDisplayMode != "" {
+		table.Attrs["displayMode"] = r.config.TableDisplayMode
+	}
+	return table
+}
+
 func (r *Renderer) renderTableHeader(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
-		r.pushNode(NewTableRow())
+		st.pushNode(NewTableRow())
 	} else {
-		r.popNode()
+		st.popNode()
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderTableRow(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
-		r.pushNode(NewTableRow())
+		st.pushNode(NewTableRow())
 	} else {
-		r.popNode()
+		st.popNode()
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderTableCell(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
 		n := node.(*extast.TableCell)
 		// Determine if this is a header cell based on parent
-		parent := n.Parent()
-		if _, isHeader := parent.(*extast.TableHeader); isHeader {
-			cell := NewTableHeader()
-			r.pushNode(cell)
-			// Table cells need paragraph wrapper
-			r.pushNode(NewParagraph())
+		var cell *Node
+		if _, isHeader := n.Parent().(*extast.TableHeader); isHeader {
+			cell = NewTableHeader()
 		} else {
-			cell := NewTableCell()
-			r.pushNode(cell)
-			// Table cells need paragraph wrapper
-			r.pushNode(NewParagraph())
+			cell = NewTableCell()
+		}
+
+		column := 0
+		for c := n.PreviousSibling(); c != nil; c = c.PreviousSibling() {
+			column++
+		}
+		if column < len(r.config.TableColumnWidths) && r.config.TableColumnWidths[column] > 0 {
+			cell.Attrs["colwidth"] = []int{r.config.TableColumnWidths[column]}
+		}
+		st.pushNode(cell)
+
+		// Table cells need paragraph wrapper, which carries the column
+		// alignment. Left is the default, so only center and right need a mark.
+		para := NewParagraph()
+		switch n.Alignment {
+		case extast.AlignCenter:
+			para.AppendMark(NewAlignmentMark("center"))
+		case extast.AlignRight:
+			para.AppendMark(NewAlignmentMark("end"))
 		}
+		st.pushNode(para)
 	} else {
-		// Pop the paragraph
-		r.popNode()
+		// Pop the paragraph, dropping it if a block (such as a card) split it
+		// and left it empty
+		if cell := st.nodeStack[len(st.nodeStack)-2]; len(st.currentNode().Content) == 0 && len(cell.Content) > 0 {
+			st.discardCurrentNode()
+		} else {
+			st.popNode()
+		}
 		// Pop the cell
-		r.popNode()
+		st.popNode()
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderStrikethrough(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if entering {
-		r.pushMark(NewStrikeMark())
+		st.pushMark(NewStrikeMark())
 	} else {
-		r.popMark()
+		st.popMark()
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderTaskCheckBox(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	st := r.state(node)
 	if !entering {
 		return ast.WalkContinue, nil
 	}
+	// Task items carry their state in attrs, so the checkbox emits nothing
+	item := node.Parent().Parent()
+	if r.listItemRun(st, item) == "taskList" {
+		return ast.WalkContinue, nil
+	}
 	n := node.(*extast.TaskCheckBox)
+	if r.isTaskItem(item) {
+		r.report(st, item, SeverityWarning, DiagnosticTaskItemAsText, "%s cannot contain taskList; rendered with a text checkbox", st.listParents[item.Parent().(*ast.List)])
+	} else {
+		r.report(st, item, SeverityWarning, DiagnosticTaskItemAsText, "task item holds content a taskItem cannot contain; rendered with a text checkbox")
+	}
 	// Render checkbox as text prefix
 	var text string
 	if n.IsChecked {
@@ -556,7 +969,7 @@ func (r *Renderer) renderTaskCheckBox(w util.BufWriter, source []byte, node ast.
 	} else {
 		text = "[ ] "
 	}
-	r.appendToCurrentOrDocument(*NewText(text))
+	st.appendToCurrentOrDocument(*NewText(text))
 	return ast.WalkContinue, nil
 }
 
@@ -94,7 +94,7 @@ func migrate(schema map[string]any) {
 			}
 		}
 		// Handle id -> $id rename at top level
-		if key == "id" {
+		if _, isString := value.(string); key == "id" && isString {
 			if _, hasID := schema["$id"]; !hasID {
 				schema["$id"] = value
 				delete(schema, "id")
@@ -121,8 +121,9 @@ func migrateObject(obj map[string]any) {
 		}
 	}
 
-	// Handle id -> $id rename
-	if id, ok := obj["id"]; ok {
+	// Handle id -> $id rename. Only string values are schema identifiers;
+	// an object is the schema of a property named "id" and is kept.
+	if id, ok := obj["id"].(string); ok {
 		if _, has := obj["$id"]; !has {
 			obj["$id"] = id
 			delete(obj, "id")
@@ -0,0 +1,103 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// SmartLinkAppearance selects how a URL is rendered as a smart link.
+type SmartLinkAppearance int
+
+const (
+	// SmartLinkNone renders the URL as text with a link mark.
+	SmartLinkNone SmartLinkAppearance = iota
+
+	// SmartLinkInline renders the URL as an inlineCard.
+	SmartLinkInline
+
+	// SmartLinkBlock renders the URL as a blockCard.
+	SmartLinkBlock
+
+	// SmartLinkEmbed renders the URL as an embedCard.
+	SmartLinkEmbed
+)
+
+// SmartLinkRule selects how autolinks (<https://…> and, with GFM, bare URLs)
+// to matching hosts are rendered. Links written as [text](url) are never
+// rendered as smart links.
+type SmartLinkRule struct {
+	// Host is a host name pattern in path.Match syntax, matched against the
+	// lower-cased host of the URL: "example.com" matches only that host,
+	// "*.example.com" its subdomains and "*" every host.
+	Host string
+
+	// Inline is the appearance of autolinks within text: SmartLinkNone or
+	// SmartLinkInline.
+	Inline SmartLinkAppearance
+
+	// Standalone is the appearance of an autolink alone in its paragraph. Where
+	// ADF does not allow a blockCard or embedCard, such as in lists and
+	// blockquotes, SmartLinkBlock and SmartLinkEmbed fall back to an
+	// inlineCard.
+	Standalone SmartLinkAppearance
+}
+
+// smartLinkRule returns the first rule matching the host of rawURL.
+func (r *Renderer) smartLinkRule(rawURL string) (SmartLinkRule, bool) {
+	if len(r.config.SmartLinks) == 0 {
+		return SmartLinkRule{}, false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return SmartLinkRule{}, false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, rule := range r.config.SmartLinks {
+		if ok, _ := path.Match(strings.ToLower(rule.Host), host); ok {
+			return rule, true
+		}
+	}
+	return SmartLinkRule{}, false
+}
+
+// renderSmartLink renders an autolink as a smart link if a rule asks for one,
+// reporting whether it did.
+func (r *Renderer) renderSmartLink(st *renderState, node *ast.AutoLink, rawURL string) bool {
+	rule, ok := r.smartLinkRule(rawURL)
+	if !ok {
+		return false
+	}
+
+	// Table cells hold their inline content directly
+	appearance := rule.Inline
+	switch node.Parent().(type) {
+	case *ast.Paragraph, *extast.TableCell:
+		if node.Parent().ChildCount() == 1 {
+			appearance = rule.Standalone
+		}
+	}
+
+	switch appearance {
+	case SmartLinkBlock, SmartLinkEmbed:
+		card := NewBlockCard(rawURL)
+		if appearance == SmartLinkEmbed {
+			card = NewEmbedCard(rawURL, "center")
+		}
+		if canContain(st.paragraphParentType(), card.Type) {
+			st.insertBlock(*card)
+			return true
+		}
+		st.appendToCurrentOrDocument(*NewInlineCard(rawURL))
+		return true
+	case SmartLinkInline:
+		st.appendToCurrentOrDocument(*NewInlineCard(rawURL))
+		return true
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+//go:build goexperiment.jsonv2
+
+package adf
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindStatusLozenge is a NodeKind of the StatusLozenge node.
+var KindStatusLozenge = ast.NewNodeKind("StatusLozenge")
+
+// StatusLozenge is an inline node representing a status lozenge such as
+// {status:In Progress|blue}. Its single child is the lozenge as written.
+type StatusLozenge struct {
+	ast.BaseInline
+
+	// Label is the text of the lozenge.
+	Label string
+
+	// Color is the lower-cased colour as written, or "" if none was given.
+	Color string
+}
+
+// NewStatusLozenge creates a new StatusLozenge node.
+func NewStatusLozenge(label, color string) *StatusLozenge {
+	return &StatusLozenge{Label: label, Color: color}
+}
+
+// Kind implements ast.Node.Kind.
+func (n *StatusLozenge) Kind() ast.NodeKind {
+	return KindStatusLozenge
+}
+
+// Dump implements ast.Node.Dump.
+func (n *StatusLozenge) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Label": n.Label, "Color": n.Color}, nil)
+}
+
+// StatusSyntax describes the delimiters of status lozenges: Open, then the
+// text, then optionally Separator and a colour, then Close.
+type StatusSyntax struct {
+	Open      string
+	Separator string
+	Close     string
+}
+
+// DefaultStatusSyntax is the default status syntax, {status:Text|color}.
+var DefaultStatusSyntax = StatusSyntax{Open: "{status:", Separator: "|", Close: "}"}
+
+// statusColors lists the colours a status may have.
+var statusColors = map[string]bool{
+	"neutral": true,
+	"purple":  true,
+	"blue":    true,
+	"red":     true,
+	"yellow":  true,
+	"green":   true,
+}
+
+// statusParser parses status lozenges.
+type statusParser struct {
+	syntax StatusSyntax
+	re     *regexp.Regexp
+}
+
+// NewStatusParser returns a parser.InlineParser that parses status lozenges
+// written in the given syntax into [StatusLozenge] nodes. The text may not
+// span lines and is trimmed; a lozenge without text is left as is. The colour
+// is made of letters and is optional. The separator may be preceded by a
+// backslash, so {status:Done\|green} can be written in a table cell. Open and
+// Close must not be empty.
+func NewStatusParser(syntax StatusSyntax) parser.InlineParser {
+	pattern := `^` + regexp.QuoteMeta(syntax.Open) + `([^\n]+?)`
+	if syntax.Separator != "" {
+		// Allow the separator to be escaped, as a pipe must be in table cells
+		pattern += `(?:\\?` + regexp.QuoteMeta(syntax.Separator) + `\s*([A-Za-z]+)\s*)?`
+	}
+	pattern += regexp.QuoteMeta(syntax.Close)
+	return &statusParser{syntax: syntax, re: regexp.MustCompile(pattern)}
+}
+
+// Trigger implements parser.InlineParser.
+func (p *statusParser) Trigger() []byte {
+	return []byte{p.syntax.Open[0]}
+}
+
+// Parse implements parser.InlineParser.
+func (p *statusParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	m := p.re.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	statusText := strings.TrimFunc(string(m[1]), unicode.IsSpace)
+	if statusText == "" {
+		return nil
+	}
+
+	var color string
+	if len(m) > 2 {
+		color = strings.ToLower(string(m[2]))
+	}
+	node := NewStatusLozenge(statusText, color)
+	node.AppendChild(node, ast.NewTextSegment(segment.WithStop(segment.Start+len(m[0]))))
+	block.Advance(len(m[0]))
+	return node
+}
+
+// addStatusParser adds the status parser for the given syntax. An empty
+// Open or Close disables status lozenges.
+func addStatusParser(md goldmark.Markdown, syntax StatusSyntax) {
+	if syntax.Open == "" || syntax.Close == "" {
+		return
+	}
+	md.Parser().AddOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(NewStatusParser(syntax), 150),
+		),
+	)
+}
+
+func (r *Renderer) renderStatusLozenge(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	st := r.state(node)
+	n := node.(*StatusLozenge)
+
+	color := n.Color
+	if color == "" {
+		color = "neutral"
+	} else if !statusColors[color] {
+		r.report(st, node, SeverityWarning, DiagnosticStatusColorInvalid, "status color %q is not one of neutral, purple, blue, red, yellow, green; used neutral", n.Color)
+		color = "neutral"
+	}
+	st.appendToCurrentOrDocument(*NewStatus(n.Label, color, st.nextLocalID()))
+	return ast.WalkSkipChildren, nil
+}